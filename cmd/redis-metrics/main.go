@@ -16,6 +16,51 @@ func main() {
 		Version: version.Version.String(),
 		Flags:   config.Flags,
 		Action:  metrics.RedisMetricsAction,
+		Commands: []*cli.Command{
+			{
+				Name:   "collect",
+				Usage:  "collect a Redis server once and print its metrics",
+				Flags:  config.CollectFlags,
+				Action: metrics.CollectAction,
+			},
+			{
+				Name:   "check-config",
+				Usage:  "check the configuration files and exit",
+				Flags:  config.CheckConfigFlags,
+				Action: metrics.CheckConfigAction,
+			},
+			{
+				Name:      "replay",
+				Usage:     "replay recorded INFO snapshots and print the resulting metrics",
+				ArgsUsage: "FILE_OR_DIR...",
+				Flags:     config.ReplayFlags,
+				Action:    metrics.ReplayAction,
+			},
+			{
+				Name:   "topology",
+				Usage:  "collect every configured Redis server once and print the replication graph",
+				Flags:  config.TopologyFlags,
+				Action: metrics.TopologyAction,
+			},
+			{
+				Name:  "generate",
+				Usage: "generate configuration for other tools from the metrics of the exporter",
+				Subcommands: []*cli.Command{
+					{
+						Name:   "rules",
+						Usage:  "print Prometheus recording and alerting rules",
+						Flags:  config.GenerateRulesFlags,
+						Action: metrics.GenerateRulesAction,
+					},
+					{
+						Name:   "dashboard",
+						Usage:  "print a Grafana dashboard",
+						Flags:  config.GenerateDashboardFlags,
+						Action: metrics.GenerateDashboardAction,
+					},
+				},
+			},
+		},
 		Authors: []*cli.Author{
 			&cli.Author{
 				Name:  "Chen Weiran",
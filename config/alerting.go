@@ -0,0 +1,160 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	AlertRuleDown            = "down"
+	AlertRuleMemoryUsage     = "memory_usage"
+	AlertRuleReplicaLinkDown = "replica_link_down"
+	AlertRuleBgsaveFailing   = "rdb_bgsave_failing"
+	AlertRuleEvictions       = "evictions"
+	AlertRuleInfo            = "info"
+
+	NotifierWebhook      = "webhook"
+	NotifierAlertmanager = "alertmanager"
+)
+
+var alertOps = map[string]bool{">": true, ">=": true, "<": true, "<=": true, "==": true, "!=": true}
+
+// AlertingConfig holds the built-in alert rules, evaluated after every
+// collection of a target, and the notifiers their alerts are sent to.
+type AlertingConfig struct {
+	Rules     []*AlertRule     `yaml:"rules,omitempty"`
+	Notifiers []*AlertNotifier `yaml:"notifiers,omitempty"`
+}
+
+// AlertRule fires once its condition held for For consecutive collections
+// of a target. Threshold is the maxmemory ratio of memory_usage rules, the
+// keys evicted between two collections of evictions rules and the value
+// Field, a Section.key of INFO, is compared to with Op by info rules.
+// Targets limits the rule to the named targets. Summary replaces the
+// default summary of the alerts, {{node}} and {{value}} in it are replaced
+// with the target name and the value of the rule.
+type AlertRule struct {
+	Name      string   `yaml:"name"`
+	Type      string   `yaml:"type"`
+	Threshold float64  `yaml:"threshold,omitempty"`
+	Field     string   `yaml:"field,omitempty"`
+	Op        string   `yaml:"op,omitempty"`
+	For       int      `yaml:"for,omitempty"`
+	Targets   []string `yaml:"targets,omitempty"`
+	Severity  string   `yaml:"severity,omitempty"`
+	Summary   string   `yaml:"summary,omitempty"`
+}
+
+// AlertNotifier is a generic webhook, sent an Alertmanager webhook style
+// payload on every state change, or an Alertmanager, sent the firing alerts
+// after every evaluation and the resolved ones once.
+type AlertNotifier struct {
+	Type    string        `yaml:"type,omitempty"`
+	URL     string        `yaml:"url"`
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+	Retries int           `yaml:"retries,omitempty"`
+}
+
+// Matches reports whether the rule applies to the target named name.
+func (r *AlertRule) Matches(name string) bool {
+	if len(r.Targets) == 0 {
+		return true
+	}
+	for _, target := range r.Targets {
+		if target == name {
+			return true
+		}
+	}
+	return false
+}
+
+// SectionKey splits the Field of an info rule.
+func (r *AlertRule) SectionKey() (string, string) {
+	i := strings.Index(r.Field, ".")
+	if i < 0 {
+		return "", r.Field
+	}
+	return r.Field[:i], r.Field[i+1:]
+}
+
+func parseAlertingConfig(a *AlertingConfig) error {
+	names := make(map[string]bool)
+	for _, rule := range a.Rules {
+		if err := parseAlertRule(rule); err != nil {
+			return fmt.Errorf("alert rule %q: %s", rule.Name, err)
+		}
+		if names[rule.Name] {
+			return fmt.Errorf("duplicate alert rule name %q", rule.Name)
+		}
+		names[rule.Name] = true
+	}
+	for _, notifier := range a.Notifiers {
+		if err := parseAlertNotifier(notifier); err != nil {
+			return fmt.Errorf("alert notifier %q: %s", notifier.URL, err)
+		}
+	}
+	return nil
+}
+
+func parseAlertRule(r *AlertRule) error {
+	if r.Name == "" {
+		return errors.New("the alert rule name must not be empty")
+	}
+	if r.For == 0 {
+		r.For = 1
+	}
+	if r.For < 0 {
+		return errors.New("for must not be negative")
+	}
+	if r.Severity == "" {
+		r.Severity = "warning"
+	}
+	switch r.Type {
+	case AlertRuleDown, AlertRuleReplicaLinkDown, AlertRuleBgsaveFailing:
+	case AlertRuleMemoryUsage:
+		if r.Threshold <= 0 {
+			return errors.New("memory_usage requires a threshold above 0, e.g. 0.9")
+		}
+	case AlertRuleEvictions:
+		if r.Threshold < 0 {
+			return errors.New("evictions requires a threshold of 0 or more")
+		}
+	case AlertRuleInfo:
+		if section, key := r.SectionKey(); section == "" || key == "" {
+			return fmt.Errorf("info requires a field of the form Section.key, not %q", r.Field)
+		}
+		if r.Op == "" {
+			r.Op = ">"
+		}
+		if !alertOps[r.Op] {
+			return fmt.Errorf("unknown op %q", r.Op)
+		}
+	default:
+		return fmt.Errorf("unknown type %q", r.Type)
+	}
+	return nil
+}
+
+func parseAlertNotifier(n *AlertNotifier) error {
+	if n.Type == "" {
+		n.Type = NotifierWebhook
+	}
+	if n.Type != NotifierWebhook && n.Type != NotifierAlertmanager {
+		return fmt.Errorf("type must be %q or %q", NotifierWebhook, NotifierAlertmanager)
+	}
+	if n.URL == "" {
+		return errors.New("url must not be empty")
+	}
+	if n.Timeout == 0 {
+		n.Timeout = 10 * time.Second
+	}
+	if n.Retries == 0 {
+		n.Retries = 3
+	}
+	if n.Retries < 0 {
+		return errors.New("retries must not be negative")
+	}
+	return nil
+}
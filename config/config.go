@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/go-redis/redis/v7"
 	"gopkg.in/yaml.v2"
+	"reflect"
 	"time"
 )
 
@@ -31,6 +32,7 @@ func (a RedisAddress) valid() error {
 
 type RedisConfig struct {
 	RedisInstances RedisInstanceSlice `yaml:"redis,omitempty"`
+	Alerting       AlertingConfig     `yaml:"alerting,omitempty"`
 }
 
 func (r RedisConfig) Equal(n RedisConfig) bool {
@@ -39,11 +41,11 @@ func (r RedisConfig) Equal(n RedisConfig) bool {
 	}
 	for i, r1 := range r.RedisInstances {
 		r2 := n.RedisInstances[i]
-		if r1 != r2 {
+		if *r1 != *r2 {
 			return false
 		}
 	}
-	return true
+	return reflect.DeepEqual(r.Alerting, n.Alerting)
 }
 
 type RedisInstanceSlice []*RedisInstance
@@ -56,6 +58,15 @@ type RedisInstance struct {
 	ReadTimeout  time.Duration `yaml:"read_timeout"`
 }
 
+// InstanceName returns the configured name, or a name derived from the
+// address when none is configured.
+func (r *RedisInstance) InstanceName() string {
+	if r.Name == "" {
+		return fmt.Sprintf("redis-server-%s", r.address())
+	}
+	return r.Name
+}
+
 func (r *RedisInstance) RedisOptions() *redis.Options {
 	var options = redis.Options{}
 	options.Addr = r.address()
@@ -72,11 +83,20 @@ func ParseRedisConfig(f []byte) (*RedisConfig, error) {
 	if err != nil {
 		return nil, err
 	}
+	names := make(map[string]bool)
 	for _, redisInstance := range redisConfig.RedisInstances {
 		err = parseRedisInstance(redisInstance)
 		if err != nil {
 			return nil, err
 		}
+		name := redisInstance.InstanceName()
+		if names[name] {
+			return nil, fmt.Errorf("duplicate Redis instance name %q", name)
+		}
+		names[name] = true
+	}
+	if err := parseAlertingConfig(&redisConfig.Alerting); err != nil {
+		return nil, err
 	}
 	return &redisConfig, nil
 }
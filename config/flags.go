@@ -1,6 +1,8 @@
 package config
 
 import (
+	"time"
+
 	"github.com/urfave/cli/v2"
 )
 
@@ -25,6 +27,12 @@ var Flags = []cli.Flag{
 		Usage:   "Load configuration from `FILE`",
 		Value:   "/etc/redis-metrics.yaml",
 	},
+	&cli.StringFlag{
+		EnvVars: []string{"WEB_CONFIG", "WEB_CONFIG_FILE"},
+		Name:    "web-config",
+		Usage:   "Load TLS and authentication settings of the Http endpoints from `FILE`",
+		Value:   "",
+	},
 	&cli.IntFlag{
 		Name:    "collector-interval",
 		Aliases: []string{"i"},
@@ -32,4 +40,395 @@ var Flags = []cli.Flag{
 		Usage:   "interval seconds of Redis collector scrape.",
 		Value:   60,
 	},
+	&cli.DurationFlag{
+		Name:    "config-watch-interval",
+		EnvVars: []string{"CONFIG_WATCH_INTERVAL"},
+		Usage:   "how often the configuration file is checked for changes, 0 disables watching.",
+		Value:   10 * time.Second,
+	},
+	&cli.DurationFlag{
+		Name:    "config-reload-debounce",
+		EnvVars: []string{"CONFIG_RELOAD_DEBOUNCE"},
+		Usage:   "quiet period to wait for after a reload request before the configuration file is reloaded.",
+		Value:   2 * time.Second,
+	},
+	&cli.DurationFlag{
+		Name:    "shutdown-timeout",
+		EnvVars: []string{"SHUTDOWN_TIMEOUT"},
+		Usage:   "maximum time to wait for the Http server, in-flight collections and queued output updates to finish on shutdown.",
+		Value:   30 * time.Second,
+	},
+	&cli.StringFlag{
+		Name:    "push-gateway-url",
+		EnvVars: []string{"PUSH_GATEWAY_URL"},
+		Usage:   "push the metrics of every target to the Pushgateway at `URL` after each collection.",
+	},
+	&cli.StringFlag{
+		Name:    "push-job",
+		EnvVars: []string{"PUSH_JOB"},
+		Usage:   "job name used for Pushgateway groups.",
+		Value:   "redis-metrics",
+	},
+	&cli.IntFlag{
+		Name:    "push-retries",
+		EnvVars: []string{"PUSH_RETRIES"},
+		Usage:   "number of times a failed push is retried.",
+		Value:   3,
+	},
+	&cli.DurationFlag{
+		Name:    "push-retry-backoff",
+		EnvVars: []string{"PUSH_RETRY_BACKOFF"},
+		Usage:   "wait before the first retry of a push, doubled for every further retry.",
+		Value:   time.Second,
+	},
+	&cli.DurationFlag{
+		Name:    "push-timeout",
+		EnvVars: []string{"PUSH_TIMEOUT"},
+		Usage:   "timeout of a single push request.",
+		Value:   10 * time.Second,
+	},
+	&cli.StringFlag{
+		Name:    "remote-write-url",
+		EnvVars: []string{"REMOTE_WRITE_URL"},
+		Usage:   "send the samples of every collection to the Prometheus remote write endpoint at `URL`.",
+	},
+	&cli.StringSliceFlag{
+		Name:    "remote-write-external-label",
+		EnvVars: []string{"REMOTE_WRITE_EXTERNAL_LABELS"},
+		Usage:   "`NAME=VALUE` label added to every remote write series, may be repeated.",
+	},
+	&cli.StringSliceFlag{
+		Name:    "remote-write-header",
+		EnvVars: []string{"REMOTE_WRITE_HEADERS"},
+		Usage:   "`NAME=VALUE` Http header sent with every remote write request, e.g. X-Scope-OrgID, may be repeated.",
+	},
+	&cli.IntFlag{
+		Name:    "remote-write-batch-size",
+		EnvVars: []string{"REMOTE_WRITE_BATCH_SIZE"},
+		Usage:   "maximum number of series sent in one remote write request.",
+		Value:   500,
+	},
+	&cli.IntFlag{
+		Name:    "remote-write-queue-capacity",
+		EnvVars: []string{"REMOTE_WRITE_QUEUE_CAPACITY"},
+		Usage:   "number of series buffered for remote write before new ones are dropped.",
+		Value:   10000,
+	},
+	&cli.DurationFlag{
+		Name:    "remote-write-flush-interval",
+		EnvVars: []string{"REMOTE_WRITE_FLUSH_INTERVAL"},
+		Usage:   "maximum time series wait in the queue before a partial batch is sent.",
+		Value:   5 * time.Second,
+	},
+	&cli.IntFlag{
+		Name:    "remote-write-retries",
+		EnvVars: []string{"REMOTE_WRITE_RETRIES"},
+		Usage:   "number of times a failed remote write request is retried.",
+		Value:   3,
+	},
+	&cli.DurationFlag{
+		Name:    "remote-write-retry-backoff",
+		EnvVars: []string{"REMOTE_WRITE_RETRY_BACKOFF"},
+		Usage:   "wait before the first retry of a remote write request, doubled for every further retry.",
+		Value:   time.Second,
+	},
+	&cli.DurationFlag{
+		Name:    "remote-write-timeout",
+		EnvVars: []string{"REMOTE_WRITE_TIMEOUT"},
+		Usage:   "timeout of a single remote write request.",
+		Value:   10 * time.Second,
+	},
+	&cli.StringFlag{
+		Name:    "graphite-address",
+		EnvVars: []string{"GRAPHITE_ADDRESS"},
+		Usage:   "send the Redis metrics to the Graphite plaintext listener at `HOST:PORT` after each collection.",
+	},
+	&cli.StringFlag{
+		Name:    "graphite-template",
+		EnvVars: []string{"GRAPHITE_TEMPLATE"},
+		Usage:   "Graphite metric path template, using {{.NodeName}}, {{.NodeAddress}}, {{.Section}} and {{.Name}}.",
+		Value:   "redis.{{.NodeName}}.{{.Section}}.{{.Name}}",
+	},
+	&cli.StringFlag{
+		Name:    "graphite-label-mode",
+		EnvVars: []string{"GRAPHITE_LABEL_MODE"},
+		Usage:   "send labels as Graphite tags (tags) or as further path segments (path).",
+		Value:   "path",
+	},
+	&cli.StringFlag{
+		Name:    "statsd-address",
+		EnvVars: []string{"STATSD_ADDRESS"},
+		Usage:   "send the Redis metrics as gauges to the StatsD server at `HOST:PORT` after each collection.",
+	},
+	&cli.StringFlag{
+		Name:    "statsd-template",
+		EnvVars: []string{"STATSD_TEMPLATE"},
+		Usage:   "StatsD metric name template, using {{.NodeName}}, {{.NodeAddress}}, {{.Section}} and {{.Name}}.",
+		Value:   "redis.{{.NodeName}}.{{.Section}}.{{.Name}}",
+	},
+	&cli.StringFlag{
+		Name:    "statsd-label-mode",
+		EnvVars: []string{"STATSD_LABEL_MODE"},
+		Usage:   "send labels as DogStatsD tags (tags) or as further name segments (path).",
+		Value:   "path",
+	},
+	&cli.StringFlag{
+		Name:    "influxdb-url",
+		EnvVars: []string{"INFLUXDB_URL"},
+		Usage:   "post the Redis metrics in line protocol to the InfluxDB write endpoint at `URL` after each collection.",
+	},
+	&cli.StringFlag{
+		Name:    "influxdb-token",
+		EnvVars: []string{"INFLUXDB_TOKEN"},
+		Usage:   "token sent in the Authorization header of InfluxDB writes.",
+	},
+	&cli.StringFlag{
+		Name:    "influxdb-template",
+		EnvVars: []string{"INFLUXDB_TEMPLATE"},
+		Usage:   "InfluxDB measurement template, using {{.NodeName}}, {{.NodeAddress}}, {{.Section}} and {{.Name}}.",
+		Value:   "redis_{{.Section}}",
+	},
+	&cli.StringFlag{
+		Name:    "influxdb-label-mode",
+		EnvVars: []string{"INFLUXDB_LABEL_MODE"},
+		Usage:   "send labels as InfluxDB tags (tags) or append them to the measurement (path).",
+		Value:   "tags",
+	},
+	&cli.DurationFlag{
+		Name:    "sink-timeout",
+		EnvVars: []string{"SINK_TIMEOUT"},
+		Usage:   "timeout of a single write to the Graphite, StatsD or InfluxDB sink.",
+		Value:   10 * time.Second,
+	},
+	&cli.StringFlag{
+		Name:    "otlp-url",
+		EnvVars: []string{"OTLP_URL"},
+		Usage:   "export the Redis metrics over OTLP/HTTP to `URL`, e.g. http://localhost:4318/v1/metrics, after each collection.",
+	},
+	&cli.StringSliceFlag{
+		Name:    "otlp-header",
+		EnvVars: []string{"OTLP_HEADERS"},
+		Usage:   "`NAME=VALUE` Http header sent with every OTLP export, may be repeated.",
+	},
+	&cli.StringFlag{
+		Name:    "otlp-service-name",
+		EnvVars: []string{"OTLP_SERVICE_NAME"},
+		Usage:   "service.name resource attribute of the exported Redis instances.",
+		Value:   "redis",
+	},
+	&cli.BoolFlag{
+		Name:    "otlp-semconv",
+		EnvVars: []string{"OTLP_SEMCONV"},
+		Usage:   "export metrics under their OpenTelemetry semantic-convention names, e.g. redis.memory.used.",
+	},
+	&cli.IntFlag{
+		Name:    "otlp-retries",
+		EnvVars: []string{"OTLP_RETRIES"},
+		Usage:   "number of times a failed OTLP export is retried.",
+		Value:   3,
+	},
+	&cli.DurationFlag{
+		Name:    "otlp-retry-backoff",
+		EnvVars: []string{"OTLP_RETRY_BACKOFF"},
+		Usage:   "wait before the first retry of an OTLP export, doubled for every further retry.",
+		Value:   time.Second,
+	},
+	&cli.DurationFlag{
+		Name:    "otlp-timeout",
+		EnvVars: []string{"OTLP_TIMEOUT"},
+		Usage:   "timeout of a single OTLP export request.",
+		Value:   10 * time.Second,
+	},
+	&cli.StringFlag{
+		Name:    "record-dir",
+		EnvVars: []string{"RECORD_DIR"},
+		Usage:   "record the raw INFO output of every collection as a snapshot file below `DIR`.",
+	},
+	&cli.IntFlag{
+		Name:    "record-max-files",
+		EnvVars: []string{"RECORD_MAX_FILES"},
+		Usage:   "number of snapshots kept per target, older ones are deleted, 0 keeps all.",
+		Value:   0,
+	},
+	&cli.StringFlag{
+		Name:    "event-webhook-url",
+		EnvVars: []string{"EVENT_WEBHOOK_URL"},
+		Usage:   "post every detected role change, restart, master change and full sync as JSON to `URL`.",
+	},
+	&cli.IntFlag{
+		Name:    "event-webhook-retries",
+		EnvVars: []string{"EVENT_WEBHOOK_RETRIES"},
+		Usage:   "number of times a failed event post is retried.",
+		Value:   3,
+	},
+	&cli.DurationFlag{
+		Name:    "event-webhook-retry-backoff",
+		EnvVars: []string{"EVENT_WEBHOOK_RETRY_BACKOFF"},
+		Usage:   "wait before the first retry of an event post, doubled for every further retry.",
+		Value:   time.Second,
+	},
+	&cli.DurationFlag{
+		Name:    "event-webhook-timeout",
+		EnvVars: []string{"EVENT_WEBHOOK_TIMEOUT"},
+		Usage:   "timeout of a single event post.",
+		Value:   10 * time.Second,
+	},
+	&cli.StringFlag{
+		Name:    "event-log",
+		EnvVars: []string{"EVENT_LOG"},
+		Usage:   "append every detected event as a line of JSON to `FILE`, - writes to stdout.",
+	},
+	&cli.DurationFlag{
+		Name:    "forecast-window",
+		EnvVars: []string{"FORECAST_WINDOW"},
+		Usage:   "fit the memory and key growth of every target over this window to predict when it runs out of memory, 0 disables forecasting.",
+	},
+	&cli.DurationFlag{
+		Name:    "forecast-horizon",
+		EnvVars: []string{"FORECAST_HORIZON"},
+		Usage:   "time from now the key count of every target is projected to.",
+		Value:   24 * time.Hour,
+	},
+}
+
+var CollectFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:     "target",
+		Aliases:  []string{"t"},
+		Usage:    "collect the Redis server at `HOST:PORT`",
+		Required: true,
+	},
+	&cli.StringFlag{
+		Name:  "name",
+		Usage: "node_name of the collected series, defaults to redis-server-HOST:PORT",
+	},
+	&cli.StringFlag{
+		Name:    "password",
+		Aliases: []string{"a"},
+		EnvVars: []string{"REDIS_PASSWORD"},
+		Usage:   "password used to authenticate to the Redis server",
+	},
+	&cli.StringFlag{
+		Name:    "format",
+		Aliases: []string{"o"},
+		Usage:   "output format: prometheus, json or table",
+		Value:   "prometheus",
+	},
+	&cli.DurationFlag{
+		Name:  "connect-timeout",
+		Usage: "timeout for establishing the connection",
+		Value: 5 * time.Second,
+	},
+	&cli.DurationFlag{
+		Name:  "read-timeout",
+		Usage: "timeout for reading replies",
+		Value: 3 * time.Second,
+	},
+}
+
+var CheckConfigFlags = []cli.Flag{
+	&cli.StringFlag{
+		EnvVars: []string{"CONFIG", "CONFIG_FILE"},
+		Name:    "config",
+		Aliases: []string{"c"},
+		Usage:   "Check the configuration `FILE`",
+		Value:   "/etc/redis-metrics.yaml",
+	},
+	&cli.StringFlag{
+		EnvVars: []string{"WEB_CONFIG", "WEB_CONFIG_FILE"},
+		Name:    "web-config",
+		Usage:   "Also check the web configuration `FILE`",
+	},
+}
+
+var ReplayFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:    "format",
+		Aliases: []string{"o"},
+		Usage:   "output format: prometheus, json or table",
+		Value:   "prometheus",
+	},
+	&cli.BoolFlag{
+		Name:  "every",
+		Usage: "print the series after every snapshot instead of only after the last one",
+	},
+}
+
+var TopologyFlags = []cli.Flag{
+	&cli.StringFlag{
+		EnvVars: []string{"CONFIG", "CONFIG_FILE"},
+		Name:    "config",
+		Aliases: []string{"c"},
+		Usage:   "Load the targets from the configuration `FILE`",
+		Value:   "/etc/redis-metrics.yaml",
+	},
+	&cli.StringFlag{
+		Name:    "format",
+		Aliases: []string{"o"},
+		Usage:   "output format: json or dot",
+		Value:   "json",
+	},
+}
+
+var GenerateRulesFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:    "output",
+		Aliases: []string{"o"},
+		Usage:   "write the rules to `FILE`, - writes to stdout.",
+		Value:   "-",
+	},
+	&cli.DurationFlag{
+		Name:  "window",
+		Usage: "range of the rates and maxima of the recording rules.",
+		Value: 5 * time.Minute,
+	},
+	&cli.DurationFlag{
+		Name:  "for",
+		Usage: "time a condition must hold before an alert fires.",
+		Value: 5 * time.Minute,
+	},
+	&cli.Float64Flag{
+		Name:  "memory-ratio",
+		Usage: "ratio of maxmemory used above which RedisMemoryHigh fires.",
+		Value: 0.9,
+	},
+	&cli.Float64Flag{
+		Name:  "replication-lag-bytes",
+		Usage: "replica lag in bytes above which RedisReplicationLagHigh fires.",
+		Value: 1 << 20,
+	},
+	&cli.Float64Flag{
+		Name:  "eviction-rate",
+		Usage: "evicted keys per second above which RedisEvictions fires.",
+		Value: 0,
+	},
+	&cli.Float64Flag{
+		Name:  "hit-ratio",
+		Usage: "keyspace hit ratio below which RedisHitRatioLow fires, 0 leaves the alert out.",
+		Value: 0,
+	},
+	&cli.DurationFlag{
+		Name:  "master-change-window",
+		Usage: "time RedisMasterChanged fires for after a replica changed its master.",
+		Value: 15 * time.Minute,
+	},
+}
+
+var GenerateDashboardFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:    "output",
+		Aliases: []string{"o"},
+		Usage:   "write the dashboard to `FILE`, - writes to stdout.",
+		Value:   "-",
+	},
+	&cli.StringFlag{
+		Name:  "title",
+		Usage: "title of the dashboard.",
+		Value: "Redis",
+	},
+	&cli.StringFlag{
+		Name:  "uid",
+		Usage: "uid of the dashboard, keep it to overwrite the imported dashboard.",
+		Value: "redis-metrics",
+	},
 }
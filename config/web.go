@@ -0,0 +1,175 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	RouteAuthNone     = "none"
+	RouteAuthRequired = "required"
+)
+
+// WebConfig configures TLS and authentication of the exporter's own Http
+// endpoints. Routes maps a path to RouteAuthNone or RouteAuthRequired,
+// paths not listed use DefaultAuth.
+type WebConfig struct {
+	TLSServerConfig *TLSServerConfig  `yaml:"tls_server_config,omitempty"`
+	BasicAuthUsers  map[string]string `yaml:"basic_auth_users,omitempty"`
+	BearerTokens    []string          `yaml:"bearer_tokens,omitempty"`
+	DefaultAuth     string            `yaml:"default_auth,omitempty"`
+	Routes          map[string]string `yaml:"routes,omitempty"`
+}
+
+type TLSServerConfig struct {
+	CertFile       string `yaml:"cert_file"`
+	KeyFile        string `yaml:"key_file"`
+	ClientAuthType string `yaml:"client_auth_type,omitempty"`
+	ClientCAFile   string `yaml:"client_ca_file,omitempty"`
+	MinVersion     string `yaml:"min_version,omitempty"`
+}
+
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"":                           tls.NoClientCert,
+	"NoClientCert":               tls.NoClientCert,
+	"RequestClientCert":          tls.RequestClientCert,
+	"RequireAnyClientCert":       tls.RequireAnyClientCert,
+	"VerifyClientCertIfGiven":    tls.VerifyClientCertIfGiven,
+	"RequireAndVerifyClientCert": tls.RequireAndVerifyClientCert,
+}
+
+var tlsVersions = map[string]uint16{
+	"":      tls.VersionTLS12,
+	"TLS10": tls.VersionTLS10,
+	"TLS11": tls.VersionTLS11,
+	"TLS12": tls.VersionTLS12,
+	"TLS13": tls.VersionTLS13,
+}
+
+func ParseWebConfig(f []byte) (*WebConfig, error) {
+	var webConfig = WebConfig{}
+	err := yaml.UnmarshalStrict(f, &webConfig)
+	if err != nil {
+		return nil, err
+	}
+	if webConfig.DefaultAuth == "" {
+		webConfig.DefaultAuth = RouteAuthNone
+		if webConfig.HasCredentials() {
+			webConfig.DefaultAuth = RouteAuthRequired
+		}
+	}
+	if err := webConfig.valid(); err != nil {
+		return nil, err
+	}
+	return &webConfig, nil
+}
+
+// HasCredentials reports whether any way of authenticating a request is
+// configured.
+func (w *WebConfig) HasCredentials() bool {
+	return len(w.BasicAuthUsers) > 0 || len(w.BearerTokens) > 0 || w.verifiesClientCert()
+}
+
+// RequireAuth reports whether requests to path must be authenticated.
+func (w *WebConfig) RequireAuth(path string) bool {
+	if w == nil {
+		return false
+	}
+	if auth, ok := w.Routes[path]; ok {
+		return auth == RouteAuthRequired
+	}
+	return w.DefaultAuth == RouteAuthRequired
+}
+
+func (w *WebConfig) verifiesClientCert() bool {
+	if w.TLSServerConfig == nil {
+		return false
+	}
+	switch clientAuthTypes[w.TLSServerConfig.ClientAuthType] {
+	case tls.VerifyClientCertIfGiven, tls.RequireAndVerifyClientCert:
+		return true
+	}
+	return false
+}
+
+func (w *WebConfig) valid() error {
+	for user, hash := range w.BasicAuthUsers {
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return fmt.Errorf("invalid bcrypt hash for user %q: %s", user, err)
+		}
+	}
+	for _, token := range w.BearerTokens {
+		if token == "" {
+			return errors.New("bearer tokens must not be empty")
+		}
+	}
+	auths := map[string]string{"default_auth": w.DefaultAuth}
+	for path, auth := range w.Routes {
+		auths[path] = auth
+	}
+	for route, auth := range auths {
+		switch auth {
+		case RouteAuthNone:
+		case RouteAuthRequired:
+			if !w.HasCredentials() {
+				return fmt.Errorf("%s requires authentication but no credentials are configured", route)
+			}
+		default:
+			return fmt.Errorf("%s: auth must be %q or %q", route, RouteAuthNone, RouteAuthRequired)
+		}
+	}
+	if w.TLSServerConfig != nil {
+		return w.TLSServerConfig.valid()
+	}
+	return nil
+}
+
+func (t *TLSServerConfig) valid() error {
+	if t.CertFile == "" || t.KeyFile == "" {
+		return errors.New("tls_server_config requires cert_file and key_file")
+	}
+	clientAuth, ok := clientAuthTypes[t.ClientAuthType]
+	if !ok {
+		return fmt.Errorf("unknown client_auth_type %q", t.ClientAuthType)
+	}
+	if t.ClientCAFile == "" && (clientAuth == tls.VerifyClientCertIfGiven ||
+		clientAuth == tls.RequireAndVerifyClientCert) {
+		return fmt.Errorf("client_auth_type %s requires client_ca_file", t.ClientAuthType)
+	}
+	if _, ok := tlsVersions[t.MinVersion]; !ok {
+		return fmt.Errorf("unknown min_version %q", t.MinVersion)
+	}
+	return nil
+}
+
+// TLSConfig loads the certificates and returns the server side TLS
+// configuration.
+func (t *TLSServerConfig) TLSConfig() (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   clientAuthTypes[t.ClientAuthType],
+		MinVersion:   tlsVersions[t.MinVersion],
+	}
+	if t.ClientCAFile != "" {
+		ca, err := ioutil.ReadFile(t.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in %s", t.ClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+	}
+	return tlsConfig, nil
+}
@@ -5,8 +5,13 @@ go 1.13
 require (
 	github.com/coreos/go-semver v0.3.0
 	github.com/go-redis/redis/v7 v7.0.0-beta.4
+	github.com/golang/protobuf v1.3.2
+	github.com/golang/snappy v0.0.1
 	github.com/prometheus/client_golang v1.2.1
+	github.com/prometheus/client_model v0.0.0-20190812154241-14fe0d1b01d4
+	github.com/prometheus/common v0.7.0
 	github.com/sirupsen/logrus v1.4.2
 	github.com/urfave/cli/v2 v2.0.0
+	golang.org/x/crypto v0.0.0-20191206172530-e9b2fee46413
 	gopkg.in/yaml.v2 v2.2.7
 )
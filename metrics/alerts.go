@@ -0,0 +1,508 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cwr0401/redis_metrics/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	AlertStatePending  = "pending"
+	AlertStateFiring   = "firing"
+	AlertStateResolved = "resolved"
+
+	OutputAlertWebhook = "alert_webhook"
+	OutputAlertmanager = "alertmanager"
+
+	alertRetryBackoff = time.Second
+	// alertmanagerResolveCycles is the number of collections Alertmanager
+	// keeps a firing alert for without hearing of it again.
+	alertmanagerResolveCycles = 4
+)
+
+// Alert is a rule whose condition holds for a target. Cycles counts the
+// consecutive collections it held in, the alert fires once it reaches the
+// For of the rule.
+type Alert struct {
+	Rule        string     `json:"rule"`
+	Type        string     `json:"type"`
+	NodeName    string     `json:"node_name"`
+	NodeAddress string     `json:"node_address"`
+	Severity    string     `json:"severity"`
+	State       string     `json:"state"`
+	Value       float64    `json:"value"`
+	Summary     string     `json:"summary"`
+	Cycles      int        `json:"cycles"`
+	ActiveAt    time.Time  `json:"active_at"`
+	FiredAt     *time.Time `json:"fired_at,omitempty"`
+	ResolvedAt  *time.Time `json:"resolved_at,omitempty"`
+}
+
+// AlertEvaluator evaluates the alert rules of the configuration after every
+// collection of a target and notifies the configured notifiers of the
+// alerts that fire and resolve.
+type AlertEvaluator struct {
+	Exporter *ExporterCollector
+	// Interval is the collection interval, the firing alerts are sent to
+	// Alertmanager again after every collection.
+	Interval time.Duration
+
+	mu        sync.Mutex
+	rules     []*config.AlertRule
+	notifiers []*config.AlertNotifier
+	alerts    map[string]*Alert
+	evicted   map[string]float64
+	queue     chan alertNotification
+}
+
+type alertNotification struct {
+	notifier *config.AlertNotifier
+	alerts   []Alert
+}
+
+// NewAlertEvaluator returns an AlertEvaluator observing the collections of
+// rm. It must be called before the first rm.Apply.
+func NewAlertEvaluator(rm *RedisMetrics, exporter *ExporterCollector) *AlertEvaluator {
+	e := &AlertEvaluator{
+		Exporter: exporter,
+		Interval: rm.Duration,
+		alerts:   make(map[string]*Alert),
+		evicted:  make(map[string]float64),
+		queue:    make(chan alertNotification, 1024),
+	}
+	rm.AddObserver(e)
+	return e
+}
+
+// Apply replaces the rules and notifiers. The alerts of the rules that are
+// gone are resolved.
+func (e *AlertEvaluator) Apply(alerting config.AlertingConfig) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rules = alerting.Rules
+	e.notifiers = alerting.Notifiers
+	names := make(map[string]bool)
+	for _, rule := range e.rules {
+		names[rule.Name] = true
+	}
+	for key, alert := range e.alerts {
+		if !names[alert.Rule] {
+			e.resolve(key, alert, time.Now())
+		}
+	}
+}
+
+func (e *AlertEvaluator) Collected(result *CollectionResult) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, rule := range e.rules {
+		if !rule.Matches(result.Name) {
+			continue
+		}
+		value, active, ok := e.evaluate(rule, result)
+		if !ok {
+			continue
+		}
+		key := rule.Name + "\x00" + result.Name
+		alert, exists := e.alerts[key]
+		if !active {
+			if exists {
+				e.resolve(key, alert, result.Time)
+			}
+			continue
+		}
+		if !exists {
+			alert = &Alert{
+				Rule:        rule.Name,
+				Type:        rule.Type,
+				NodeName:    result.Name,
+				NodeAddress: result.Addr,
+				Severity:    rule.Severity,
+				State:       AlertStatePending,
+				ActiveAt:    result.Time,
+			}
+			e.alerts[key] = alert
+		}
+		alert.Cycles++
+		alert.Value = value
+		alert.Summary = alertSummary(rule, result.Name, value)
+		switch {
+		case alert.State == AlertStatePending && alert.Cycles >= rule.For:
+			alert.State = AlertStateFiring
+			firedAt := result.Time
+			alert.FiredAt = &firedAt
+			log.WithFields(log.Fields{
+				"node":  alert.NodeName,
+				"addr":  alert.NodeAddress,
+				"alert": alert.Rule,
+			}).Warnf("Alert firing: %s", alert.Summary)
+			e.notify(alert, true)
+		case alert.State == AlertStateFiring:
+			e.notify(alert, false)
+		}
+	}
+	if result.Err == nil {
+		if evicted, ok := result.Info.Section("Stats").Float("evicted_keys"); ok {
+			e.evicted[result.Name] = evicted
+		}
+	}
+}
+
+func (e *AlertEvaluator) Removed(nodeName, nodeAddress string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for key, alert := range e.alerts {
+		if alert.NodeName == nodeName {
+			e.resolve(key, alert, time.Now())
+		}
+	}
+	delete(e.evicted, nodeName)
+}
+
+// evaluate returns the value of the rule for a collection and whether its
+// condition holds, ok is false when the collection cannot tell.
+func (e *AlertEvaluator) evaluate(rule *config.AlertRule, result *CollectionResult) (float64, bool, bool) {
+	if rule.Type == config.AlertRuleDown {
+		if result.Err != nil {
+			return 1, true, true
+		}
+		return 0, false, true
+	}
+	if result.Err != nil {
+		return 0, false, false
+	}
+	i := result.Info
+	switch rule.Type {
+	case config.AlertRuleMemoryUsage:
+		used, hasUsed := i.Section("Memory").Float("used_memory")
+		max, hasMax := i.Section("Memory").Float("maxmemory")
+		if !hasUsed || !hasMax {
+			return 0, false, false
+		}
+		if max == 0 {
+			return 0, false, true
+		}
+		return used / max, used/max > rule.Threshold, true
+	case config.AlertRuleReplicaLinkDown:
+		replication := i.Section("Replication")
+		if replication["role"] != "slave" {
+			return 0, false, true
+		}
+		if replication["master_link_status"] != "up" {
+			return 1, true, true
+		}
+		return 0, false, true
+	case config.AlertRuleBgsaveFailing:
+		status, ok := i.String("Persistence", "rdb_last_bgsave_status")
+		if !ok {
+			return 0, false, false
+		}
+		if status != "ok" {
+			return 1, true, true
+		}
+		return 0, false, true
+	case config.AlertRuleEvictions:
+		evicted, ok := i.Section("Stats").Float("evicted_keys")
+		previous, seen := e.evicted[result.Name]
+		if !ok || !seen {
+			return 0, false, false
+		}
+		delta := evicted - previous
+		if delta < 0 {
+			// The counter was reset by a restart or CONFIG RESETSTAT.
+			delta = evicted
+		}
+		return delta, delta > rule.Threshold, true
+	case config.AlertRuleInfo:
+		section, key := rule.SectionKey()
+		value, ok := i.Section(section).Float(key)
+		if !ok {
+			return 0, false, false
+		}
+		return value, compareAlertValue(value, rule.Op, rule.Threshold), true
+	}
+	return 0, false, false
+}
+
+func compareAlertValue(value float64, op string, threshold float64) bool {
+	switch op {
+	case ">":
+		return value > threshold
+	case ">=":
+		return value >= threshold
+	case "<":
+		return value < threshold
+	case "<=":
+		return value <= threshold
+	case "==":
+		return value == threshold
+	case "!=":
+		return value != threshold
+	}
+	return false
+}
+
+func alertSummary(rule *config.AlertRule, nodeName string, value float64) string {
+	if rule.Summary != "" {
+		return strings.NewReplacer("{{node}}", nodeName, "{{value}}", fmt.Sprintf("%g", value)).
+			Replace(rule.Summary)
+	}
+	switch rule.Type {
+	case config.AlertRuleDown:
+		return fmt.Sprintf("%s is down", nodeName)
+	case config.AlertRuleMemoryUsage:
+		return fmt.Sprintf("%s uses %.0f%% of maxmemory", nodeName, value*100)
+	case config.AlertRuleReplicaLinkDown:
+		return fmt.Sprintf("the link of replica %s to its master is down", nodeName)
+	case config.AlertRuleBgsaveFailing:
+		return fmt.Sprintf("the last RDB save of %s failed", nodeName)
+	case config.AlertRuleEvictions:
+		return fmt.Sprintf("%s evicted %g keys since the last collection", nodeName, value)
+	}
+	return fmt.Sprintf("%s of %s is %g", rule.Field, nodeName, value)
+}
+
+// resolve deletes an alert, notifying its resolution if it fired.
+func (e *AlertEvaluator) resolve(key string, alert *Alert, now time.Time) {
+	delete(e.alerts, key)
+	if alert.State != AlertStateFiring {
+		return
+	}
+	alert.State = AlertStateResolved
+	alert.ResolvedAt = &now
+	log.WithFields(log.Fields{
+		"node":  alert.NodeName,
+		"addr":  alert.NodeAddress,
+		"alert": alert.Rule,
+	}).Infof("Alert resolved: %s", alert.Summary)
+	e.notify(alert, true)
+}
+
+// notify queues the alert for the notifiers. Webhooks only get the changes
+// of state, Alertmanager the firing alerts after every evaluation as well,
+// as it resolves the alerts it stops hearing of.
+func (e *AlertEvaluator) notify(alert *Alert, changed bool) {
+	for _, notifier := range e.notifiers {
+		if !changed && notifier.Type != config.NotifierAlertmanager {
+			continue
+		}
+		select {
+		case e.queue <- alertNotification{notifier: notifier, alerts: []Alert{*alert}}:
+		default:
+			e.Exporter.OutputDropped.WithLabelValues(alertOutput(notifier)).Inc()
+			log.WithFields(log.Fields{
+				"node":  alert.NodeName,
+				"addr":  alert.NodeAddress,
+				"alert": alert.Rule,
+			}).Warn("Alert notification queue is full, dropping notification")
+		}
+	}
+}
+
+// Alerts returns the pending and firing alerts sorted by rule and target.
+func (e *AlertEvaluator) Alerts() []Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	alerts := make([]Alert, 0, len(e.alerts))
+	for _, alert := range e.alerts {
+		alerts = append(alerts, *alert)
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		if alerts[i].Rule != alerts[j].Rule {
+			return alerts[i].Rule < alerts[j].Rule
+		}
+		return alerts[i].NodeName < alerts[j].NodeName
+	})
+	return alerts
+}
+
+// alertsAPI serves /api/v1/alerts, ?state= only returns the alerts in the
+// state.
+func alertsAPI(alerts *AlertEvaluator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeAPI(w, http.StatusMethodNotAllowed, nil, "method not allowed")
+			return
+		}
+		state := r.URL.Query().Get("state")
+		if state != "" && state != AlertStatePending && state != AlertStateFiring {
+			writeAPI(w, http.StatusBadRequest, nil, fmt.Sprintf("unknown state %q", state))
+			return
+		}
+		selected := []Alert{}
+		for _, alert := range alerts.Alerts() {
+			if state == "" || alert.State == state {
+				selected = append(selected, alert)
+			}
+		}
+		writeAPI(w, http.StatusOK, selected, "")
+	}
+}
+
+// Run sends the queued notifications until stop is closed, then the
+// notifications still queued. Once ctx is done the rest are dropped.
+func (e *AlertEvaluator) Run(ctx context.Context, stop <-chan struct{}) {
+	defer e.dropQueued()
+	for ctx.Err() == nil {
+		var n alertNotification
+		select {
+		case <-ctx.Done():
+			return
+		case n = <-e.queue:
+		case <-stop:
+			select {
+			case n = <-e.queue:
+			default:
+				return
+			}
+		}
+		e.send(ctx, n)
+	}
+}
+
+// dropQueued counts the notifications left in the queue by their output.
+func (e *AlertEvaluator) dropQueued() {
+	dropped := make(map[string]int)
+	for len(e.queue) > 0 {
+		dropped[alertOutput((<-e.queue).notifier)]++
+	}
+	for output, n := range dropped {
+		dropQueued(e.Exporter, output, n)
+	}
+}
+
+func (e *AlertEvaluator) send(ctx context.Context, n alertNotification) {
+	output := alertOutput(n.notifier)
+	url := n.notifier.URL
+	var payload interface{}
+	if n.notifier.Type == config.NotifierAlertmanager {
+		url = strings.TrimSuffix(url, "/") + "/api/v2/alerts"
+		// Without endsAt, Alertmanager resolves the alerts after its
+		// resolve_timeout, which can be shorter than the interval.
+		endsAt := time.Now().Add(alertmanagerResolveCycles * e.Interval)
+		payload = alertmanagerAlerts(n.alerts, &endsAt)
+	} else {
+		payload = newAlertWebhookMessage(n.alerts)
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Errorf("Encode alert notification error: %s", err)
+		return
+	}
+	client := &http.Client{Timeout: n.notifier.Timeout}
+	err = retryWithBackoff(ctx, n.notifier.Retries, alertRetryBackoff, func() error {
+		err := postAlerts(ctx, client, url, body)
+		e.Exporter.ObserveOutput(output, err)
+		return err
+	})
+	if err != nil {
+		e.Exporter.OutputDropped.WithLabelValues(output).Inc()
+		log.Errorf("Send alert notification to %s failed: %s", n.notifier.URL, err)
+	}
+}
+
+func alertOutput(notifier *config.AlertNotifier) string {
+	if notifier.Type == config.NotifierAlertmanager {
+		return OutputAlertmanager
+	}
+	return OutputAlertWebhook
+}
+
+func postAlerts(ctx context.Context, client *http.Client, url string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return permanentError{err}
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "redis-metrics")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		io.Copy(ioutil.Discard, resp.Body)
+		return nil
+	}
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 256))
+	err = fmt.Errorf("server returned HTTP status %s: %s", resp.Status, bytes.TrimSpace(msg))
+	if resp.StatusCode/100 == 5 || resp.StatusCode == http.StatusTooManyRequests {
+		return err
+	}
+	return permanentError{err}
+}
+
+// alertmanagerAlert is an alert of the Alertmanager v2 API, also used in
+// the payload of the webhooks.
+type alertmanagerAlert struct {
+	Status      string            `json:"status,omitempty"`
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	StartsAt    time.Time         `json:"startsAt"`
+	EndsAt      *time.Time        `json:"endsAt,omitempty"`
+}
+
+// alertmanagerAlerts converts alerts, the firing ones ending at firingEndsAt
+// when it is not nil.
+func alertmanagerAlerts(alerts []Alert, firingEndsAt *time.Time) []alertmanagerAlert {
+	converted := make([]alertmanagerAlert, 0, len(alerts))
+	for _, alert := range alerts {
+		endsAt := alert.ResolvedAt
+		if endsAt == nil {
+			endsAt = firingEndsAt
+		}
+		converted = append(converted, alertmanagerAlert{
+			Labels: map[string]string{
+				"alertname":    alert.Rule,
+				"node_name":    alert.NodeName,
+				"node_address": alert.NodeAddress,
+				"severity":     alert.Severity,
+			},
+			Annotations: map[string]string{
+				"summary": alert.Summary,
+				"value":   fmt.Sprintf("%g", alert.Value),
+			},
+			StartsAt: *alert.FiredAt,
+			EndsAt:   endsAt,
+		})
+	}
+	return converted
+}
+
+// alertWebhookMessage mirrors the webhook payload of Alertmanager, so that
+// receivers written for it accept the notifications.
+type alertWebhookMessage struct {
+	Version  string              `json:"version"`
+	Status   string              `json:"status"`
+	Receiver string              `json:"receiver"`
+	Alerts   []alertmanagerAlert `json:"alerts"`
+}
+
+func newAlertWebhookMessage(alerts []Alert) alertWebhookMessage {
+	message := alertWebhookMessage{
+		Version:  "4",
+		Status:   AlertStateResolved,
+		Receiver: "redis-metrics",
+		Alerts:   alertmanagerAlerts(alerts, nil),
+	}
+	for i, alert := range alerts {
+		message.Alerts[i].Status = alert.State
+		if alert.State == AlertStateFiring {
+			message.Status = AlertStateFiring
+		}
+	}
+	return message
+}
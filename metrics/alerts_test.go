@@ -0,0 +1,130 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cwr0401/redis_metrics/config"
+	"github.com/cwr0401/redis_metrics/metrics/info"
+)
+
+func TestAlertEvaluator(t *testing.T) {
+	received := make(chan alertWebhookMessage, 10)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var message alertWebhookMessage
+		if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+			t.Error(err)
+		}
+		received <- message
+	}))
+	defer webhook.Close()
+
+	redisConfig, err := config.ParseRedisConfig([]byte(`
+alerting:
+  rules:
+    - name: RedisDown
+      type: down
+      for: 2
+      severity: critical
+    - name: RedisMemory
+      type: memory_usage
+      threshold: 0.9
+  notifiers:
+    - url: ` + webhook.URL + `
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	exporter := NewExporterCollector()
+	rm := NewRedisMetrics(info.NewRedisCollector(), exporter, time.Second)
+	e := NewAlertEvaluator(rm, exporter)
+	e.Apply(redisConfig.Alerting)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go e.Run(ctx, nil)
+
+	down := &CollectionResult{Name: "a", Addr: "127.0.0.1:6379", Time: time.Now(), Err: errors.New("refused"),
+		Info: info.DownInfo(info.RedisServerDownReasonUnreachable)}
+	up := &CollectionResult{Name: "a", Addr: "127.0.0.1:6379", Time: time.Now(),
+		Info: info.ParseInfo("# Memory\r\nused_memory:95\r\nmaxmemory:100\r\n")}
+
+	e.Collected(down)
+	if alerts := e.Alerts(); len(alerts) != 1 || alerts[0].State != AlertStatePending {
+		t.Fatalf("alerts after one down cycle %+v, want RedisDown pending", alerts)
+	}
+	e.Collected(down)
+	if message := <-received; message.Status != AlertStateFiring ||
+		message.Alerts[0].Labels["alertname"] != "RedisDown" {
+		t.Errorf("notification %+v, want RedisDown firing", message)
+	}
+
+	e.Collected(up)
+	if message := <-received; message.Status != AlertStateResolved {
+		t.Errorf("notification %+v, want RedisDown resolved", message)
+	}
+	if message := <-received; message.Status != AlertStateFiring ||
+		message.Alerts[0].Labels["alertname"] != "RedisMemory" {
+		t.Errorf("notification %+v, want RedisMemory firing", message)
+	}
+
+	rec := httptest.NewRecorder()
+	alertsAPI(e).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/alerts?state=firing", nil))
+	var resp struct {
+		Data []Alert `json:"data"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Data) != 1 || resp.Data[0].Rule != "RedisMemory" || resp.Data[0].Value != 0.95 {
+		t.Errorf("firing alerts %+v, want RedisMemory at 0.95", resp.Data)
+	}
+
+	// Dropping the rule resolves its alert.
+	e.Apply(config.AlertingConfig{Notifiers: redisConfig.Alerting.Notifiers})
+	if message := <-received; message.Status != AlertStateResolved {
+		t.Errorf("notification %+v, want RedisMemory resolved", message)
+	}
+	if alerts := e.Alerts(); len(alerts) != 0 {
+		t.Errorf("alerts without rules %+v", alerts)
+	}
+}
+
+func TestAlertmanagerEndsAt(t *testing.T) {
+	var alerts []alertmanagerAlert
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v2/alerts" {
+			t.Errorf("posted to %s", r.URL.Path)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&alerts); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer server.Close()
+
+	exporter := NewExporterCollector()
+	e := NewAlertEvaluator(NewRedisMetrics(info.NewRedisCollector(), exporter, time.Minute), exporter)
+	notifier := &config.AlertNotifier{Type: config.NotifierAlertmanager, URL: server.URL, Timeout: time.Second}
+	firedAt := time.Now().Add(-time.Hour)
+	resolvedAt := time.Now()
+	firing := Alert{Rule: "RedisDown", State: AlertStateFiring, FiredAt: &firedAt}
+	resolved := Alert{Rule: "RedisMemory", State: AlertStateResolved, FiredAt: &firedAt, ResolvedAt: &resolvedAt}
+	before := time.Now()
+	e.send(context.Background(), alertNotification{notifier: notifier, alerts: []Alert{firing, resolved}})
+
+	if len(alerts) != 2 {
+		t.Fatalf("sent %+v, want 2 alerts", alerts)
+	}
+	// Firing alerts outlive a few collections, not Alertmanager's resolve_timeout.
+	if endsAt := alerts[0].EndsAt; endsAt == nil || endsAt.Before(before.Add(4*time.Minute)) ||
+		endsAt.After(time.Now().Add(4*time.Minute)) {
+		t.Errorf("firing alert ends at %v, want 4 collection intervals from now", endsAt)
+	}
+	if endsAt := alerts[1].EndsAt; endsAt == nil || !endsAt.Equal(resolvedAt) {
+		t.Errorf("resolved alert ends at %v, want %v", endsAt, resolvedAt)
+	}
+}
@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/cwr0401/redis_metrics/config"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// authenticator checks the credentials of requests against a WebConfig.
+// Successful basic auth checks are cached because bcrypt is deliberately
+// slow and Prometheus sends the same credentials on every scrape.
+type authenticator struct {
+	web *config.WebConfig
+
+	mu    sync.Mutex
+	valid map[[sha256.Size]byte]bool
+}
+
+func newAuthenticator(web *config.WebConfig) *authenticator {
+	return &authenticator{
+		web:   web,
+		valid: make(map[[sha256.Size]byte]bool),
+	}
+}
+
+// wrap returns handler unchanged when path does not require authentication.
+func (a *authenticator) wrap(path string, handler http.Handler) http.Handler {
+	if !a.web.RequireAuth(path) {
+		return handler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.authenticated(r) {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		log.WithFields(log.Fields{
+			"path":   r.URL.Path,
+			"remote": r.RemoteAddr,
+		}).Warn("Unauthorized request")
+		if len(a.web.BasicAuthUsers) > 0 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="redis-metrics"`)
+		}
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	})
+}
+
+func (a *authenticator) authenticated(r *http.Request) bool {
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		return true
+	}
+	if token := bearerToken(r); token != "" {
+		for _, t := range a.web.BearerTokens {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+				return true
+			}
+		}
+		return false
+	}
+	user, password, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+	hash, ok := a.web.BasicAuthUsers[user]
+	if !ok {
+		return false
+	}
+	key := sha256.Sum256([]byte(user + "\x00" + password + "\x00" + hash))
+	a.mu.Lock()
+	cached := a.valid[key]
+	a.mu.Unlock()
+	if cached {
+		return true
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		return false
+	}
+	a.mu.Lock()
+	a.valid[key] = true
+	a.mu.Unlock()
+	return true
+}
+
+func bearerToken(r *http.Request) string {
+	const prefix = "Bearer "
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, prefix) {
+		return ""
+	}
+	return strings.TrimSpace(auth[len(prefix):])
+}
@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cwr0401/redis_metrics/config"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestAuthenticator(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	web := &config.WebConfig{
+		BasicAuthUsers: map[string]string{"prometheus": string(hash)},
+		BearerTokens:   []string{"token"},
+		DefaultAuth:    config.RouteAuthRequired,
+		Routes:         map[string]string{"/health": config.RouteAuthNone},
+	}
+	auth := newAuthenticator(web)
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	tests := []struct {
+		name    string
+		path    string
+		request func(r *http.Request)
+		code    int
+	}{
+		{"basic auth", "/metrics", func(r *http.Request) { r.SetBasicAuth("prometheus", "secret") }, http.StatusOK},
+		{"wrong password", "/metrics", func(r *http.Request) { r.SetBasicAuth("prometheus", "wrong") }, http.StatusUnauthorized},
+		{"unknown user", "/metrics", func(r *http.Request) { r.SetBasicAuth("nobody", "secret") }, http.StatusUnauthorized},
+		{"bearer token", "/metrics", func(r *http.Request) { r.Header.Set("Authorization", "Bearer token") }, http.StatusOK},
+		{"wrong bearer token", "/metrics", func(r *http.Request) { r.Header.Set("Authorization", "Bearer other") }, http.StatusUnauthorized},
+		{"no credentials", "/metrics", func(r *http.Request) {}, http.StatusUnauthorized},
+		{"client certificate", "/metrics", func(r *http.Request) {
+			r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{&x509.Certificate{}}}}
+		}, http.StatusOK},
+		{"unverified client certificate", "/metrics", func(r *http.Request) {
+			r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{}}}
+		}, http.StatusUnauthorized},
+		{"open route", "/health", func(r *http.Request) {}, http.StatusOK},
+	}
+	for _, test := range tests {
+		// Twice, the second basic auth check is cached.
+		for i := 0; i < 2; i++ {
+			r := httptest.NewRequest(http.MethodGet, test.path, nil)
+			test.request(r)
+			w := httptest.NewRecorder()
+			auth.wrap(test.path, ok).ServeHTTP(w, r)
+			if w.Code != test.code {
+				t.Errorf("%s: status %d, want %d", test.name, w.Code, test.code)
+			}
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	w := httptest.NewRecorder()
+	auth.wrap("/metrics", ok).ServeHTTP(w, r)
+	if w.Header().Get("WWW-Authenticate") == "" {
+		t.Error("no basic auth challenge")
+	}
+}
+
+func TestCheckWebConfigFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "web-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "web.yml")
+	content := "tls_server_config:\n  cert_file: " + filepath.Join(dir, "missing.crt") +
+		"\n  key_file: " + filepath.Join(dir, "missing.key") + "\n"
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadWebConfigFile(path); err != nil {
+		t.Fatalf("the configuration itself is valid: %s", err)
+	}
+	if err := checkWebConfigFile(path); err == nil {
+		t.Error("no error for missing certificate files")
+	}
+}
@@ -2,7 +2,8 @@ package metrics
 
 import (
 	"context"
-	"fmt"
+	"net"
+	"sort"
 	"sync"
 	"time"
 
@@ -12,10 +13,45 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+// RedisMetrics collects every configured Redis instance on its own schedule.
+// Apply changes the set of instances without touching the ones that did not
+// change, so their connections and series survive a configuration reload.
 type RedisMetrics struct {
 	Collector info.RedisCollector
+	Exporter  *ExporterCollector
 	Duration  time.Duration
 	Config    *config.RedisConfig
+
+	mu        sync.Mutex
+	targets   map[string]*redisTarget
+	stopped   bool
+	observers []CollectionObserver
+
+	// endpoints are the resolved addresses of the targets for masterName.
+	// Collections cannot take mu, Apply holds it while it waits for them.
+	endpointsMu sync.RWMutex
+	endpoints   *targetEndpoints
+}
+
+// CollectionResult is the outcome of a single collection from a target. Raw
+// is the unparsed INFO result, empty when INFO failed.
+type CollectionResult struct {
+	Name     string
+	Addr     string
+	Info     *info.Info
+	Raw      string
+	Latency  time.Duration
+	Err      error
+	Time     time.Time
+	Duration time.Duration
+}
+
+// CollectionObserver is notified after every collection, and when a target
+// is removed and its series deleted. Both methods are called from collector
+// goroutines and must not block.
+type CollectionObserver interface {
+	Collected(result *CollectionResult)
+	Removed(nodeName, nodeAddress string)
 }
 
 type RedisClient struct {
@@ -24,54 +60,325 @@ type RedisClient struct {
 	Client *redis.Client
 }
 
-func (r *RedisMetrics) Clients() []*RedisClient {
-	var redisClients []*RedisClient
-	for _, instance := range r.Config.RedisInstances {
-		instanceOptions := instance.RedisOptions()
-		name := instance.Name
-		if instance.Name == "" {
-			name = fmt.Sprintf("redis-server-%s", instanceOptions.Addr)
+type redisTarget struct {
+	instance config.RedisInstance
+	client   *RedisClient
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+func NewRedisMetrics(collector info.RedisCollector, exporter *ExporterCollector,
+	duration time.Duration) *RedisMetrics {
+	r := &RedisMetrics{
+		Collector: collector,
+		Exporter:  exporter,
+		Duration:  duration,
+		targets:   make(map[string]*redisTarget),
+	}
+	collector.SetMasterName(r.masterName)
+	return r
+}
+
+func newRedisClient(instance *config.RedisInstance) *RedisClient {
+	instanceOptions := instance.RedisOptions()
+	return &RedisClient{
+		Name:   instance.InstanceName(),
+		Addr:   instanceOptions.Addr,
+		Client: redis.NewClient(instanceOptions),
+	}
+}
+
+// AddObserver registers o to be notified of collections. It must be called
+// before the first Apply.
+func (r *RedisMetrics) AddObserver(o CollectionObserver) {
+	r.observers = append(r.observers, o)
+}
+
+// Apply starts the instances added to redisConfig, stops the removed ones and
+// restarts the ones whose configuration changed.
+func (r *RedisMetrics) Apply(redisConfig *config.RedisConfig) {
+	wanted := make(map[string]*config.RedisInstance)
+	addresses := make(map[string]string, len(redisConfig.RedisInstances))
+	for _, instance := range redisConfig.RedisInstances {
+		wanted[instance.InstanceName()] = instance
+		addresses[instance.InstanceName()] = instance.RedisOptions().Addr
+	}
+	// Resolved before taking mu so that a slow resolver does not hold up
+	// Targets, and before the new targets start so that their first
+	// collections already match them.
+	endpoints := newTargetEndpoints(addresses)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stopped {
+		return
+	}
+
+	for name, target := range r.targets {
+		instance, ok := wanted[name]
+		if ok && *instance == target.instance {
+			continue
 		}
-		rdb := redis.NewClient(instanceOptions)
-		rc := RedisClient{
-			Name:   name,
-			Addr:   instanceOptions.Addr,
-			Client: rdb,
+		r.stopTarget(target)
+		// A changed instance keeps its series unless they are labelled with
+		// an address it no longer has.
+		if !ok || instance.RedisOptions().Addr != target.client.Addr {
+			r.deleteSeries(target.client)
 		}
-		redisClients = append(redisClients, &rc)
+		delete(r.targets, name)
+		if ok {
+			log.WithFields(log.Fields{
+				"node": name,
+				"addr": target.client.Addr,
+			}).Info("Restart changed Redis target")
+		} else {
+			log.WithFields(log.Fields{
+				"node": name,
+				"addr": target.client.Addr,
+			}).Info("Remove Redis target")
+		}
+	}
+
+	r.setEndpoints(endpoints)
+
+	for _, instance := range redisConfig.RedisInstances {
+		name := instance.InstanceName()
+		if _, ok := r.targets[name]; ok {
+			continue
+		}
+		r.targets[name] = r.startTarget(instance)
 	}
 
-	return redisClients
+	r.Config = redisConfig
+	r.Exporter.SetTargets(len(r.targets), 0)
 }
 
-func (r *RedisMetrics) Run(ctx context.Context, c chan<- struct{}) {
+// Stop stops every target, waits for in-flight collections and closes the
+// Redis clients. The targets are stopped concurrently so that one slow
+// collection does not hold up the others. Apply does nothing after Stop.
+func (r *RedisMetrics) Stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stopped = true
 	wg := sync.WaitGroup{}
-	clients := r.Clients()
+	for name, target := range r.targets {
+		wg.Add(1)
+		go func(target *redisTarget) {
+			r.stopTarget(target)
+			wg.Done()
+		}(target)
+		delete(r.targets, name)
+	}
+	wg.Wait()
+	r.setEndpoints(newTargetEndpoints(nil))
+}
+
+func (r *RedisMetrics) setEndpoints(endpoints *targetEndpoints) {
+	r.endpointsMu.Lock()
+	r.endpoints = endpoints
+	r.endpointsMu.Unlock()
+}
+
+// Endpoints returns the addresses of the targets as resolved by the last
+// Apply.
+func (r *RedisMetrics) Endpoints() *targetEndpoints {
+	r.endpointsMu.RLock()
+	defer r.endpointsMu.RUnlock()
+	return r.endpoints
+}
+
+// masterName returns the name of the target at host:port, or "" when there
+// is none. It is called for every collection of a replica and only looks up
+// the addresses resolved by Apply.
+func (r *RedisMetrics) masterName(host, port string) string {
+	return r.Endpoints().name(net.JoinHostPort(host, port))
+}
+
+// targetEndpoints maps the addresses of the targets, both as configured and
+// with their host resolved to its IP addresses, to the names of the targets.
+// It is built once per configuration so that the master_host and slaveN
+// addresses reported by INFO are matched to targets without a DNS lookup on
+// every collection. It is not modified once built.
+type targetEndpoints struct {
+	names map[string]string
+}
+
+// targetLookupTimeout bounds the lookups of newTargetEndpoints. A target
+// whose host is not resolved in time is only matched by its address as
+// configured.
+var targetLookupTimeout = 5 * time.Second
+
+// newTargetEndpoints resolves the addresses of the targets, keyed by name.
+// The hosts are looked up concurrently so that a slow resolver delays Apply
+// by one lookup rather than one per target.
+func newTargetEndpoints(addresses map[string]string) *targetEndpoints {
+	e := &targetEndpoints{names: make(map[string]string)}
+	names := make([]string, 0, len(addresses))
+	for name := range addresses {
+		names = append(names, name)
+	}
+	// The first target by name wins when two share an address.
+	sort.Strings(names)
+	add := func(addr, name string) {
+		if _, ok := e.names[addr]; !ok {
+			e.names[addr] = name
+		}
+	}
+	for _, name := range names {
+		add(addresses[name], name)
+	}
+
+	// The target each host is looked up for, to log its errors.
+	lookups := make(map[string]string)
+	for _, name := range names {
+		host, _, err := net.SplitHostPort(addresses[name])
+		if err != nil || net.ParseIP(host) != nil {
+			continue
+		}
+		if _, ok := lookups[host]; !ok {
+			lookups[host] = name
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), targetLookupTimeout)
+	defer cancel()
+	var (
+		mu    sync.Mutex
+		wg    sync.WaitGroup
+		hosts = make(map[string][]string)
+	)
+	for host, name := range lookups {
+		wg.Add(1)
+		go func(name, host string) {
+			defer wg.Done()
+			ips, err := net.DefaultResolver.LookupHost(ctx, host)
+			if err != nil {
+				log.WithFields(log.Fields{
+					"node": name,
+					"addr": addresses[name],
+				}).Warnf("Resolve Redis target error: %s", err)
+				return
+			}
+			mu.Lock()
+			hosts[host] = ips
+			mu.Unlock()
+		}(name, host)
+	}
+	wg.Wait()
+	for _, name := range names {
+		host, port, err := net.SplitHostPort(addresses[name])
+		if err != nil {
+			continue
+		}
+		for _, ip := range hosts[host] {
+			add(net.JoinHostPort(ip, port), name)
+		}
+	}
+	return e
+}
+
+// name returns the name of the target at addr, or "" when there is none.
+func (e *targetEndpoints) name(addr string) string {
+	if e == nil {
+		return ""
+	}
+	return e.names[addr]
+}
+
+// same reports whether a and b are the same address or the addresses of the
+// same target.
+func (e *targetEndpoints) same(a, b string) bool {
+	if a == b {
+		return true
+	}
+	name := e.name(a)
+	return name != "" && name == e.name(b)
+}
+
+// Targets returns the clients of the current targets sorted by name.
+func (r *RedisMetrics) Targets() []*RedisClient {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	clients := make([]*RedisClient, 0, len(r.targets))
+	for _, target := range r.targets {
+		clients = append(clients, target.client)
+	}
+	sort.Slice(clients, func(i, j int) bool { return clients[i].Name < clients[j].Name })
+	return clients
+}
+
+func (r *RedisMetrics) startTarget(instance *config.RedisInstance) *redisTarget {
+	ctx, cancel := context.WithCancel(context.Background())
+	target := &redisTarget{
+		instance: *instance,
+		client:   newRedisClient(instance),
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+	log.WithFields(log.Fields{
+		"node": target.client.Name,
+		"addr": target.client.Addr,
+	}).Info("Start Redis target")
+	go r.run(ctx, target)
+	return target
+}
+
+func (r *RedisMetrics) stopTarget(target *redisTarget) {
+	target.cancel()
+	<-target.done
+	if err := target.client.Client.Close(); err != nil {
+		log.WithFields(log.Fields{
+			"node": target.client.Name,
+			"addr": target.client.Addr,
+		}).Errorf("Close Redis client error: %s", err)
+	}
+}
+
+func (r *RedisMetrics) deleteSeries(client *RedisClient) {
+	r.Collector.Delete(client.Name, client.Addr)
+	r.Exporter.Delete(client.Name, client.Addr)
+	for _, o := range r.observers {
+		o.Removed(client.Name, client.Addr)
+	}
+}
+
+func (r *RedisMetrics) run(ctx context.Context, target *redisTarget) {
+	defer close(target.done)
 	for {
+		r.collect(target.client)
 		select {
 		case <-ctx.Done():
-			wg.Wait()
-			for _, client := range clients {
-				client.Client.Close()
-			}
-			c <- struct{}{}
 			return
-		default:
-			for _, client := range clients {
-				wg.Add(1)
-				log.Infof("node=%s, addr=%s", client.Name, client.Addr)
-				go func(client *RedisClient) {
-					infoMap := redisInfoToMetrics(client.Name, client.Addr, client.Client)
-					if err := r.Collector.Set(client.Name, client.Addr, infoMap); err != nil {
-						log.WithFields(log.Fields{
-							"node": client.Name,
-							"addr": client.Addr,
-						}).Errorf("Set Redis metrics error: %s", err)
-					}
-					wg.Done()
-				}(client)
-			}
-			time.Sleep(r.Duration)
+		case <-time.After(r.Duration):
 		}
 	}
 }
+
+func (r *RedisMetrics) collect(client *RedisClient) {
+	log.Infof("node=%s, addr=%s", client.Name, client.Addr)
+	start := time.Now()
+	infoMap, raw, latency, err := redisInfoToMetrics(client.Name, client.Addr, client.Client)
+	r.Exporter.ObserveCollection(client.Name, client.Addr, time.Since(start).Seconds(), err)
+	if err := r.Collector.Set(client.Name, client.Addr, infoMap); err != nil {
+		log.WithFields(log.Fields{
+			"node": client.Name,
+			"addr": client.Addr,
+		}).Errorf("Set Redis metrics error: %s", err)
+	}
+	if latency > 0 {
+		r.Collector.SetPingLatency(client.Name, client.Addr, latency)
+	}
+
+	result := &CollectionResult{
+		Name:     client.Name,
+		Addr:     client.Addr,
+		Info:     infoMap,
+		Raw:      raw,
+		Latency:  latency,
+		Err:      err,
+		Time:     start,
+		Duration: time.Since(start),
+	}
+	for _, o := range r.observers {
+		o.Collected(result)
+	}
+}
@@ -0,0 +1,187 @@
+package metrics
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/cwr0401/redis_metrics/config"
+	"github.com/cwr0401/redis_metrics/metrics/info"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	FormatPrometheus = "prometheus"
+	FormatJSON       = "json"
+	FormatTable      = "table"
+)
+
+// CollectAction runs a single collection from --target, prints the series in
+// the requested format and fails when the collection failed.
+func CollectAction(c *cli.Context) error {
+	// Keep stdout for the output.
+	log.SetOutput(os.Stderr)
+	log.SetLevel(log.WarnLevel)
+	if c.Bool("debug") {
+		log.SetLevel(log.DebugLevel)
+	}
+
+	format := c.String("format")
+	switch format {
+	case FormatPrometheus, FormatJSON, FormatTable:
+	default:
+		return fmt.Errorf("unknown format %q, must be %s, %s or %s", format, FormatPrometheus, FormatJSON, FormatTable)
+	}
+	host, portStr, err := net.SplitHostPort(c.String("target"))
+	if err != nil {
+		return fmt.Errorf("invalid target: %s", err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return fmt.Errorf("invalid target port %q", portStr)
+	}
+	instance := &config.RedisInstance{
+		Name:         c.String("name"),
+		RedisAddress: config.RedisAddress{Host: host, Port: port},
+		Password:     c.String("password"),
+		DialTimeout:  c.Duration("connect-timeout"),
+		ReadTimeout:  c.Duration("read-timeout"),
+	}
+
+	mfs, collectErr := collectOnce(instance)
+	if mfs != nil {
+		if err := writeMetricFamilies(os.Stdout, mfs, format); err != nil {
+			return err
+		}
+	}
+	return collectErr
+}
+
+// collectOnce collects instance into a registry of its own and returns the
+// gathered series, together with the collection error if any.
+func collectOnce(instance *config.RedisInstance) ([]*dto.MetricFamily, error) {
+	client := newRedisClient(instance)
+	defer client.Client.Close()
+
+	registry := prometheus.NewRegistry()
+	rmc := info.NewRedisCollector()
+	rmc.MustRegister(registry)
+
+	infoMap, _, latency, collectErr := redisInfoToMetrics(client.Name, client.Addr, client.Client)
+	if err := rmc.Set(client.Name, client.Addr, infoMap); err != nil && collectErr == nil {
+		collectErr = err
+	}
+	if latency > 0 {
+		rmc.SetPingLatency(client.Name, client.Addr, latency)
+	}
+	mfs, err := registry.Gather()
+	if err != nil {
+		return nil, err
+	}
+	return mfs, collectErr
+}
+
+func writeMetricFamilies(w io.Writer, mfs []*dto.MetricFamily, format string) error {
+	switch format {
+	case FormatJSON:
+		type sample struct {
+			Metric map[string]string `json:"metric"`
+			Value  float64           `json:"value"`
+		}
+		samples := []sample{}
+		for _, ts := range metricFamiliesToSeries(mfs, nil, 0) {
+			metric := make(map[string]string, len(ts.Labels))
+			for _, l := range ts.Labels {
+				metric[l.Name] = l.Value
+			}
+			samples = append(samples, sample{Metric: metric, Value: ts.Samples[0].Value})
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(samples)
+	case FormatTable:
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(tw, "METRIC\tVALUE\tLABELS")
+		for _, ts := range metricFamiliesToSeries(mfs, nil, 0) {
+			var name string
+			var labels []string
+			for _, l := range ts.Labels {
+				switch l.Name {
+				case "__name__":
+					name = l.Value
+				case "node_name", "node_address":
+				default:
+					labels = append(labels, l.Name+"="+l.Value)
+				}
+			}
+			sort.Strings(labels)
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", name,
+				strconv.FormatFloat(ts.Samples[0].Value, 'f', -1, 64), strings.Join(labels, ","))
+		}
+		return tw.Flush()
+	default:
+		for _, mf := range mfs {
+			if _, err := expfmt.MetricFamilyToText(w, mf); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// CheckConfigAction validates the configuration file and, if given, the web
+// configuration file.
+func CheckConfigAction(c *cli.Context) error {
+	log.SetOutput(os.Stderr)
+	log.SetLevel(log.WarnLevel)
+
+	var failed bool
+	redisConfig, err := loadConfigFile(c.String("config"))
+	if err != nil {
+		fmt.Printf("FAILED: %s: %s\n", c.String("config"), err)
+		failed = true
+	} else {
+		fmt.Printf("SUCCESS: %s: %d Redis instances\n", c.String("config"), len(redisConfig.RedisInstances))
+		for _, instance := range redisConfig.RedisInstances {
+			fmt.Printf("  %s %s\n", instance.InstanceName(), instance.RedisOptions().Addr)
+		}
+	}
+	if path := c.String("web-config"); path != "" {
+		if err := checkWebConfigFile(path); err != nil {
+			fmt.Printf("FAILED: %s: %s\n", path, err)
+			failed = true
+		} else {
+			fmt.Printf("SUCCESS: %s\n", path)
+		}
+	}
+	if failed {
+		return errors.New("configuration check failed")
+	}
+	return nil
+}
+
+// checkWebConfigFile loads the web configuration file and the certificates
+// it names, as the server does when it starts.
+func checkWebConfigFile(path string) error {
+	webConfig, err := loadWebConfigFile(path)
+	if err != nil {
+		return err
+	}
+	if webConfig.TLSServerConfig != nil {
+		if _, err := webConfig.TLSServerConfig.TLSConfig(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
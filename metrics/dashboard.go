@@ -0,0 +1,280 @@
+package metrics
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cwr0401/redis_metrics/metrics/info"
+	log "github.com/sirupsen/logrus"
+)
+
+// dashboardTarget is a row of the dashboard's target table.
+type dashboardTarget struct {
+	TargetStatus
+	UsedMemory     string
+	MaxMemory      string
+	MemoryPercent  string
+	OpsPerSec      string
+	ReplicationLag string
+}
+
+// dashboardTopology is the replication graph grouped by master.
+type dashboardTopology struct {
+	Anomalies []TopologyAnomaly
+	Groups    []*topologyGroup
+}
+
+// topologyGroup is a master with its replicas. Name is empty when the master
+// is not one of the targets.
+type topologyGroup struct {
+	Name      string
+	Address   string
+	Sentinels []string
+	Replicas  []topologyReplica
+}
+
+type topologyReplica struct {
+	Name    string
+	Address string
+	State   string
+	Offset  string
+	Lag     string
+}
+
+var dashboardFuncs = template.FuncMap{
+	"since": func(t *time.Time) string {
+		if t == nil {
+			return "never"
+		}
+		return time.Since(*t).Truncate(time.Second).String() + " ago"
+	},
+}
+
+var dashboardLayout = template.Must(template.New("layout").Funcs(dashboardFuncs).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>redis-metrics</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; vertical-align: top; }
+th { background: #f0f0f0; }
+.up { color: #1a7f37; font-weight: bold; }
+.down { color: #cf222e; font-weight: bold; }
+.unknown { color: #888; }
+.error { color: #cf222e; }
+nav a { margin-right: 1em; }
+</style>
+</head>
+<body>
+<nav><a href="/">Targets</a><a href="/topology">Topology</a><a href="/metrics">Metrics</a></nav>
+{{template "content" .}}
+</body>
+</html>
+`))
+
+// dashboardPages are the layout with the content of each page.
+var dashboardPages = map[string]*template.Template{
+	"targets": dashboardPage(`{{define "content"}}
+<h1>Targets</h1>
+<table>
+<tr><th>Name</th><th>Address</th><th>State</th><th>Role</th><th>Version</th><th>Memory</th><th>Ops/sec</th><th>Replication lag</th><th>Last collection</th><th>Last error</th></tr>
+{{range .}}<tr>
+<td><a href="/targets/{{.Name}}">{{.Name}}</a></td>
+<td>{{.Address}}</td>
+<td class="{{.Health}}">{{.Health}}</td>
+<td>{{.Role}}</td>
+<td>{{.Version}}</td>
+<td>{{.UsedMemory}}{{if .MaxMemory}} / {{.MaxMemory}} ({{.MemoryPercent}}){{end}}</td>
+<td>{{.OpsPerSec}}</td>
+<td>{{.ReplicationLag}}</td>
+<td>{{since .LastCollection}}</td>
+<td class="error">{{.LastError}}</td>
+</tr>{{else}}<tr><td colspan="10">No targets configured.</td></tr>{{end}}
+</table>
+{{end}}`),
+	"target": dashboardPage(`{{define "content"}}
+<h1>{{.Name}} <small>{{.Address}}</small></h1>
+<p>INFO collected at {{.Time.Format "2006-01-02 15:04:05 MST"}}</p>
+{{range $section, $fields := .Sections}}
+<h2>{{if $section}}{{$section}}{{else}}Other{{end}}</h2>
+<table>
+{{range $key, $value := $fields}}<tr><th>{{$key}}</th><td>{{$value}}</td></tr>
+{{end}}</table>
+{{end}}
+{{end}}`),
+	"topology": dashboardPage(`{{define "content"}}
+<h1>Replication topology</h1>
+{{if .Anomalies}}<h2>Anomalies</h2>
+<table>
+<tr><th>Node</th><th>Anomaly</th><th>Details</th></tr>
+{{range .Anomalies}}<tr><td>{{.Node}}</td><td class="error">{{.Kind}}</td><td>{{.Message}}</td></tr>
+{{end}}</table>
+{{end}}{{range .Groups}}
+<h2>{{if .Name}}<a href="/targets/{{.Name}}">{{.Name}}</a> {{.Address}}{{else}}{{.Address}} <small>(not a target)</small>{{end}}</h2>
+{{with .Sentinels}}<p>Monitored by {{range $i, $s := .}}{{if $i}}, {{end}}{{$s}}{{end}}</p>
+{{end}}<table>
+<tr><th>Replica</th><th>Address</th><th>State</th><th>Offset</th><th>Lag</th></tr>
+{{range .Replicas}}<tr>
+<td>{{if .Name}}<a href="/targets/{{.Name}}">{{.Name}}</a>{{else}}-{{end}}</td>
+<td>{{.Address}}</td><td>{{.State}}</td><td>{{.Offset}}</td><td>{{.Lag}}</td>
+</tr>{{else}}<tr><td colspan="5">No replicas.</td></tr>{{end}}
+</table>
+{{else}}<p>No masters found.</p>{{end}}
+{{end}}`),
+}
+
+func dashboardPage(content string) *template.Template {
+	return template.Must(template.Must(dashboardLayout.Clone()).Parse(content))
+}
+
+// dashboard serves the HTML status pages: the target table at /, the INFO of
+// a target at /targets/{name} and the replication topology at /topology.
+func dashboard(statuses *TargetStatuses) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var (
+			name string
+			data interface{}
+		)
+		switch {
+		case r.URL.Path == "/":
+			name, data = "targets", dashboardTargets(statuses)
+		case r.URL.Path == "/topology":
+			name, data = "topology", newDashboardTopology(
+				buildTopology(statuses.topologyTargets(), statuses.Metrics.Endpoints()))
+		case strings.HasPrefix(r.URL.Path, "/targets/"):
+			info, ok := statuses.Info(strings.TrimPrefix(r.URL.Path, "/targets/"))
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			name, data = "target", info
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := dashboardPages[name].Execute(w, data); err != nil {
+			log.Errorf("Render dashboard error: %s", err)
+		}
+	}
+}
+
+func dashboardTargets(statuses *TargetStatuses) []dashboardTarget {
+	var targets []dashboardTarget
+	for _, status := range statuses.Targets() {
+		target := dashboardTarget{TargetStatus: status}
+		if i, ok := statuses.lastInfo(status.Name); ok {
+			info := i.Flat()
+			used, _ := strconv.ParseFloat(info["used_memory"], 64)
+			target.UsedMemory = humanBytes(used)
+			if max, _ := strconv.ParseFloat(info["maxmemory"], 64); max > 0 {
+				target.MaxMemory = humanBytes(max)
+				target.MemoryPercent = fmt.Sprintf("%.1f%%", used/max*100)
+			}
+			target.OpsPerSec = info["instantaneous_ops_per_sec"]
+			target.ReplicationLag = replicationLag(info, i.Replicas)
+		}
+		targets = append(targets, target)
+	}
+	return targets
+}
+
+// replicationLag describes how far a replica is behind its master, or the
+// largest lag of the replicas of a master.
+func replicationLag(info map[string]string, replicas []info.Replica) string {
+	if info["role"] == "slave" {
+		if info["master_link_status"] != "up" {
+			return "link " + info["master_link_status"]
+		}
+		return info["master_last_io_seconds_ago"] + "s since last I/O"
+	}
+	maxLag := int64(-1)
+	for _, replica := range replicas {
+		if replica.Lag > maxLag {
+			maxLag = replica.Lag
+		}
+	}
+	if maxLag < 0 {
+		return ""
+	}
+	return fmt.Sprintf("max %ds", maxLag)
+}
+
+// newDashboardTopology groups the replication edges of t by master, the
+// masters in the order of t.Nodes.
+func newDashboardTopology(t *Topology) *dashboardTopology {
+	nodes := make(map[string]*TopologyNode, len(t.Nodes))
+	for _, n := range t.Nodes {
+		nodes[n.ID] = n
+	}
+	groups := make(map[string]*topologyGroup)
+	d := &dashboardTopology{Anomalies: t.Anomalies}
+	group := func(n *TopologyNode) *topologyGroup {
+		if g, ok := groups[n.ID]; ok {
+			return g
+		}
+		g := &topologyGroup{Name: n.Name, Address: n.ID}
+		groups[n.ID] = g
+		d.Groups = append(d.Groups, g)
+		return g
+	}
+	for _, n := range t.Nodes {
+		if n.Role == TopologyRoleMaster {
+			group(n)
+		}
+	}
+	for _, e := range t.Edges {
+		switch e.Kind {
+		case TopologyEdgeReplication:
+			n := nodes[e.To]
+			replica := topologyReplica{Name: n.Name, Address: n.ID, State: e.State}
+			if n.Offset != nil {
+				replica.Offset = strconv.FormatInt(*n.Offset, 10)
+			}
+			if e.LagBytes != nil {
+				replica.Lag = humanBytes(float64(*e.LagBytes))
+			}
+			g := group(nodes[e.From])
+			g.Replicas = append(g.Replicas, replica)
+		case TopologyEdgeMonitors:
+			sentinel := nodes[e.From]
+			name := sentinel.ID
+			if sentinel.Name != "" {
+				name = sentinel.Name
+			}
+			g := group(nodes[e.To])
+			g.Sentinels = append(g.Sentinels, name)
+		}
+	}
+	return d
+}
+
+func humanBytes(b float64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%.0fB", b)
+	}
+	div, exp := float64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", b/div, "KMGTPE"[exp])
+}
+
+// lastInfo returns the INFO of the last successful collection.
+func (s *TargetStatuses) lastInfo(name string) (*info.Info, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	up, ok := s.lastUp[name]
+	if !ok {
+		return nil, false
+	}
+	return up.Info, true
+}
@@ -0,0 +1,92 @@
+package metrics
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"syscall"
+
+	"github.com/cwr0401/redis_metrics/metrics/info"
+)
+
+const (
+	ErrorClassDial    = "dial"
+	ErrorClassAuth    = "auth"
+	ErrorClassTimeout = "timeout"
+	ErrorClassParse   = "parse"
+	ErrorClassOther   = "other"
+)
+
+var errInfoParse = errors.New("no fields found in 'info all' reply")
+
+// classifyError maps an error returned while collecting from a Redis server
+// to one of the ErrorClass values.
+func classifyError(err error) string {
+	if err == nil {
+		return ""
+	}
+	if errors.Is(err, errInfoParse) {
+		return ErrorClassParse
+	}
+	if isAuthError(err) {
+		return ErrorClassAuth
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrorClassTimeout
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return ErrorClassDial
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return ErrorClassDial
+	}
+	return ErrorClassOther
+}
+
+// isAuthError reports whether err is a Redis reply rejecting the credentials.
+func isAuthError(err error) bool {
+	msg := err.Error()
+	return strings.HasPrefix(msg, "NOAUTH") ||
+		strings.HasPrefix(msg, "WRONGPASS") ||
+		strings.HasPrefix(msg, "ERR invalid password") ||
+		strings.HasPrefix(msg, "ERR Client sent AUTH")
+}
+
+// downReason maps an error returned by PING or INFO to the reason reported
+// by redis_server_up_reason.
+func downReason(err error) string {
+	if err == nil {
+		return info.RedisServerDownReasonNone
+	}
+	if isAuthError(err) {
+		return info.RedisServerDownReasonAuth
+	}
+	msg := err.Error()
+	switch {
+	case strings.HasPrefix(msg, "LOADING"):
+		return info.RedisServerDownReasonLoading
+	case strings.HasPrefix(msg, "MASTERDOWN"):
+		return info.RedisServerDownReasonMasterDown
+	case strings.HasPrefix(msg, "BUSY"):
+		return info.RedisServerDownReasonBusy
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return info.RedisServerDownReasonDNS
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return info.RedisServerDownReasonTimeout
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return info.RedisServerDownReasonConnectionRefused
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return info.RedisServerDownReasonUnreachable
+	}
+	return info.RedisServerDownReasonUnknown
+}
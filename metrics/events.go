@@ -0,0 +1,346 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cwr0401/redis_metrics/metrics/info"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	EventRoleChange   = "role_change"
+	EventRestart      = "restart"
+	EventMasterChange = "master_change"
+	EventFullSync     = "full_sync"
+
+	OutputEventWebhook = "event_webhook"
+)
+
+// Event is a change of a target detected between two of its collections.
+// From and To are the values before and after the change: the roles, the
+// run_ids, the master addresses, or the sync_full counts of a master.
+type Event struct {
+	Type        string    `json:"type"`
+	NodeName    string    `json:"node_name"`
+	NodeAddress string    `json:"node_address"`
+	Time        time.Time `json:"time"`
+	From        string    `json:"from,omitempty"`
+	To          string    `json:"to,omitempty"`
+	Message     string    `json:"message"`
+}
+
+// EventOutput receives the detected events. Event is called from collector
+// goroutines and must not block.
+type EventOutput interface {
+	Event(event *Event)
+}
+
+// EventDetector keeps the last state of every target and turns the changes
+// between two successful collections into events: role changes, restarts
+// (a new run_id or an uptime going backwards), changes of master and full
+// syncs, seen on a replica starting one or on a master serving them.
+type EventDetector struct {
+	Events    *prometheus.CounterVec
+	LastEvent *prometheus.GaugeVec
+	// Collector tells the changes of master, counted by
+	// redis_replication_master_changes_total, so that both agree.
+	Collector info.RedisCollector
+
+	mu      sync.Mutex
+	states  map[string]eventState
+	outputs []EventOutput
+}
+
+type eventState struct {
+	address    string
+	role       string
+	runID      string
+	uptime     float64
+	master     string
+	masterFrom string
+	syncing    bool
+	syncFull   float64
+}
+
+// NewEventDetector returns an EventDetector observing the collections of rm.
+// It must be called before the first rm.Apply.
+func NewEventDetector(rm *RedisMetrics) *EventDetector {
+	var (
+		// events
+		eventsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "redis",
+			Subsystem: "events",
+			Name:      "total",
+			Help:      "Number of role changes, restarts, master changes and full syncs detected. The master changes are those of redis_replication_master_changes_total.",
+		},
+			[]string{"node_name", "node_address", "event"})
+
+		// last event
+		eventsLastTimestamp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "redis",
+			Subsystem: "events",
+			Name:      "last_timestamp_seconds",
+			Help:      "Time of the collection the last event of the type was detected in, since the Unix epoch.",
+		},
+			[]string{"node_name", "node_address", "event"})
+	)
+	d := &EventDetector{
+		Events:    eventsTotal,
+		LastEvent: eventsLastTimestamp,
+		Collector: rm.Collector,
+		states:    make(map[string]eventState),
+	}
+	rm.AddObserver(d)
+	return d
+}
+
+func (d *EventDetector) MustRegister(registry *prometheus.Registry) {
+	registry.MustRegister(d.Events)
+	registry.MustRegister(d.LastEvent)
+}
+
+// AddOutput registers o to receive the events. It must be called before the
+// first rm.Apply.
+func (d *EventDetector) AddOutput(o EventOutput) {
+	d.outputs = append(d.outputs, o)
+}
+
+func (d *EventDetector) Collected(result *CollectionResult) {
+	if result.Err != nil {
+		return
+	}
+	current := newEventState(result.Addr, result.Info)
+	current.masterFrom, _ = d.Collector.MasterChangedFrom(result.Name, result.Addr)
+	d.mu.Lock()
+	previous, seen := d.states[result.Name]
+	d.states[result.Name] = current
+	d.mu.Unlock()
+	if !seen || previous.address != current.address {
+		return
+	}
+	for _, event := range detectEvents(previous, current) {
+		event.NodeName = result.Name
+		event.NodeAddress = result.Addr
+		event.Time = result.Time
+		d.emit(event)
+	}
+}
+
+func (d *EventDetector) Removed(nodeName, nodeAddress string) {
+	d.mu.Lock()
+	delete(d.states, nodeName)
+	d.mu.Unlock()
+	labels := prometheus.Labels{"node_name": nodeName, "node_address": nodeAddress}
+	for _, event := range []string{EventRoleChange, EventRestart, EventMasterChange, EventFullSync} {
+		labels["event"] = event
+		d.Events.Delete(labels)
+		d.LastEvent.Delete(labels)
+	}
+}
+
+func newEventState(address string, i *info.Info) eventState {
+	replication := i.Section("Replication")
+	server := i.Section("Server")
+	state := eventState{
+		address: address,
+		role:    replication["role"],
+		runID:   server["run_id"],
+	}
+	state.uptime, _ = server.Float("uptime_in_seconds")
+	if state.role == "slave" {
+		state.master = net.JoinHostPort(replication["master_host"], replication["master_port"])
+		syncing, _ := replication.Float("master_sync_in_progress")
+		state.syncing = syncing == 1
+	}
+	state.syncFull, _ = i.Section("Stats").Float("sync_full")
+	return state
+}
+
+// detectEvents returns the events between two states of a target, without
+// their node and time.
+func detectEvents(previous, current eventState) []*Event {
+	var events []*Event
+	restarted := false
+	switch {
+	case previous.runID != "" && current.runID != "" && previous.runID != current.runID:
+		restarted = true
+		events = append(events, &Event{Type: EventRestart, From: previous.runID, To: current.runID,
+			Message: "run_id changed"})
+	case current.uptime < previous.uptime:
+		restarted = true
+		events = append(events, &Event{Type: EventRestart, From: previous.runID, To: current.runID,
+			Message: fmt.Sprintf("uptime went back from %gs to %gs", previous.uptime, current.uptime)})
+	}
+	if previous.role != "" && current.role != "" && previous.role != current.role {
+		events = append(events, &Event{Type: EventRoleChange, From: previous.role, To: current.role,
+			Message: fmt.Sprintf("role changed from %s to %s", previous.role, current.role)})
+	}
+	if current.masterFrom != "" {
+		events = append(events, &Event{Type: EventMasterChange, From: current.masterFrom, To: current.master,
+			Message: fmt.Sprintf("master changed from %s to %s", current.masterFrom, current.master)})
+	}
+	if current.syncing && !previous.syncing {
+		events = append(events, &Event{Type: EventFullSync, To: current.master,
+			Message: fmt.Sprintf("full sync from %s started", current.master)})
+	}
+	// A restart resets sync_full.
+	if !restarted && current.syncFull > previous.syncFull {
+		events = append(events, &Event{Type: EventFullSync,
+			From:    fmt.Sprintf("%g", previous.syncFull),
+			To:      fmt.Sprintf("%g", current.syncFull),
+			Message: fmt.Sprintf("served %g full syncs", current.syncFull-previous.syncFull)})
+	}
+	return events
+}
+
+func (d *EventDetector) emit(event *Event) {
+	d.Events.WithLabelValues(event.NodeName, event.NodeAddress, event.Type).Inc()
+	d.LastEvent.WithLabelValues(event.NodeName, event.NodeAddress, event.Type).Set(
+		float64(event.Time.UnixNano()) / 1e9)
+	log.WithFields(log.Fields{
+		"node":  event.NodeName,
+		"addr":  event.NodeAddress,
+		"event": event.Type,
+		"from":  event.From,
+		"to":    event.To,
+	}).Warn(event.Message)
+	for _, o := range d.outputs {
+		o.Event(event)
+	}
+}
+
+// EventLog writes every event as a line of JSON to W.
+type EventLog struct {
+	W io.Writer
+
+	mu sync.Mutex
+}
+
+func NewEventLog(w io.Writer) *EventLog {
+	return &EventLog{W: w}
+}
+
+func (l *EventLog) Event(event *Event) {
+	b, err := json.Marshal(event)
+	if err != nil {
+		log.Errorf("Encode event error: %s", err)
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.W.Write(append(b, '\n')); err != nil {
+		log.Errorf("Write event log error: %s", err)
+	}
+}
+
+// EventWebhook posts every event as JSON to URL.
+type EventWebhook struct {
+	URL      string
+	Exporter *ExporterCollector
+	Retries  int
+	Backoff  time.Duration
+
+	client *http.Client
+	queue  chan *Event
+}
+
+func NewEventWebhook(url string, exporter *ExporterCollector, retries int, backoff, timeout time.Duration) *EventWebhook {
+	return &EventWebhook{
+		URL:      url,
+		Exporter: exporter,
+		Retries:  retries,
+		Backoff:  backoff,
+		client:   &http.Client{Timeout: timeout},
+		queue:    make(chan *Event, 1024),
+	}
+}
+
+func (w *EventWebhook) Event(event *Event) {
+	select {
+	case w.queue <- event:
+	default:
+		w.Exporter.OutputDropped.WithLabelValues(OutputEventWebhook).Inc()
+		log.WithFields(log.Fields{
+			"node": event.NodeName,
+			"addr": event.NodeAddress,
+		}).Warn("Event webhook queue is full, dropping event")
+	}
+}
+
+// Run sends the queued events until stop is closed, then the events still
+// queued. Once ctx is done the rest are dropped.
+func (w *EventWebhook) Run(ctx context.Context, stop <-chan struct{}) {
+	log.Infof("Sending events to %s", w.URL)
+	defer func() { dropQueued(w.Exporter, OutputEventWebhook, len(w.queue)) }()
+	for ctx.Err() == nil {
+		var event *Event
+		select {
+		case <-ctx.Done():
+			return
+		case event = <-w.queue:
+		case <-stop:
+			select {
+			case event = <-w.queue:
+			default:
+				return
+			}
+		}
+		w.send(ctx, event)
+	}
+}
+
+func (w *EventWebhook) send(ctx context.Context, event *Event) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		log.Errorf("Encode event error: %s", err)
+		return
+	}
+	err = retryWithBackoff(ctx, w.Retries, w.Backoff, func() error {
+		err := w.post(ctx, body)
+		w.Exporter.ObserveOutput(OutputEventWebhook, err)
+		return err
+	})
+	if err != nil {
+		w.Exporter.OutputDropped.WithLabelValues(OutputEventWebhook).Inc()
+		log.WithFields(log.Fields{
+			"node": event.NodeName,
+			"addr": event.NodeAddress,
+		}).Errorf("Send event to webhook failed: %s", err)
+	}
+}
+
+func (w *EventWebhook) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return permanentError{err}
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "redis-metrics")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		io.Copy(ioutil.Discard, resp.Body)
+		return nil
+	}
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 256))
+	err = fmt.Errorf("server returned HTTP status %s: %s", resp.Status, bytes.TrimSpace(msg))
+	if resp.StatusCode/100 == 5 || resp.StatusCode == http.StatusTooManyRequests {
+		return err
+	}
+	return permanentError{err}
+}
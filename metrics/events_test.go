@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/cwr0401/redis_metrics/metrics/info"
+)
+
+func TestEventDetector(t *testing.T) {
+	rmc := info.NewRedisCollector()
+	rm := NewRedisMetrics(rmc, NewExporterCollector(), time.Second)
+	d := NewEventDetector(rm)
+	var buf bytes.Buffer
+	d.AddOutput(NewEventLog(&buf))
+
+	// Collected runs after the collector is set, as in a live collection.
+	collect := func(raw string) {
+		result := &CollectionResult{Name: "r", Addr: "127.0.0.1:6380", Time: time.Now(), Info: info.ParseInfo(raw)}
+		rmc.Set(result.Name, result.Addr, result.Info)
+		d.Collected(result)
+	}
+	replica := func(runID, uptime, master, syncing string) string {
+		return "# Server\r\nrun_id:" + runID + "\r\nuptime_in_seconds:" + uptime + "\r\n" +
+			"# Replication\r\nrole:slave\r\nmaster_host:" + master + "\r\nmaster_port:6379\r\n" +
+			"master_sync_in_progress:" + syncing + "\r\n"
+	}
+	collect(replica("a", "100", "10.0.0.1", "0"))
+	collect(replica("a", "160", "10.0.0.1", "0"))
+	if buf.Len() != 0 {
+		t.Fatalf("events without a change: %s", buf.String())
+	}
+	// Failover: the replica follows a new master and resyncs from it.
+	collect(replica("a", "220", "10.0.0.2", "1"))
+	// Restart as a master.
+	collect("# Server\r\nrun_id:b\r\nuptime_in_seconds:5\r\n# Replication\r\nrole:master\r\n")
+	collect("# Server\r\nrun_id:b\r\nuptime_in_seconds:65\r\n# Replication\r\nrole:master\r\n")
+	// Back as the replica of another master.
+	collect(replica("b", "125", "10.0.0.3", "0"))
+
+	var got []string
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var event Event
+		if err := dec.Decode(&event); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, event.Type+" "+event.From+">"+event.To)
+	}
+	want := []string{
+		"master_change 10.0.0.1:6379>10.0.0.2:6379",
+		"full_sync >10.0.0.2:6379",
+		"restart a>b",
+		"role_change slave>master",
+		"role_change master>slave",
+		"master_change 10.0.0.2:6379>10.0.0.3:6379",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("events %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("event %d %q, want %q", i, got[i], want[i])
+		}
+	}
+	if n := seriesCount(d.Events); n != 4 {
+		t.Errorf("%d event series, want 4", n)
+	}
+	changes := counterValue(rmc["Replication"].(*info.RedisReplicationCollector).MasterChanges.WithLabelValues("r", "127.0.0.1:6380"))
+	if n := counterValue(d.Events.WithLabelValues("r", "127.0.0.1:6380", EventMasterChange)); n != 2 || n != changes {
+		t.Errorf("%v master_change events and %v master changes, want 2 of both", n, changes)
+	}
+	d.Removed("r", "127.0.0.1:6380")
+	if n := seriesCount(d.Events); n != 0 {
+		t.Errorf("%d event series left after removal", n)
+	}
+}
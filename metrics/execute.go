@@ -1,30 +1,38 @@
 package metrics
 
 import (
+	"time"
+
+	"github.com/cwr0401/redis_metrics/metrics/info"
 	"github.com/go-redis/redis/v7"
 	log "github.com/sirupsen/logrus"
 )
 
-func redisInfoToMetrics(nodeName, nodeAddress string, rdb *redis.Client) map[string]string {
+// redisInfoToMetrics pings the server and fetches 'info all'. It returns the
+// parsed and the raw INFO result. The latency is the PING round-trip time and
+// is zero when PING failed.
+func redisInfoToMetrics(nodeName, nodeAddress string, rdb *redis.Client) (*info.Info, string, time.Duration, error) {
 	log.WithFields(log.Fields{
 		"node": nodeName,
 		"addr": nodeAddress,
 	}).Info("Get Redis Server Info Metrics")
 
+	start := time.Now()
 	err := rdb.Ping().Err()
 	if err != nil {
+		reason := downReason(err)
 		log.WithFields(log.Fields{
-			"node": nodeName,
-			"addr": nodeAddress,
+			"node":   nodeName,
+			"addr":   nodeAddress,
+			"reason": reason,
 		}).Error("Redis Server is down.")
 		log.WithFields(log.Fields{
 			"node": nodeName,
 			"addr": nodeAddress,
 		}).Error(err)
-		return map[string]string{
-			"down": "",
-		}
+		return info.DownInfo(reason), "", 0, err
 	}
+	latency := time.Since(start)
 
 	redisInfo, err := rdb.Info("all").Result()
 
@@ -33,10 +41,15 @@ func redisInfoToMetrics(nodeName, nodeAddress string, rdb *redis.Client) map[str
 			"node": nodeName,
 			"addr": nodeAddress,
 		}).Errorf("Execute command 'info all' failed: %s", err)
-		return map[string]string{
-			"down": "",
-		}
+		return info.DownInfo(downReason(err)), "", latency, err
+	}
+	redisInfoMap := info.ParseInfo(redisInfo)
+	if len(redisInfoMap.Sections) == 0 {
+		log.WithFields(log.Fields{
+			"node": nodeName,
+			"addr": nodeAddress,
+		}).Error(errInfoParse)
+		return redisInfoMap, redisInfo, latency, errInfoParse
 	}
-	redisInfoMap := RedisInfoResultParser(redisInfo)
-	return redisInfoMap
+	return redisInfoMap, redisInfo, latency, nil
 }
@@ -0,0 +1,227 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	TargetSourceConfigured = "configured"
+	TargetSourceDiscovered = "discovered"
+)
+
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+)
+
+// ExporterCollector holds the metrics describing the exporter itself rather
+// than the Redis servers it scrapes.
+type ExporterCollector struct {
+	CollectionDuration     *prometheus.HistogramVec
+	LastCollectionSuccess  *prometheus.GaugeVec
+	CollectionErrors       *prometheus.CounterVec
+	Targets                *prometheus.GaugeVec
+	ConfigReloads          *prometheus.CounterVec
+	ConfigReloadTriggers   *prometheus.CounterVec
+	ConfigLastReload       prometheus.Gauge
+	ConfigLastReloadStatus prometheus.Gauge
+	OutputRequests         *prometheus.CounterVec
+	OutputDropped          *prometheus.CounterVec
+}
+
+func NewExporterCollector() *ExporterCollector {
+	var (
+		// collection duration
+		exporterCollectionDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "redis_metrics",
+			Subsystem: "collector",
+			Name:      "duration_seconds",
+			Help:      "Time spent collecting metrics from a Redis server.",
+			Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 20},
+		},
+			[]string{"node_name", "node_address"})
+
+		// last successful collection
+		exporterLastCollectionSuccess = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "redis_metrics",
+			Subsystem: "collector",
+			Name:      "last_success_timestamp_seconds",
+			Help:      "Unix timestamp of the last successful collection from a Redis server.",
+		},
+			[]string{"node_name", "node_address"})
+
+		// collection errors
+		exporterCollectionErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "redis_metrics",
+			Subsystem: "collector",
+			Name:      "errors_total",
+			Help:      "Number of failed collections by error class (dial, auth, timeout, parse, other).",
+		},
+			[]string{"node_name", "node_address", "class"})
+
+		// targets
+		exporterTargets = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "redis_metrics",
+			Name:      "targets",
+			Help:      "Number of Redis targets by source (configured, discovered).",
+		},
+			[]string{"source"})
+
+		// config reloads
+		exporterConfigReloads = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "redis_metrics",
+			Subsystem: "config",
+			Name:      "reloads_total",
+			Help:      "Number of configuration reloads by result (success, failure).",
+		},
+			[]string{"result"})
+
+		// config reload triggers
+		exporterConfigReloadTriggers = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "redis_metrics",
+			Subsystem: "config",
+			Name:      "reload_triggers_total",
+			Help:      "Number of configuration reload requests by trigger (http, signal, file).",
+		},
+			[]string{"trigger"})
+
+		// last config reload
+		exporterConfigLastReload = prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "redis_metrics",
+			Subsystem: "config",
+			Name:      "last_reload_timestamp_seconds",
+			Help:      "Unix timestamp of the last configuration reload attempt.",
+		})
+
+		// last config reload status
+		exporterConfigLastReloadStatus = prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "redis_metrics",
+			Subsystem: "config",
+			Name:      "last_reload_successful",
+			Help:      "Value is 1 if the last configuration reload succeeded, 0 otherwise.",
+		})
+
+		// output requests
+		exporterOutputRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "redis_metrics",
+			Subsystem: "output",
+			Name:      "requests_total",
+			Help:      "Number of requests sent to an output by result (success, failure), retries included.",
+		},
+			[]string{"output", "result"})
+
+		// output dropped
+		exporterOutputDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "redis_metrics",
+			Subsystem: "output",
+			Name:      "dropped_total",
+			Help:      "Number of updates an output dropped because its queue was full or retries were exhausted.",
+		},
+			[]string{"output"})
+	)
+	return &ExporterCollector{
+		exporterCollectionDuration,
+		exporterLastCollectionSuccess,
+		exporterCollectionErrors,
+		exporterTargets,
+		exporterConfigReloads,
+		exporterConfigReloadTriggers,
+		exporterConfigLastReload,
+		exporterConfigLastReloadStatus,
+		exporterOutputRequests,
+		exporterOutputDropped,
+	}
+}
+
+func (m *ExporterCollector) MustRegister(registry *prometheus.Registry) {
+	registry.MustRegister(m.CollectionDuration)
+	registry.MustRegister(m.LastCollectionSuccess)
+	registry.MustRegister(m.CollectionErrors)
+	registry.MustRegister(m.Targets)
+	registry.MustRegister(m.ConfigReloads)
+	registry.MustRegister(m.ConfigReloadTriggers)
+	registry.MustRegister(m.ConfigLastReload)
+	registry.MustRegister(m.ConfigLastReloadStatus)
+	registry.MustRegister(m.OutputRequests)
+	registry.MustRegister(m.OutputDropped)
+}
+
+func (m *ExporterCollector) Unregister(registry *prometheus.Registry) bool {
+	if !registry.Unregister(m.CollectionDuration) {
+		return false
+	}
+	if !registry.Unregister(m.LastCollectionSuccess) {
+		return false
+	}
+	if !registry.Unregister(m.CollectionErrors) {
+		return false
+	}
+	if !registry.Unregister(m.Targets) {
+		return false
+	}
+	if !registry.Unregister(m.ConfigReloads) {
+		return false
+	}
+	if !registry.Unregister(m.ConfigReloadTriggers) {
+		return false
+	}
+	if !registry.Unregister(m.ConfigLastReload) {
+		return false
+	}
+	if !registry.Unregister(m.ConfigLastReloadStatus) {
+		return false
+	}
+	if !registry.Unregister(m.OutputRequests) {
+		return false
+	}
+	if !registry.Unregister(m.OutputDropped) {
+		return false
+	}
+	return true
+}
+
+// Delete removes the series of a target that is no longer collected.
+func (m *ExporterCollector) Delete(nodeName, nodeAddress string) {
+	m.CollectionDuration.DeleteLabelValues(nodeName, nodeAddress)
+	m.LastCollectionSuccess.DeleteLabelValues(nodeName, nodeAddress)
+	for _, class := range []string{
+		ErrorClassDial, ErrorClassAuth, ErrorClassTimeout, ErrorClassParse, ErrorClassOther,
+	} {
+		m.CollectionErrors.DeleteLabelValues(nodeName, nodeAddress, class)
+	}
+}
+
+// ObserveCollection records the outcome of a single collection from a target.
+func (m *ExporterCollector) ObserveCollection(nodeName, nodeAddress string, seconds float64, err error) {
+	m.CollectionDuration.WithLabelValues(nodeName, nodeAddress).Observe(seconds)
+	if err != nil {
+		m.CollectionErrors.WithLabelValues(nodeName, nodeAddress, classifyError(err)).Inc()
+		return
+	}
+	m.LastCollectionSuccess.WithLabelValues(nodeName, nodeAddress).SetToCurrentTime()
+}
+
+func (m *ExporterCollector) SetTargets(configured, discovered int) {
+	m.Targets.WithLabelValues(TargetSourceConfigured).Set(float64(configured))
+	m.Targets.WithLabelValues(TargetSourceDiscovered).Set(float64(discovered))
+}
+
+func (m *ExporterCollector) ObserveReload(err error) {
+	m.ConfigLastReload.SetToCurrentTime()
+	if err != nil {
+		m.ConfigReloads.WithLabelValues(ResultFailure).Inc()
+		m.ConfigLastReloadStatus.Set(0)
+		return
+	}
+	m.ConfigReloads.WithLabelValues(ResultSuccess).Inc()
+	m.ConfigLastReloadStatus.Set(1)
+}
+
+// ObserveOutput records the result of a request sent to an output.
+func (m *ExporterCollector) ObserveOutput(output string, err error) {
+	if err != nil {
+		m.OutputRequests.WithLabelValues(output, ResultFailure).Inc()
+		return
+	}
+	m.OutputRequests.WithLabelValues(output, ResultSuccess).Inc()
+}
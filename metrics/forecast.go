@@ -0,0 +1,316 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// forecastMinSamples is the number of samples a trend is fitted from at the
+// least.
+const forecastMinSamples = 3
+
+// ForecastCollector keeps the used_memory, used_memory_dataset and key
+// count of every target over Window and fits a linear trend to them, to
+// predict when a target reaches its maxmemory or the memory of its system
+// and how many keys it holds Horizon from now. What happens at maxmemory
+// depends on maxmemory_policy: with noeviction writes start failing, which
+// TimeToMaxmemory predicts, any other policy starts evicting keys, which
+// TimeToEviction predicts instead. Since Redis cannot grow past maxmemory
+// either way, the memory of the system is only predicted for targets
+// without a lower maxmemory and the projected key count is capped at the
+// keys the dataset can hold below maxmemory. A restart, seen from run_id or
+// uptime_in_seconds, starts a new window.
+type ForecastCollector struct {
+	MemoryGrowth       *prometheus.GaugeVec
+	DatasetGrowth      *prometheus.GaugeVec
+	TimeToMaxmemory    *prometheus.GaugeVec
+	TimeToEviction     *prometheus.GaugeVec
+	TimeToSystemMemory *prometheus.GaugeVec
+	MaxmemoryEvicts    *prometheus.GaugeVec
+	ProjectedKeys      *prometheus.GaugeVec
+	Samples            *prometheus.GaugeVec
+
+	Window  time.Duration
+	Horizon time.Duration
+
+	mu      sync.Mutex
+	targets map[string]*forecastTarget
+}
+
+type forecastTarget struct {
+	address string
+	samples []forecastSample
+}
+
+type forecastSample struct {
+	time         time.Time
+	usedMemory   float64
+	dataset      float64
+	keys         float64
+	maxmemory    float64
+	systemMemory float64
+	policy       string
+	runID        string
+	uptime       float64
+}
+
+// NewForecastCollector returns a ForecastCollector observing the
+// collections of rm. It must be called before the first rm.Apply.
+func NewForecastCollector(rm *RedisMetrics, window, horizon time.Duration) *ForecastCollector {
+	labels := []string{"node_name", "node_address"}
+	var (
+		// used_memory trend
+		forecastMemoryGrowth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "redis",
+			Subsystem: "forecast",
+			Name:      "memory_growth_bytes_per_second",
+			Help:      "Growth of used_memory fitted over the forecast window.",
+		}, labels)
+
+		// used_memory_dataset trend
+		forecastDatasetGrowth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "redis",
+			Subsystem: "forecast",
+			Name:      "dataset_growth_bytes_per_second",
+			Help:      "Growth of used_memory_dataset fitted over the forecast window.",
+		}, labels)
+
+		// maxmemory with noeviction
+		forecastTimeToMaxmemory = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "redis",
+			Subsystem: "forecast",
+			Name:      "time_to_maxmemory_seconds",
+			Help:      "Predicted seconds until used_memory reaches maxmemory and writes fail, 0 once it did. Absent without maxmemory or growth, or with an evicting maxmemory_policy.",
+		}, labels)
+
+		// maxmemory with an evicting policy
+		forecastTimeToEviction = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "redis",
+			Subsystem: "forecast",
+			Name:      "time_to_eviction_seconds",
+			Help:      "Predicted seconds until used_memory reaches maxmemory and keys get evicted, 0 once it did. Absent without maxmemory or growth, or with noeviction.",
+		}, labels)
+
+		// total_system_memory
+		forecastTimeToSystemMemory = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "redis",
+			Subsystem: "forecast",
+			Name:      "time_to_system_memory_seconds",
+			Help:      "Predicted seconds until used_memory reaches total_system_memory. Absent when maxmemory is lower or without growth.",
+		}, labels)
+
+		// maxmemory_policy
+		forecastMaxmemoryEvicts = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "redis",
+			Subsystem: "forecast",
+			Name:      "maxmemory_evicts",
+			Help:      "Value is 1 if the maxmemory_policy evicts keys at maxmemory, 0 if writes fail instead.",
+		}, labels)
+
+		// keys at the horizon
+		forecastProjectedKeys = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "redis",
+			Subsystem: "forecast",
+			Name:      "projected_keys",
+			Help:      "Predicted number of keys at the forecast horizon, capped at the keys that fit below maxmemory.",
+		}, labels)
+
+		// samples
+		forecastSamples = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "redis",
+			Subsystem: "forecast",
+			Name:      "samples",
+			Help:      "Number of collections in the forecast window.",
+		}, labels)
+	)
+	c := &ForecastCollector{
+		MemoryGrowth:       forecastMemoryGrowth,
+		DatasetGrowth:      forecastDatasetGrowth,
+		TimeToMaxmemory:    forecastTimeToMaxmemory,
+		TimeToEviction:     forecastTimeToEviction,
+		TimeToSystemMemory: forecastTimeToSystemMemory,
+		MaxmemoryEvicts:    forecastMaxmemoryEvicts,
+		ProjectedKeys:      forecastProjectedKeys,
+		Samples:            forecastSamples,
+		Window:             window,
+		Horizon:            horizon,
+		targets:            make(map[string]*forecastTarget),
+	}
+	rm.AddObserver(c)
+	return c
+}
+
+func (m *ForecastCollector) MustRegister(registry *prometheus.Registry) {
+	registry.MustRegister(m.MemoryGrowth)
+	registry.MustRegister(m.DatasetGrowth)
+	registry.MustRegister(m.TimeToMaxmemory)
+	registry.MustRegister(m.TimeToEviction)
+	registry.MustRegister(m.TimeToSystemMemory)
+	registry.MustRegister(m.MaxmemoryEvicts)
+	registry.MustRegister(m.ProjectedKeys)
+	registry.MustRegister(m.Samples)
+}
+
+func (m *ForecastCollector) Collected(result *CollectionResult) {
+	if result.Err != nil {
+		return
+	}
+	memory := result.Info.Section("Memory")
+	sample := forecastSample{time: result.Time, policy: memory["maxmemory_policy"]}
+	var ok bool
+	if sample.usedMemory, ok = memory.Float("used_memory"); !ok {
+		return
+	}
+	sample.dataset, _ = memory.Float("used_memory_dataset")
+	sample.maxmemory, _ = memory.Float("maxmemory")
+	sample.systemMemory, _ = memory.Float("total_system_memory")
+	for _, db := range result.Info.Keyspace {
+		sample.keys += float64(db.Keys)
+	}
+	server := result.Info.Section("Server")
+	sample.runID = server["run_id"]
+	sample.uptime, _ = server.Float("uptime_in_seconds")
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	target, ok := m.targets[result.Name]
+	if !ok || target.address != result.Addr {
+		if ok {
+			m.delete(result.Name, target.address)
+		}
+		target = &forecastTarget{address: result.Addr}
+		m.targets[result.Name] = target
+	}
+	if n := len(target.samples); n > 0 && restarted(target.samples[n-1], sample) {
+		target.samples = target.samples[:0]
+	}
+	target.samples = append(target.samples, sample)
+	start := 0
+	for start < len(target.samples) && result.Time.Sub(target.samples[start].time) > m.Window {
+		start++
+	}
+	target.samples = target.samples[start:]
+	m.update(result.Name, target)
+}
+
+func (m *ForecastCollector) Removed(nodeName, nodeAddress string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.targets, nodeName)
+	m.delete(nodeName, nodeAddress)
+}
+
+func (m *ForecastCollector) delete(nodeName, nodeAddress string) {
+	m.deletePredictions(nodeName, nodeAddress)
+	m.MaxmemoryEvicts.DeleteLabelValues(nodeName, nodeAddress)
+	m.Samples.DeleteLabelValues(nodeName, nodeAddress)
+}
+
+func (m *ForecastCollector) deletePredictions(nodeName, nodeAddress string) {
+	for _, vec := range []*prometheus.GaugeVec{m.MemoryGrowth, m.DatasetGrowth, m.TimeToMaxmemory,
+		m.TimeToEviction, m.TimeToSystemMemory, m.ProjectedKeys} {
+		vec.DeleteLabelValues(nodeName, nodeAddress)
+	}
+}
+
+// update sets the forecast of a target from its samples.
+func (m *ForecastCollector) update(nodeName string, target *forecastTarget) {
+	samples := target.samples
+	last := samples[len(samples)-1]
+	m.Samples.WithLabelValues(nodeName, target.address).Set(float64(len(samples)))
+	evicts := 0.0
+	if last.policy != "" && last.policy != "noeviction" {
+		evicts = 1
+	}
+	m.MaxmemoryEvicts.WithLabelValues(nodeName, target.address).Set(evicts)
+
+	memoryGrowth, okMemory := fitSlope(samples, func(s forecastSample) float64 { return s.usedMemory })
+	datasetGrowth, okDataset := fitSlope(samples, func(s forecastSample) float64 { return s.dataset })
+	keysGrowth, okKeys := fitSlope(samples, func(s forecastSample) float64 { return s.keys })
+	if !okMemory || !okDataset || !okKeys {
+		m.deletePredictions(nodeName, target.address)
+		return
+	}
+	m.MemoryGrowth.WithLabelValues(nodeName, target.address).Set(memoryGrowth)
+	m.DatasetGrowth.WithLabelValues(nodeName, target.address).Set(datasetGrowth)
+
+	// Only one of the two applies, depending on the policy.
+	reached, other := m.TimeToMaxmemory, m.TimeToEviction
+	if evicts == 1 {
+		reached, other = m.TimeToEviction, m.TimeToMaxmemory
+	}
+	other.DeleteLabelValues(nodeName, target.address)
+	switch {
+	case last.maxmemory > 0 && last.usedMemory >= last.maxmemory:
+		reached.WithLabelValues(nodeName, target.address).Set(0)
+	case last.maxmemory > 0 && memoryGrowth > 0:
+		reached.WithLabelValues(nodeName, target.address).Set(
+			(last.maxmemory - last.usedMemory) / memoryGrowth)
+	default:
+		reached.DeleteLabelValues(nodeName, target.address)
+	}
+
+	capped := last.maxmemory > 0 && last.maxmemory < last.systemMemory
+	if last.systemMemory > 0 && !capped && memoryGrowth > 0 {
+		remaining := last.systemMemory - last.usedMemory
+		if remaining < 0 {
+			remaining = 0
+		}
+		m.TimeToSystemMemory.WithLabelValues(nodeName, target.address).Set(remaining / memoryGrowth)
+	} else {
+		m.TimeToSystemMemory.DeleteLabelValues(nodeName, target.address)
+	}
+
+	keys := last.keys + keysGrowth*m.Horizon.Seconds()
+	if keys < 0 {
+		keys = 0
+	}
+	// The dataset holds the keys, the rest of used_memory is overhead that
+	// does not grow with them.
+	if last.maxmemory > 0 && last.dataset > 0 && last.keys > 0 {
+		capacity := (last.maxmemory - (last.usedMemory - last.dataset)) / (last.dataset / last.keys)
+		if capacity < last.keys {
+			capacity = last.keys
+		}
+		if keys > capacity {
+			keys = capacity
+		}
+	}
+	m.ProjectedKeys.WithLabelValues(nodeName, target.address).Set(keys)
+}
+
+// restarted reports whether the server restarted between two samples, which
+// resets its memory and, without persistence, its keys.
+func restarted(previous, current forecastSample) bool {
+	if previous.runID != "" && current.runID != "" {
+		return previous.runID != current.runID
+	}
+	return current.uptime < previous.uptime
+}
+
+// fitSlope returns the slope per second of the least squares line through
+// the values of the samples, false with too few samples or no time between
+// them.
+func fitSlope(samples []forecastSample, value func(forecastSample) float64) (float64, bool) {
+	if len(samples) < forecastMinSamples {
+		return 0, false
+	}
+	t0 := samples[0].time
+	var sumX, sumY, sumXY, sumXX float64
+	for _, s := range samples {
+		x := s.time.Sub(t0).Seconds()
+		y := value(s)
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+	n := float64(len(samples))
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		return 0, false
+	}
+	return (n*sumXY - sumX*sumY) / denominator, true
+}
@@ -0,0 +1,84 @@
+package metrics
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cwr0401/redis_metrics/metrics/info"
+)
+
+func TestForecastCollector(t *testing.T) {
+	rm := NewRedisMetrics(info.NewRedisCollector(), NewExporterCollector(), time.Second)
+	c := NewForecastCollector(rm, 10*time.Minute, time.Hour)
+	start := time.Now()
+	runID := "r1"
+	collect := func(minute int, policy string) {
+		// 1000 bytes and 10 keys a minute, 100 bytes a key, 1000 bytes of
+		// overhead.
+		used := 10000 + 1000*minute
+		raw := fmt.Sprintf("# Server\r\nrun_id:%s\r\nuptime_in_seconds:%d\r\n"+
+			"# Memory\r\nused_memory:%d\r\nused_memory_dataset:%d\r\nmaxmemory:40000\r\n"+
+			"total_system_memory:100000\r\nmaxmemory_policy:%s\r\n# Keyspace\r\ndb0:keys=%d,expires=0,avg_ttl=0\r\n",
+			runID, minute*60, used, used-1000, policy, (used-1000)/100)
+		c.Collected(&CollectionResult{Name: "a", Addr: "127.0.0.1:6379",
+			Time: start.Add(time.Duration(minute) * time.Minute), Info: info.ParseInfo(raw)})
+	}
+
+	collect(0, "noeviction")
+	if n := seriesCount(c.TimeToMaxmemory); n != 0 {
+		t.Errorf("%d predictions from one sample", n)
+	}
+	for minute := 1; minute <= 20; minute++ {
+		collect(minute, "allkeys-lru")
+	}
+	node := []string{"a", "127.0.0.1:6379"}
+	if v := gaugeValue(c.Samples.WithLabelValues(node...)); v != 11 {
+		t.Errorf("%v samples, want the 11 in the window", v)
+	}
+	// 30000 bytes at 20 minutes, 10000 bytes to go at 1000 bytes a minute,
+	// when allkeys-lru starts evicting.
+	if v := gaugeValue(c.TimeToEviction.WithLabelValues(node...)); v < 599 || v > 601 {
+		t.Errorf("time to eviction %v, want 600", v)
+	}
+	if n := seriesCount(c.TimeToMaxmemory); n != 0 {
+		t.Error("writes predicted to fail with allkeys-lru")
+	}
+	if v := gaugeValue(c.DatasetGrowth.WithLabelValues(node...)); v < 16.66 || v > 16.67 {
+		t.Errorf("dataset growth %v, want 1000 bytes a minute", v)
+	}
+	if n := seriesCount(c.TimeToSystemMemory); n != 0 {
+		t.Error("time to system memory predicted below maxmemory")
+	}
+	// 290 keys and 10 a minute would be 890 in an hour, but only 390 fit.
+	if v := gaugeValue(c.ProjectedKeys.WithLabelValues(node...)); v != 390 {
+		t.Errorf("projected keys %v, want 390", v)
+	}
+	if v := gaugeValue(c.MaxmemoryEvicts.WithLabelValues(node...)); v != 1 {
+		t.Errorf("maxmemory evicts %v with allkeys-lru", v)
+	}
+
+	// With noeviction writes fail instead.
+	collect(21, "noeviction")
+	if v := gaugeValue(c.TimeToMaxmemory.WithLabelValues(node...)); v < 539 || v > 541 {
+		t.Errorf("time to maxmemory %v, want 540", v)
+	}
+	if n := seriesCount(c.TimeToEviction); n != 0 {
+		t.Error("eviction predicted with noeviction")
+	}
+
+	// A restart starts a new window.
+	runID = "r2"
+	collect(22, "noeviction")
+	if v := gaugeValue(c.Samples.WithLabelValues(node...)); v != 1 {
+		t.Errorf("%v samples after a restart, want 1", v)
+	}
+	if n := seriesCount(c.MemoryGrowth); n != 0 {
+		t.Error("growth fitted across a restart")
+	}
+
+	c.Removed("a", "127.0.0.1:6379")
+	if n := seriesCount(c.Samples); n != 0 {
+		t.Errorf("%d series left after removal", n)
+	}
+}
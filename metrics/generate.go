@@ -0,0 +1,193 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/cwr0401/redis_metrics/metrics/info"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	MetricTypeGauge   = "gauge"
+	MetricTypeCounter = "counter"
+)
+
+// metricDefinition is a metric the exporter defines, read from the
+// collectors so that generated rules and dashboards follow their changes.
+// Section is the INFO section of its collector, or Exporter for the
+// exporter's own metrics.
+type metricDefinition struct {
+	Name    string
+	Help    string
+	Type    string
+	Labels  []string
+	Section string
+}
+
+var (
+	metricNamePattern = regexp.MustCompile(`\bredis_[a-z0-9_]+\b`)
+	recordNamePattern = regexp.MustCompile(`\bredis:[a-z0-9_:]+`)
+)
+
+// metricDefinitions returns the metrics of every collector of the exporter
+// keyed by name.
+func metricDefinitions() (map[string]metricDefinition, error) {
+	exporter := NewExporterCollector()
+	rm := NewRedisMetrics(info.NewRedisCollector(), exporter, time.Second)
+	collectors := map[interface{}]string{
+		exporter:                    "Exporter",
+		NewReplicationCollector(rm): "Replication",
+		NewEventDetector(rm):        "Events",
+		NewForecastCollector(rm, time.Hour, time.Hour): "Forecast",
+	}
+	for section, collector := range rm.Collector {
+		collectors[collector] = section
+	}
+
+	definitions := make(map[string]metricDefinition)
+	for collector, section := range collectors {
+		v := reflect.Indirect(reflect.ValueOf(collector))
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Field(i)
+			if field.Kind() != reflect.Ptr || field.IsNil() || !field.CanInterface() {
+				continue
+			}
+			c, ok := field.Interface().(prometheus.Collector)
+			if !ok {
+				continue
+			}
+			metricType := MetricTypeGauge
+			if _, ok := c.(*prometheus.CounterVec); ok {
+				metricType = MetricTypeCounter
+			}
+			descs := make(chan *prometheus.Desc, 16)
+			go func() {
+				c.Describe(descs)
+				close(descs)
+			}()
+			for desc := range descs {
+				definition, err := describeMetric(desc)
+				if err != nil {
+					return nil, err
+				}
+				definition.Type = metricType
+				definition.Section = section
+				definitions[definition.Name] = definition
+			}
+		}
+	}
+	return definitions, nil
+}
+
+// maxMetricLabels bounds the number of variable labels describeMetric tries.
+const maxMetricLabels = 16
+
+// describeMetric returns the name, help and variable labels of desc. A Desc
+// has no accessors, so they are read back from a constant metric built from
+// it and gathered by a registry. NewConstMetric only accepts as many label
+// values as desc has variable labels, and each value is the index of its
+// label to keep their declaration order.
+func describeMetric(desc *prometheus.Desc) (metricDefinition, error) {
+	var err error
+	for n := 0; n <= maxMetricLabels; n++ {
+		values := make([]string, n)
+		for i := range values {
+			values[i] = strconv.Itoa(i)
+		}
+		var metric prometheus.Metric
+		metric, err = prometheus.NewConstMetric(desc, prometheus.UntypedValue, 0, values...)
+		if err != nil {
+			continue
+		}
+		registry := prometheus.NewRegistry()
+		if err := registry.Register(constCollector{desc, metric}); err != nil {
+			return metricDefinition{}, err
+		}
+		mfs, err := registry.Gather()
+		if err != nil {
+			return metricDefinition{}, err
+		}
+		if len(mfs) != 1 || len(mfs[0].GetMetric()) != 1 {
+			return metricDefinition{}, fmt.Errorf("cannot gather %s", desc)
+		}
+		labels := make([]string, n)
+		for _, label := range mfs[0].GetMetric()[0].GetLabel() {
+			if i, err := strconv.Atoi(label.GetValue()); err == nil && i < n && labels[i] == "" {
+				labels[i] = label.GetName()
+			}
+		}
+		return metricDefinition{Name: mfs[0].GetName(), Help: mfs[0].GetHelp(), Labels: labels}, nil
+	}
+	return metricDefinition{}, fmt.Errorf("cannot describe %s: %s", desc, err)
+}
+
+// constCollector collects a single constant metric.
+type constCollector struct {
+	desc   *prometheus.Desc
+	metric prometheus.Metric
+}
+
+func (c constCollector) Describe(ch chan<- *prometheus.Desc) { ch <- c.desc }
+
+func (c constCollector) Collect(ch chan<- prometheus.Metric) { ch <- c.metric }
+
+// checkExpr fails when expr uses a metric the exporter does not define or a
+// recording rule not in recorded, or groups by a label one of its metrics
+// lacks.
+func checkExpr(definitions map[string]metricDefinition, recorded map[string]bool, expr string,
+	labels ...string) error {
+	names := metricNamePattern.FindAllString(expr, -1)
+	records := recordNamePattern.FindAllString(expr, -1)
+	if len(names) == 0 && len(records) == 0 {
+		return fmt.Errorf("%s uses no metric of the exporter", expr)
+	}
+	for _, name := range names {
+		definition, ok := definitions[name]
+		if !ok {
+			return fmt.Errorf("%s uses the unknown metric %s", expr, name)
+		}
+		for _, label := range labels {
+			if !stringsContain(definition.Labels, label) {
+				return fmt.Errorf("%s uses the label %s that %s does not have", expr, label, name)
+			}
+		}
+	}
+	for _, record := range records {
+		if !recorded[record] {
+			return fmt.Errorf("%s uses the unknown recording rule %s", expr, record)
+		}
+	}
+	return nil
+}
+
+func stringsContain(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// writeOutput writes the output of a generate command to path, or to
+// stdout when path is - or empty.
+func writeOutput(path string, write func(w io.Writer) error) error {
+	if path == "" || path == "-" {
+		return write(os.Stdout)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
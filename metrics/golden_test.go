@@ -0,0 +1,165 @@
+package metrics
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cwr0401/redis_metrics/config"
+	"github.com/cwr0401/redis_metrics/metrics/info"
+	"github.com/cwr0401/redis_metrics/metrics/internal/fakeredis"
+	dto "github.com/prometheus/client_model/go"
+	log "github.com/sirupsen/logrus"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func init() {
+	log.SetOutput(ioutil.Discard)
+}
+
+// goldenAddress replaces the random address of the fake server in the
+// golden files.
+const goldenAddress = "fakeredis:6379"
+
+func fakeInstance(server *fakeredis.Server) *config.RedisInstance {
+	return &config.RedisInstance{
+		Name:         "fake-" + server.Version,
+		RedisAddress: config.RedisAddress{Host: server.Host(), Port: server.Port()},
+		DialTimeout:  time.Second,
+		ReadTimeout:  time.Second,
+	}
+}
+
+func TestCollectGolden(t *testing.T) {
+	versions, err := fakeredis.Versions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, version := range versions {
+		version := version
+		t.Run(version, func(t *testing.T) {
+			server, err := fakeredis.Start(version)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer server.Close()
+
+			mfs, err := collectOnce(fakeInstance(server))
+			if err != nil {
+				t.Fatalf("collect: %s", err)
+			}
+			// The PING latency differs on every run.
+			var stable []*dto.MetricFamily
+			for _, mf := range mfs {
+				if mf.GetName() != "redis_server_ping_latency_seconds" {
+					stable = append(stable, mf)
+				}
+			}
+			var buf bytes.Buffer
+			if err := writeMetricFamilies(&buf, stable, FormatPrometheus); err != nil {
+				t.Fatal(err)
+			}
+			got := strings.Replace(buf.String(), server.Addr(), goldenAddress, -1)
+
+			golden := filepath.Join("testdata", "golden", "redis-"+version+".prom")
+			if *update {
+				if err := ioutil.WriteFile(golden, []byte(got), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := ioutil.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != string(want) {
+				t.Errorf("exposition differs from %s, run go test -update to see the change:\n%s",
+					golden, diffLines(string(want), got))
+			}
+		})
+	}
+}
+
+// diffLines lists the lines only in want and only in got.
+func diffLines(want, got string) string {
+	inWant := make(map[string]bool)
+	for _, line := range strings.Split(want, "\n") {
+		inWant[line] = true
+	}
+	inGot := make(map[string]bool)
+	for _, line := range strings.Split(got, "\n") {
+		inGot[line] = true
+	}
+	var diff []string
+	for _, line := range strings.Split(want, "\n") {
+		if !inGot[line] {
+			diff = append(diff, "- "+line)
+		}
+	}
+	for _, line := range strings.Split(got, "\n") {
+		if !inWant[line] {
+			diff = append(diff, "+ "+line)
+		}
+	}
+	return strings.Join(diff, "\n")
+}
+
+func TestCollectDownReason(t *testing.T) {
+	tests := []struct {
+		name     string
+		failure  fakeredis.Failure
+		password string
+		reason   string
+	}{
+		{name: "timeout", failure: fakeredis.Timeout, reason: info.RedisServerDownReasonTimeout},
+		{name: "noauth", failure: fakeredis.AuthFailure, reason: info.RedisServerDownReasonAuth},
+		{name: "loading", failure: fakeredis.Loading, reason: info.RedisServerDownReasonLoading},
+		{name: "wrong password", password: "secret", reason: info.RedisServerDownReasonAuth},
+		{name: "reset", failure: fakeredis.Reset, reason: info.RedisServerDownReasonUnreachable},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			server, err := fakeredis.Start("6.2")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer server.Close()
+			server.SetFailure(test.failure)
+			instance := fakeInstance(server)
+			if test.password != "" {
+				server.SetPassword(test.password)
+				instance.Password = "wrong"
+			}
+			instance.ReadTimeout = 100 * time.Millisecond
+
+			client := newRedisClient(instance)
+			defer client.Client.Close()
+			infoMap, _, _, err := redisInfoToMetrics(client.Name, client.Addr, client.Client)
+			if err == nil {
+				t.Fatal("collect succeeded")
+			}
+			if infoMap.Down != test.reason {
+				t.Errorf("down reason %q, want %q (error %s)", infoMap.Down, test.reason, err)
+			}
+		})
+	}
+}
+
+func TestCollectPassword(t *testing.T) {
+	server, err := fakeredis.Start("6.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.SetPassword("secret")
+	instance := fakeInstance(server)
+	instance.Password = "secret"
+	if _, err := collectOnce(instance); err != nil {
+		t.Fatalf("collect: %s", err)
+	}
+}
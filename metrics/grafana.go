@@ -0,0 +1,375 @@
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	grafanaSchemaVersion = 30
+	grafanaWidth         = 24
+	grafanaPanelWidth    = 8
+	grafanaPanelHeight   = 8
+	grafanaStatWidth     = 4
+	grafanaStatHeight    = 4
+
+	// nodeSelector limits a query to the targets of the node_name variable.
+	nodeSelector = `{node_name=~"$node_name"}`
+)
+
+// cumulativeGauges are the gauges of INFO fields that only grow, graphed as
+// rates.
+var cumulativeGauges = map[string]bool{
+	"redis_cmdstat_calls": true,
+	"redis_cmdstat_usec":  true,
+}
+
+// dashboardSections are the generated rows of the dashboard, one panel per
+// metric of the INFO section.
+var dashboardSections = []struct {
+	Title   string
+	Section string
+}{
+	{"Replication", "Replication"},
+	{"Persistence", "Persistence"},
+	{"Memory", "Memory"},
+	{"Commandstats", "Commandstats"},
+	{"Keyspace", "Keyspace"},
+}
+
+type grafanaDashboard struct {
+	UID           string            `json:"uid"`
+	Title         string            `json:"title"`
+	Tags          []string          `json:"tags"`
+	Timezone      string            `json:"timezone"`
+	SchemaVersion int               `json:"schemaVersion"`
+	Version       int               `json:"version"`
+	Refresh       string            `json:"refresh"`
+	Time          map[string]string `json:"time"`
+	Templating    grafanaTemplating `json:"templating"`
+	Panels        []*grafanaPanel   `json:"panels"`
+}
+
+type grafanaTemplating struct {
+	List []grafanaVariable `json:"list"`
+}
+
+type grafanaVariable struct {
+	Name       string             `json:"name"`
+	Label      string             `json:"label"`
+	Type       string             `json:"type"`
+	Query      string             `json:"query"`
+	Datasource *grafanaDatasource `json:"datasource,omitempty"`
+	Definition string             `json:"definition,omitempty"`
+	Multi      bool               `json:"multi"`
+	IncludeAll bool               `json:"includeAll"`
+	Refresh    int                `json:"refresh"`
+	Sort       int                `json:"sort"`
+}
+
+type grafanaDatasource struct {
+	Type string `json:"type"`
+	UID  string `json:"uid"`
+}
+
+type grafanaPanel struct {
+	ID          int                    `json:"id"`
+	Type        string                 `json:"type"`
+	Title       string                 `json:"title"`
+	Description string                 `json:"description,omitempty"`
+	GridPos     grafanaGridPos         `json:"gridPos"`
+	Datasource  *grafanaDatasource     `json:"datasource,omitempty"`
+	Targets     []grafanaTarget        `json:"targets,omitempty"`
+	FieldConfig *grafanaFieldConfig    `json:"fieldConfig,omitempty"`
+	Repeat      string                 `json:"repeat,omitempty"`
+	Collapsed   *bool                  `json:"collapsed,omitempty"`
+	Panels      []*grafanaPanel        `json:"panels,omitempty"`
+	Options     map[string]interface{} `json:"options,omitempty"`
+}
+
+type grafanaGridPos struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+type grafanaTarget struct {
+	Expr         string `json:"expr"`
+	LegendFormat string `json:"legendFormat"`
+	RefID        string `json:"refId"`
+}
+
+type grafanaFieldConfig struct {
+	Defaults struct {
+		Unit string `json:"unit,omitempty"`
+	} `json:"defaults"`
+}
+
+// dashboardBuilder lays out the panels of a dashboard and checks their
+// queries against the metric definitions.
+type dashboardBuilder struct {
+	definitions map[string]metricDefinition
+	datasource  *grafanaDatasource
+	panels      []*grafanaPanel
+	row         *grafanaPanel
+	id          int
+	x, y, h     int
+	err         error
+}
+
+func (b *dashboardBuilder) addRow(title, repeat string, collapsed bool) {
+	if b.row != nil {
+		// A collapsed row takes no more room than its title.
+		b.x, b.y, b.h = 0, b.row.GridPos.Y+1, 0
+	} else {
+		b.newLine()
+	}
+	b.id++
+	row := &grafanaPanel{
+		ID:        b.id,
+		Type:      "row",
+		Title:     title,
+		GridPos:   grafanaGridPos{X: 0, Y: b.y, W: grafanaWidth, H: 1},
+		Repeat:    repeat,
+		Collapsed: &collapsed,
+	}
+	b.panels = append(b.panels, row)
+	b.y++
+	b.row = nil
+	if collapsed {
+		// The panels of a collapsed row are kept in the row.
+		b.row = row
+	}
+}
+
+func (b *dashboardBuilder) newLine() {
+	if b.x > 0 {
+		b.x = 0
+		b.y += b.h
+	}
+	b.h = 0
+}
+
+// addPanel adds a panel with a query per expr, legends[i] naming the series
+// of exprs[i].
+func (b *dashboardBuilder) addPanel(panelType, title, description, unit string, w, h int,
+	exprs, legends []string) {
+	for _, expr := range exprs {
+		if err := checkExpr(b.definitions, nil, expr, "node_name"); err != nil && b.err == nil {
+			b.err = fmt.Errorf("panel %s: %s", title, err)
+		}
+	}
+	if b.x+w > grafanaWidth {
+		b.newLine()
+	}
+	b.id++
+	panel := &grafanaPanel{
+		ID:          b.id,
+		Type:        panelType,
+		Title:       title,
+		Description: description,
+		GridPos:     grafanaGridPos{X: b.x, Y: b.y, W: w, H: h},
+		Datasource:  b.datasource,
+	}
+	for i, expr := range exprs {
+		panel.Targets = append(panel.Targets, grafanaTarget{
+			Expr:         expr,
+			LegendFormat: legends[i],
+			RefID:        string(rune('A' + i)),
+		})
+	}
+	if unit != "" {
+		panel.FieldConfig = &grafanaFieldConfig{}
+		panel.FieldConfig.Defaults.Unit = unit
+	}
+	if panelType == "stat" {
+		panel.Options = map[string]interface{}{
+			"reduceOptions": map[string]interface{}{"calcs": []string{"lastNotNull"}},
+		}
+	}
+	if b.row != nil {
+		b.row.Panels = append(b.row.Panels, panel)
+	} else {
+		b.panels = append(b.panels, panel)
+	}
+	b.x += w
+	if h > b.h {
+		b.h = h
+	}
+}
+
+func (b *dashboardBuilder) stat(title, unit, expr string) {
+	b.addPanel("stat", title, "", unit, grafanaStatWidth, grafanaStatHeight,
+		[]string{expr}, []string{"{{node_name}}"})
+}
+
+func (b *dashboardBuilder) graph(title, unit string, exprs, legends []string) {
+	b.addPanel("timeseries", title, "", unit, grafanaPanelWidth, grafanaPanelHeight, exprs, legends)
+}
+
+// metricPanel adds the panel of a metric of a generated row.
+func (b *dashboardBuilder) metricPanel(definition metricDefinition) {
+	expr := definition.Name + nodeSelector
+	if definition.Type == MetricTypeCounter || cumulativeGauges[definition.Name] {
+		expr = "rate(" + expr + "[$__rate_interval])"
+	}
+	legend := "{{node_name}}"
+	for _, label := range definition.Labels {
+		if label != "node_name" && label != "node_address" {
+			legend += " {{" + label + "}}"
+		}
+	}
+	b.addPanel("timeseries", definition.Name, definition.Help, metricUnit(definition.Name),
+		grafanaPanelWidth, grafanaPanelHeight, []string{expr}, []string{legend})
+}
+
+// metricUnit guesses the Grafana unit of a metric from its name.
+func metricUnit(name string) string {
+	switch {
+	case strings.Contains(name, "ratio"), strings.Contains(name, "perc"):
+		return ""
+	case strings.HasSuffix(name, "_seconds"), strings.HasSuffix(name, "_sec"):
+		return "s"
+	case strings.HasSuffix(name, "_kbps"):
+		return "KBs"
+	case strings.HasSuffix(name, "usec"), strings.HasSuffix(name, "usec_per_call"):
+		return "µs"
+	case strings.HasSuffix(name, "_bytes"), strings.HasSuffix(name, "_size"),
+		strings.HasPrefix(name, "redis_memory_used_memory"), strings.HasPrefix(name, "redis_memory_mem_"),
+		strings.HasPrefix(name, "redis_memory_allocator_"), name == "redis_memory_maxmemory",
+		name == "redis_memory_total_system_memory":
+		return "bytes"
+	}
+	return ""
+}
+
+// generateDashboard returns a Grafana dashboard with curated overview and
+// per-instance rows and a row of panels per metric of the main INFO
+// sections, failing when a query uses a metric the collectors no longer
+// define.
+func generateDashboard(title, uid string) (*grafanaDashboard, error) {
+	definitions, err := metricDefinitions()
+	if err != nil {
+		return nil, err
+	}
+	b := &dashboardBuilder{
+		definitions: definitions,
+		datasource:  &grafanaDatasource{Type: "prometheus", UID: "${datasource}"},
+	}
+	rate := func(metric string) string {
+		return "rate(" + metric + nodeSelector + "[$__rate_interval])"
+	}
+	hitRatio := rate("redis_stats_keyspace_hits") + " / (" + rate("redis_stats_keyspace_hits") +
+		" + " + rate("redis_stats_keyspace_misses") + ")"
+	memoryRatio := "redis_memory_used_memory" + nodeSelector + " / (redis_memory_maxmemory" + nodeSelector + " > 0)"
+
+	b.addRow("Overview", "", false)
+	b.stat("Up", "", "redis_server_up"+nodeSelector)
+	b.stat("Uptime", "s", "redis_server_uptime_in_seconds"+nodeSelector)
+	b.stat("Commands/s", "ops", rate("redis_stats_total_commands_processed"))
+	b.stat("Memory of maxmemory", "percentunit", memoryRatio)
+	b.stat("Connected clients", "", "redis_clients_connected_clients"+nodeSelector)
+	b.stat("Hit ratio", "percentunit", hitRatio)
+
+	b.addRow("Instance $node_name", "node_name", false)
+	b.graph("Commands/s", "ops", []string{rate("redis_stats_total_commands_processed")},
+		[]string{"{{node_name}}"})
+	b.graph("Hit ratio", "percentunit", []string{hitRatio}, []string{"{{node_name}}"})
+	b.graph("Clients", "", []string{
+		"redis_clients_connected_clients" + nodeSelector,
+		"redis_clients_blocked_clients" + nodeSelector,
+	}, []string{"{{node_name}} connected", "{{node_name}} blocked"})
+	b.graph("Memory", "bytes", []string{
+		"redis_memory_used_memory" + nodeSelector,
+		"redis_memory_used_memory_rss" + nodeSelector,
+		"redis_memory_maxmemory" + nodeSelector + " > 0",
+	}, []string{"{{node_name}} used", "{{node_name}} rss", "{{node_name}} maxmemory"})
+	b.graph("Network", "KBs", []string{
+		"redis_stats_instantaneous_input_kbps" + nodeSelector,
+		"redis_stats_instantaneous_output_kbps" + nodeSelector,
+	}, []string{"{{node_name}} in", "{{node_name}} out"})
+	b.graph("CPU", "percentunit", []string{
+		rate("redis_cpu_used_cpu_sys"),
+		rate("redis_cpu_used_cpu_user"),
+	}, []string{"{{node_name}} sys", "{{node_name}} user"})
+	b.graph("Expired and evicted keys/s", "", []string{
+		rate("redis_stats_expired_keys"),
+		rate("redis_stats_evicted_keys"),
+	}, []string{"{{node_name}} expired", "{{node_name}} evicted"})
+
+	for _, section := range dashboardSections {
+		var metrics []metricDefinition
+		for _, definition := range definitions {
+			if definition.Section == section.Section {
+				metrics = append(metrics, definition)
+			}
+		}
+		if len(metrics) == 0 {
+			return nil, fmt.Errorf("no metrics in section %s", section.Section)
+		}
+		sort.Slice(metrics, func(i, j int) bool { return metrics[i].Name < metrics[j].Name })
+		b.addRow(section.Title, "", true)
+		for _, definition := range metrics {
+			b.metricPanel(definition)
+		}
+	}
+	nodeQuery := "label_values(redis_server_up, node_name)"
+	if err := checkExpr(definitions, nil, nodeQuery, "node_name"); err != nil && b.err == nil {
+		b.err = fmt.Errorf("node_name variable: %s", err)
+	}
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	return &grafanaDashboard{
+		UID:           uid,
+		Title:         title,
+		Tags:          []string{"redis", "redis-metrics"},
+		Timezone:      "browser",
+		SchemaVersion: grafanaSchemaVersion,
+		Version:       1,
+		Refresh:       "1m",
+		Time:          map[string]string{"from": "now-6h", "to": "now"},
+		Templating: grafanaTemplating{List: []grafanaVariable{
+			{
+				Name:  "datasource",
+				Label: "Data source",
+				Type:  "datasource",
+				Query: "prometheus",
+			},
+			{
+				Name:       "node_name",
+				Label:      "Node",
+				Type:       "query",
+				Query:      nodeQuery,
+				Definition: nodeQuery,
+				Datasource: b.datasource,
+				Multi:      true,
+				IncludeAll: true,
+				Refresh:    2,
+				Sort:       1,
+			},
+		}},
+		Panels: b.panels,
+	}, nil
+}
+
+// GenerateDashboardAction prints a Grafana dashboard for the metrics of the
+// exporter.
+func GenerateDashboardAction(c *cli.Context) error {
+	dashboard, err := generateDashboard(c.String("title"), c.String("uid"))
+	if err != nil {
+		return err
+	}
+	return writeOutput(c.String("output"), func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(dashboard)
+	})
+}
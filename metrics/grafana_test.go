@@ -0,0 +1,44 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateDashboard(t *testing.T) {
+	dashboard, err := generateDashboard("Redis", "redis-metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := make(map[string]*grafanaPanel)
+	for _, panel := range dashboard.Panels {
+		if panel.Type == "row" {
+			rows[panel.Title] = panel
+		}
+	}
+	for _, title := range []string{"Overview", "Instance $node_name", "Replication", "Persistence",
+		"Memory", "Commandstats", "Keyspace"} {
+		if rows[title] == nil {
+			t.Errorf("no %s row", title)
+		}
+	}
+	if rows["Instance $node_name"].Repeat != "node_name" {
+		t.Error("instance row does not repeat for node_name")
+	}
+
+	// Every metric of a section gets a panel, counters and cumulative gauges
+	// as rates.
+	exprs := make(map[string]string)
+	for _, panel := range rows["Replication"].Panels {
+		exprs[panel.Title] = panel.Targets[0].Expr
+	}
+	if expr := exprs["redis_replication_master_changes_total"]; !strings.HasPrefix(expr, "rate(") {
+		t.Errorf("master changes %q, want a rate", expr)
+	}
+	if expr := exprs["redis_replication_replica_lag_bytes"]; expr != `redis_replication_replica_lag_bytes{node_name=~"$node_name"}` {
+		t.Errorf("replica lag %q", expr)
+	}
+	if len(dashboard.Templating.List) != 2 || dashboard.Templating.List[1].Name != "node_name" {
+		t.Errorf("variables %+v", dashboard.Templating.List)
+	}
+}
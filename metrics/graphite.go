@@ -0,0 +1,73 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	OutputGraphite = "graphite"
+
+	DefaultGraphiteTemplate = "redis.{{.NodeName}}.{{.Section}}.{{.Name}}"
+)
+
+var graphiteEscaper = strings.NewReplacer(".", "_", " ", "_", ";", "_", "=", "_", "~", "_")
+
+// GraphiteSink writes points to a Graphite (carbon) plaintext listener over
+// TCP, one connection per collection. Labels are appended to the path or
+// sent as Graphite tags.
+type GraphiteSink struct {
+	Address   string
+	LabelMode string
+	Timeout   time.Duration
+
+	template *sinkTemplate
+}
+
+func NewGraphiteSink(address, nameTemplate, labelMode string, timeout time.Duration) (*GraphiteSink, error) {
+	if err := validSinkLabelMode(labelMode); err != nil {
+		return nil, err
+	}
+	tmpl, err := newSinkTemplate(nameTemplate, graphiteEscaper.Replace)
+	if err != nil {
+		return nil, err
+	}
+	return &GraphiteSink{
+		Address:   address,
+		LabelMode: labelMode,
+		Timeout:   timeout,
+		template:  tmpl,
+	}, nil
+}
+
+func (g *GraphiteSink) Write(points []SinkPoint) error {
+	var buf bytes.Buffer
+	for _, p := range points {
+		name, err := g.template.pathName(p, g.LabelMode, ".")
+		if err != nil {
+			return err
+		}
+		buf.WriteString(name)
+		if g.LabelMode == SinkLabelsAsTags {
+			for _, l := range p.Labels {
+				fmt.Fprintf(&buf, ";%s=%s", graphiteEscaper.Replace(l.GetName()), graphiteEscaper.Replace(l.GetValue()))
+			}
+		}
+		fmt.Fprintf(&buf, " %s %d\n", strconv.FormatFloat(p.Value, 'f', -1, 64), p.Time.Unix())
+	}
+
+	conn, err := net.DialTimeout("tcp", g.Address, g.Timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := conn.SetWriteDeadline(time.Now().Add(g.Timeout)); err != nil {
+		return err
+	}
+	_, err = buf.WriteTo(conn)
+	return err
+}
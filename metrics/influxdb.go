@@ -0,0 +1,107 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	OutputInfluxDB = "influxdb"
+
+	DefaultInfluxDBTemplate = "redis_{{.Section}}"
+)
+
+var (
+	influxMeasurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	influxKeyEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+)
+
+// InfluxDBSink posts points in line protocol to an InfluxDB write endpoint,
+// e.g. http://localhost:8086/write?db=redis or
+// http://localhost:8086/api/v2/write?org=o&bucket=b. The template renders the
+// measurement and the metric name becomes the field key, so the points of a
+// section and target share a line. Labels are sent as tags or appended to
+// the measurement.
+type InfluxDBSink struct {
+	URL       string
+	Token     string
+	LabelMode string
+
+	client   *http.Client
+	template *sinkTemplate
+}
+
+func NewInfluxDBSink(url, token, nameTemplate, labelMode string, timeout time.Duration) (*InfluxDBSink, error) {
+	if err := validSinkLabelMode(labelMode); err != nil {
+		return nil, err
+	}
+	tmpl, err := newSinkTemplate(nameTemplate, func(s string) string { return s })
+	if err != nil {
+		return nil, err
+	}
+	return &InfluxDBSink{
+		URL:       url,
+		Token:     token,
+		LabelMode: labelMode,
+		client:    &http.Client{Timeout: timeout},
+		template:  tmpl,
+	}, nil
+}
+
+func (s *InfluxDBSink) Write(points []SinkPoint) error {
+	var (
+		keys   []string
+		fields = make(map[string][]string)
+		times  = make(map[string]time.Time)
+	)
+	for _, p := range points {
+		measurement, err := s.template.pathName(p, s.LabelMode, "_")
+		if err != nil {
+			return err
+		}
+		key := influxMeasurementEscaper.Replace(measurement) +
+			",node_name=" + influxKeyEscaper.Replace(p.NodeName) +
+			",node_address=" + influxKeyEscaper.Replace(p.NodeAddress)
+		if s.LabelMode == SinkLabelsAsTags {
+			for _, l := range p.Labels {
+				key += "," + influxKeyEscaper.Replace(l.GetName()) + "=" + influxKeyEscaper.Replace(l.GetValue())
+			}
+		}
+		if _, ok := fields[key]; !ok {
+			keys = append(keys, key)
+			times[key] = p.Time
+		}
+		fields[key] = append(fields[key],
+			influxKeyEscaper.Replace(p.Name)+"="+strconv.FormatFloat(p.Value, 'f', -1, 64))
+	}
+
+	var body bytes.Buffer
+	for _, key := range keys {
+		fmt.Fprintf(&body, "%s %s %d\n", key, strings.Join(fields[key], ","), times[key].UnixNano())
+	}
+	req, err := http.NewRequest(http.MethodPost, s.URL, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if s.Token != "" {
+		req.Header.Set("Authorization", "Token "+s.Token)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		io.Copy(ioutil.Discard, resp.Body)
+		return nil
+	}
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 256))
+	return fmt.Errorf("server returned HTTP status %s: %s", resp.Status, bytes.TrimSpace(msg))
+}
@@ -2,7 +2,6 @@ package info
 
 import (
 	"github.com/prometheus/client_golang/prometheus"
-	"strconv"
 )
 
 type RedisClientsCollector struct {
@@ -104,49 +103,46 @@ func (m *RedisClientsCollector) Unregister(registry *prometheus.Registry) bool {
 	return true
 }
 
-func (m *RedisClientsCollector) Set(nodeName, nodeAddress string, r map[string]string) error {
+func (m *RedisClientsCollector) Delete(nodeName, nodeAddress string) {
+	deleteNodeSeries(nodeName, nodeAddress,
+		m.ConnectedClients,
+		m.ClientRecentMaxInputBuffer,
+		m.ClientRecentMaxOutputBuffer,
+		m.BlockedClients,
+	)
+}
 
-	if connectedClientsStr, ok := r["connected_clients"]; ok {
-		if connectedClients, err := strconv.Atoi(connectedClientsStr); err == nil {
-			m.ConnectedClients.WithLabelValues(nodeName, nodeAddress).Set(float64(connectedClients))
-		}
+func (m *RedisClientsCollector) Set(nodeName, nodeAddress string, r *Info) error {
+
+	if connectedClients, ok := r.Float("Clients", "connected_clients"); ok {
+		m.ConnectedClients.WithLabelValues(nodeName, nodeAddress).Set(connectedClients)
 	}
-	if clientRecentMaxInputBufferStr, ok := r["client_recent_max_input_buffer"]; ok {
-		if clientRecentMaxInputBuffer, err := strconv.Atoi(clientRecentMaxInputBufferStr); err == nil {
+	if clientRecentMaxInputBuffer, ok := r.Float("Clients", "client_recent_max_input_buffer"); ok {
+		m.ClientRecentMaxInputBuffer.WithLabelValues(
+			nodeName, nodeAddress,
+		).Set(clientRecentMaxInputBuffer)
+	} else {
+		if ClientBiggestInputBuf, ok := r.Float("Clients", "client_biggest_input_buf"); ok {
 			m.ClientRecentMaxInputBuffer.WithLabelValues(
 				nodeName, nodeAddress,
-			).Set(float64(clientRecentMaxInputBuffer))
-		}
-	} else {
-		if ClientBiggestInputBufStr, ok := r["client_biggest_input_buf"]; ok {
-			if ClientBiggestInputBuf, err := strconv.Atoi(ClientBiggestInputBufStr); err == nil {
-				m.ClientRecentMaxInputBuffer.WithLabelValues(
-					nodeName, nodeAddress,
-				).Set(float64(ClientBiggestInputBuf))
-			}
+			).Set(ClientBiggestInputBuf)
 		}
 	}
 
-	if ClientRecentMaxOutputBufferStr, ok := r["client_recent_max_output_buffer"]; ok {
-		if ClientRecentMaxOutputBuffer, err := strconv.Atoi(ClientRecentMaxOutputBufferStr); err == nil {
+	if ClientRecentMaxOutputBuffer, ok := r.Float("Clients", "client_recent_max_output_buffer"); ok {
+		m.ClientRecentMaxOutputBuffer.WithLabelValues(
+			nodeName, nodeAddress,
+		).Set(ClientRecentMaxOutputBuffer)
+	} else {
+		if clientLongestOutputList, ok := r.Float("Clients", "client_longest_output_list"); ok {
 			m.ClientRecentMaxOutputBuffer.WithLabelValues(
 				nodeName, nodeAddress,
-			).Set(float64(ClientRecentMaxOutputBuffer))
-		}
-	} else {
-		if clientLongestOutputListStr, ok := r["client_longest_output_list"]; ok {
-			if clientLongestOutputList, err := strconv.Atoi(clientLongestOutputListStr); err == nil {
-				m.ClientRecentMaxOutputBuffer.WithLabelValues(
-					nodeName, nodeAddress,
-				).Set(float64(clientLongestOutputList))
-			}
+			).Set(clientLongestOutputList)
 		}
 	}
 
-	if BlockedClientsStr, ok := r["blocked_clients"]; ok {
-		if BlockedClients, err := strconv.Atoi(BlockedClientsStr); err == nil {
-			m.BlockedClients.WithLabelValues(nodeName, nodeAddress).Set(float64(BlockedClients))
-		}
+	if BlockedClients, ok := r.Float("Clients", "blocked_clients"); ok {
+		m.BlockedClients.WithLabelValues(nodeName, nodeAddress).Set(BlockedClients)
 	}
 
 	return nil
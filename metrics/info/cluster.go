@@ -2,7 +2,6 @@ package info
 
 import (
 	"github.com/prometheus/client_golang/prometheus"
-	"strconv"
 )
 
 type RedisClusterCollector struct {
@@ -39,11 +38,15 @@ func (m *RedisClusterCollector) Unregister(registry *prometheus.Registry) bool {
 	return true
 }
 
-func (m *RedisClusterCollector) Set(nodeName, nodeAddress string, r map[string]string) error {
-	if clusterEnabledStr, ok := r["cluster_enabled"]; ok {
-		if clusterEnabled, err := strconv.Atoi(clusterEnabledStr); err == nil {
-			m.ClusterEnabled.WithLabelValues(nodeName, nodeAddress).Set(float64(clusterEnabled))
-		}
+func (m *RedisClusterCollector) Delete(nodeName, nodeAddress string) {
+	deleteNodeSeries(nodeName, nodeAddress,
+		m.ClusterEnabled,
+	)
+}
+
+func (m *RedisClusterCollector) Set(nodeName, nodeAddress string, r *Info) error {
+	if clusterEnabled, ok := r.Float("Cluster", "cluster_enabled"); ok {
+		m.ClusterEnabled.WithLabelValues(nodeName, nodeAddress).Set(clusterEnabled)
 	}
 	return nil
 }
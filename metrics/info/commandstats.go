@@ -2,8 +2,6 @@ package info
 
 import (
 	"github.com/prometheus/client_golang/prometheus"
-	"strconv"
-	"strings"
 )
 
 // cmdstat_client:calls=8,usec=32,usec_per_call=4.00
@@ -62,26 +60,20 @@ func (m *RedisCommandstatsCollector) Unregister(registry *prometheus.Registry) b
 	return true
 }
 
-func (m *RedisCommandstatsCollector) Set(nodeName, nodeAddress string, r map[string]string) error {
-	for key, value := range r {
-		if strings.Contains(key, "cmdstat_") {
-			valueMap := stringToMap(value)
-			if callsStr, ok := valueMap["calls"]; ok {
-				if calls, err := strconv.Atoi(callsStr); err == nil {
-					m.Calls.WithLabelValues(nodeName, nodeAddress, key).Set(float64(calls))
-				}
-			}
-			if usecStr, ok := valueMap["usec"]; ok {
-				if usec, err := strconv.Atoi(usecStr); err == nil {
-					m.Usec.WithLabelValues(nodeName, nodeAddress, key).Set(float64(usec))
-				}
-			}
-			if usecPerCallStr, ok := valueMap["usec_per_call"]; ok {
-				if usecPerCall, err := strconv.ParseFloat(usecPerCallStr, 64); err == nil {
-					m.UsecPerCall.WithLabelValues(nodeName, nodeAddress, key).Set(usecPerCall)
-				}
-			}
-		}
+func (m *RedisCommandstatsCollector) Delete(nodeName, nodeAddress string) {
+	deleteNodeSeries(nodeName, nodeAddress,
+		m.Calls,
+		m.Usec,
+		m.UsecPerCall,
+	)
+}
+
+func (m *RedisCommandstatsCollector) Set(nodeName, nodeAddress string, r *Info) error {
+	for _, stat := range r.Commandstats {
+		cmd := "cmdstat_" + stat.Command
+		m.Calls.WithLabelValues(nodeName, nodeAddress, cmd).Set(float64(stat.Calls))
+		m.Usec.WithLabelValues(nodeName, nodeAddress, cmd).Set(float64(stat.Usec))
+		m.UsecPerCall.WithLabelValues(nodeName, nodeAddress, cmd).Set(stat.UsecPerCall)
 	}
 	return nil
 }
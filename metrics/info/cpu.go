@@ -2,7 +2,6 @@ package info
 
 import (
 	"github.com/prometheus/client_golang/prometheus"
-	"strconv"
 )
 
 type RedisCPUCollector struct {
@@ -87,26 +86,27 @@ func (m *RedisCPUCollector) Unregister(registry *prometheus.Registry) bool {
 	return true
 }
 
-func (m *RedisCPUCollector) Set(nodeName, nodeAddress string, r map[string]string) error {
-	if usedCpuSysStr, ok := r["used_cpu_sys"]; ok {
-		if usedCpuSys, err := strconv.ParseFloat(usedCpuSysStr, 64); err == nil {
-			m.UsedCpuSys.WithLabelValues(nodeName, nodeAddress).Set(float64(usedCpuSys))
-		}
+func (m *RedisCPUCollector) Delete(nodeName, nodeAddress string) {
+	deleteNodeSeries(nodeName, nodeAddress,
+		m.UsedCpuSys,
+		m.UsedCpuUser,
+		m.UsedCpuSysChildren,
+		m.UsedCpuUserChildren,
+	)
+}
+
+func (m *RedisCPUCollector) Set(nodeName, nodeAddress string, r *Info) error {
+	if usedCpuSys, ok := r.Float("CPU", "used_cpu_sys"); ok {
+		m.UsedCpuSys.WithLabelValues(nodeName, nodeAddress).Set(usedCpuSys)
 	}
-	if usedCpuUserStr, ok := r["used_cpu_user"]; ok {
-		if usedCpuUser, err := strconv.ParseFloat(usedCpuUserStr, 64); err == nil {
-			m.UsedCpuUser.WithLabelValues(nodeName, nodeAddress).Set(float64(usedCpuUser))
-		}
+	if usedCpuUser, ok := r.Float("CPU", "used_cpu_user"); ok {
+		m.UsedCpuUser.WithLabelValues(nodeName, nodeAddress).Set(usedCpuUser)
 	}
-	if usedCpuSysChildrenStr, ok := r["used_cpu_sys_children"]; ok {
-		if usedCpuSysChildren, err := strconv.ParseFloat(usedCpuSysChildrenStr, 64); err == nil {
-			m.UsedCpuSysChildren.WithLabelValues(nodeName, nodeAddress).Set(float64(usedCpuSysChildren))
-		}
+	if usedCpuSysChildren, ok := r.Float("CPU", "used_cpu_sys_children"); ok {
+		m.UsedCpuSysChildren.WithLabelValues(nodeName, nodeAddress).Set(usedCpuSysChildren)
 	}
-	if usedCpuUserChildrenStr, ok := r["used_cpu_user_children"]; ok {
-		if usedCpuUserChildren, err := strconv.ParseFloat(usedCpuUserChildrenStr, 64); err == nil {
-			m.UsedCpuUserChildren.WithLabelValues(nodeName, nodeAddress).Set(float64(usedCpuUserChildren))
-		}
+	if usedCpuUserChildren, ok := r.Float("CPU", "used_cpu_user_children"); ok {
+		m.UsedCpuUserChildren.WithLabelValues(nodeName, nodeAddress).Set(usedCpuUserChildren)
 	}
 	return nil
 }
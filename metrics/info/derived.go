@@ -0,0 +1,229 @@
+package info
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	RedisDerivedFragmentationOK int = iota
+	RedisDerivedFragmentationHigh
+	RedisDerivedFragmentationSevere
+)
+
+const (
+	RedisDerivedLimitMaxmemory = "maxmemory"
+	RedisDerivedLimitSystem    = "system"
+)
+
+var (
+	// Fragmentation below RedisDerivedFragmentationMinBytes of RSS above
+	// used_memory is ok whatever the ratio, small datasets have high ratios.
+	RedisDerivedFragmentationMinBytes    float64 = 100 << 20
+	RedisDerivedFragmentationHighRatio   float64 = 1.5
+	RedisDerivedFragmentationSevereRatio float64 = 2
+)
+
+// RedisDerivedCollector exports values derived from the raw INFO fields,
+// which would otherwise be recomputed in every query.
+type RedisDerivedCollector struct {
+	KeyspaceHitRatio       *prometheus.GaugeVec
+	MemoryUtilisation      *prometheus.GaugeVec
+	FragmentationSeverity  *prometheus.GaugeVec
+	ConnectionUtilisation  *prometheus.GaugeVec
+	ForkSecondsPerGigabyte *prometheus.GaugeVec
+
+	mu    sync.Mutex
+	nodes map[string]derivedNode
+}
+
+// derivedNode is what the derived values of a node need from its previous
+// collection.
+type derivedNode struct {
+	hits, misses float64
+	limit        string
+}
+
+func NewRedisDerivedCollector() *RedisDerivedCollector {
+	var (
+		// keyspace_hits / (keyspace_hits + keyspace_misses) between collections
+		redisDerivedKeyspaceHitRatio = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "redis",
+			Subsystem: "derived",
+			Name:      "keyspace_hit_ratio",
+			Help:      "Ratio of key lookups that hit since the previous collection, absent without lookups.",
+		},
+			[]string{"node_name", "node_address"})
+
+		// used_memory / maxmemory or total_system_memory
+		redisDerivedMemoryUtilisation = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "redis",
+			Subsystem: "derived",
+			Name:      "memory_utilisation_ratio",
+			Help:      "Ratio of used_memory to maxmemory, or to total_system_memory without maxmemory, named by limit.",
+		},
+			[]string{"node_name", "node_address", "limit"})
+
+		// used_memory_rss / used_memory
+		redisDerivedFragmentationSeverity = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "redis",
+			Subsystem: "derived",
+			Name:      "fragmentation_severity",
+			Help:      "Memory fragmentation: 0 ok, 1 high, 2 severe. Ok while RSS exceeds used_memory by less than 100MB.",
+		},
+			[]string{"node_name", "node_address"})
+
+		// connected_clients / maxclients
+		redisDerivedConnectionUtilisation = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "redis",
+			Subsystem: "derived",
+			Name:      "connection_utilisation_ratio",
+			Help:      "Ratio of connected_clients to maxclients, absent when INFO has no maxclients.",
+		},
+			[]string{"node_name", "node_address"})
+
+		// latest_fork_usec per GB of used_memory_rss
+		redisDerivedForkSecondsPerGigabyte = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "redis",
+			Subsystem: "derived",
+			Name:      "fork_seconds_per_gigabyte",
+			Help:      "Duration of the latest fork per GB of used_memory_rss, to estimate the cost of the next one.",
+		},
+			[]string{"node_name", "node_address"})
+	)
+	return &RedisDerivedCollector{
+		redisDerivedKeyspaceHitRatio,
+		redisDerivedMemoryUtilisation,
+		redisDerivedFragmentationSeverity,
+		redisDerivedConnectionUtilisation,
+		redisDerivedForkSecondsPerGigabyte,
+		sync.Mutex{},
+		make(map[string]derivedNode),
+	}
+}
+
+func (m *RedisDerivedCollector) MustRegister(registry *prometheus.Registry) {
+	registry.MustRegister(m.KeyspaceHitRatio)
+	registry.MustRegister(m.MemoryUtilisation)
+	registry.MustRegister(m.FragmentationSeverity)
+	registry.MustRegister(m.ConnectionUtilisation)
+	registry.MustRegister(m.ForkSecondsPerGigabyte)
+}
+
+func (m *RedisDerivedCollector) Unregister(registry *prometheus.Registry) bool {
+	if !registry.Unregister(m.KeyspaceHitRatio) {
+		return false
+	}
+	if !registry.Unregister(m.MemoryUtilisation) {
+		return false
+	}
+	if !registry.Unregister(m.FragmentationSeverity) {
+		return false
+	}
+	if !registry.Unregister(m.ConnectionUtilisation) {
+		return false
+	}
+	if !registry.Unregister(m.ForkSecondsPerGigabyte) {
+		return false
+	}
+	return true
+}
+
+func (m *RedisDerivedCollector) Delete(nodeName, nodeAddress string) {
+	deleteNodeSeries(nodeName, nodeAddress,
+		m.KeyspaceHitRatio,
+		m.MemoryUtilisation,
+		m.FragmentationSeverity,
+		m.ConnectionUtilisation,
+		m.ForkSecondsPerGigabyte,
+	)
+	m.mu.Lock()
+	delete(m.nodes, nodeName+"\x00"+nodeAddress)
+	m.mu.Unlock()
+}
+
+// Set derives the values from the sections the other collectors already
+// parsed, so it reads them without recording parse errors again.
+func (m *RedisDerivedCollector) Set(nodeName, nodeAddress string, r *Info) error {
+	if r.Down != "" {
+		return nil
+	}
+	key := nodeName + "\x00" + nodeAddress
+	m.mu.Lock()
+	previous, seen := m.nodes[key]
+	m.mu.Unlock()
+	current := derivedNode{limit: previous.limit}
+
+	// keyspace_hits:1000
+	// keyspace_misses:10
+	stats := r.Section("Stats")
+	hits, hasHits := stats.Float("keyspace_hits")
+	misses, hasMisses := stats.Float("keyspace_misses")
+	if hasHits && hasMisses {
+		current.hits, current.misses = hits, misses
+		deltaHits, deltaMisses := hits-previous.hits, misses-previous.misses
+		// A restart or CONFIG RESETSTAT resets the counters.
+		if seen && deltaHits >= 0 && deltaMisses >= 0 && deltaHits+deltaMisses > 0 {
+			m.KeyspaceHitRatio.WithLabelValues(nodeName, nodeAddress).Set(deltaHits / (deltaHits + deltaMisses))
+		} else {
+			m.KeyspaceHitRatio.DeleteLabelValues(nodeName, nodeAddress)
+		}
+	}
+
+	// used_memory:1024
+	// maxmemory:0
+	// total_system_memory:8589934592
+	memory := r.Section("Memory")
+	used, hasUsed := memory.Float("used_memory")
+	maxmemory, _ := memory.Float("maxmemory")
+	system, _ := memory.Float("total_system_memory")
+	limit, limitValue := "", 0.0
+	switch {
+	case maxmemory > 0:
+		limit, limitValue = RedisDerivedLimitMaxmemory, maxmemory
+	case system > 0:
+		limit, limitValue = RedisDerivedLimitSystem, system
+	}
+	if previous.limit != "" && previous.limit != limit {
+		m.MemoryUtilisation.DeleteLabelValues(nodeName, nodeAddress, previous.limit)
+	}
+	current.limit = limit
+	if hasUsed && limit != "" {
+		m.MemoryUtilisation.WithLabelValues(nodeName, nodeAddress, limit).Set(used / limitValue)
+	}
+
+	// used_memory_rss:4194304
+	if rss, ok := memory.Float("used_memory_rss"); ok && hasUsed && used > 0 {
+		severity := RedisDerivedFragmentationOK
+		ratio := rss / used
+		if rss-used >= RedisDerivedFragmentationMinBytes {
+			switch {
+			case ratio > RedisDerivedFragmentationSevereRatio:
+				severity = RedisDerivedFragmentationSevere
+			case ratio > RedisDerivedFragmentationHighRatio:
+				severity = RedisDerivedFragmentationHigh
+			}
+		}
+		m.FragmentationSeverity.WithLabelValues(nodeName, nodeAddress).Set(float64(severity))
+	}
+
+	// connected_clients:1
+	// maxclients:10000
+	clients := r.Section("Clients")
+	connected, hasConnected := clients.Float("connected_clients")
+	if maxclients, ok := clients.Float("maxclients"); ok && hasConnected && maxclients > 0 {
+		m.ConnectionUtilisation.WithLabelValues(nodeName, nodeAddress).Set(connected / maxclients)
+	}
+
+	// latest_fork_usec:350, 0 before the first fork
+	fork, _ := stats.Float("latest_fork_usec")
+	if rss, ok := memory.Float("used_memory_rss"); ok && fork > 0 && rss > 0 {
+		m.ForkSecondsPerGigabyte.WithLabelValues(nodeName, nodeAddress).Set(fork / 1e6 / (rss / (1 << 30)))
+	}
+
+	m.mu.Lock()
+	m.nodes[key] = current
+	m.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,67 @@
+package info
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestDerived(t *testing.T) {
+	m := NewRedisDerivedCollector()
+	node := func(hits, misses, maxmemory, rss string) *Info {
+		return ParseInfo("# Clients\r\nconnected_clients:50\r\nmaxclients:100\r\n" +
+			"# Memory\r\nused_memory:104857600\r\nused_memory_rss:" + rss + "\r\n" +
+			"maxmemory:" + maxmemory + "\r\ntotal_system_memory:1048576000\r\n" +
+			"# Stats\r\nkeyspace_hits:" + hits + "\r\nkeyspace_misses:" + misses + "\r\nlatest_fork_usec:0\r\n")
+	}
+
+	m.Set("a", "a:6379", node("100", "100", "0", "110000000"))
+	if n := testSeries(m.KeyspaceHitRatio); n != 0 {
+		t.Errorf("hit ratio from the first collection")
+	}
+	if v := gaugeValue(m.MemoryUtilisation.WithLabelValues("a", "a:6379", RedisDerivedLimitSystem)); v != 0.1 {
+		t.Errorf("memory utilisation %v against the system, want 0.1", v)
+	}
+	// A high ratio of few bytes is ok.
+	if v := gaugeValue(m.FragmentationSeverity.WithLabelValues("a", "a:6379")); v != float64(RedisDerivedFragmentationOK) {
+		t.Errorf("fragmentation severity %v, want ok", v)
+	}
+	if v := gaugeValue(m.ConnectionUtilisation.WithLabelValues("a", "a:6379")); v != 0.5 {
+		t.Errorf("connection utilisation %v, want 0.5", v)
+	}
+	if n := testSeries(m.ForkSecondsPerGigabyte); n != 0 {
+		t.Errorf("fork cost before the first fork")
+	}
+
+	m.Set("a", "a:6379", node("190", "110", "209715200", "314572800"))
+	if v := gaugeValue(m.KeyspaceHitRatio.WithLabelValues("a", "a:6379")); v != 0.9 {
+		t.Errorf("hit ratio %v, want 0.9", v)
+	}
+	if n := testSeries(m.MemoryUtilisation); n != 1 {
+		t.Errorf("%d memory utilisation series, want only maxmemory's", n)
+	}
+	if v := gaugeValue(m.MemoryUtilisation.WithLabelValues("a", "a:6379", RedisDerivedLimitMaxmemory)); v != 0.5 {
+		t.Errorf("memory utilisation %v against maxmemory, want 0.5", v)
+	}
+	if v := gaugeValue(m.FragmentationSeverity.WithLabelValues("a", "a:6379")); v != float64(RedisDerivedFragmentationSevere) {
+		t.Errorf("fragmentation severity %v, want severe", v)
+	}
+
+	// The counters restarted.
+	m.Set("a", "a:6379", node("10", "0", "209715200", "314572800"))
+	if n := testSeries(m.KeyspaceHitRatio); n != 0 {
+		t.Errorf("hit ratio across a counter reset")
+	}
+
+	m.Delete("a", "a:6379")
+	if n := testSeries(m.MemoryUtilisation); n != 0 {
+		t.Errorf("%d memory utilisation series after Delete", n)
+	}
+}
+
+func gaugeValue(g prometheus.Gauge) float64 {
+	var m dto.Metric
+	g.Write(&m)
+	return m.GetGauge().GetValue()
+}
@@ -2,8 +2,6 @@ package info
 
 import (
 	"github.com/prometheus/client_golang/prometheus"
-	"strconv"
-	"strings"
 )
 
 type RedisKeyspaceCollector struct {
@@ -69,28 +67,19 @@ func (m *RedisKeyspaceCollector) Unregister(registry *prometheus.Registry) bool
 	return true
 }
 
-func (m *RedisKeyspaceCollector) Set(nodeName, nodeAddress string, r map[string]string) error {
-	for key, value := range r {
-		ok1 := strings.Contains(key, "db")
-		ok2 := strings.Contains(value, "keys")
-		if ok1 && ok2 {
-			valueMap := stringToMap(value)
-			if keysStr, ok := valueMap["keys"]; ok {
-				if keys, err := strconv.Atoi(keysStr); err == nil {
-					m.DBKeys.WithLabelValues(nodeName, nodeAddress, key).Set(float64(keys))
-				}
-			}
-			if expiresStr, ok := valueMap["expires"]; ok {
-				if expires, err := strconv.Atoi(expiresStr); err == nil {
-					m.DBExpires.WithLabelValues(nodeName, nodeAddress, key).Set(float64(expires))
-				}
-			}
-			if avgTTLStr, ok := valueMap["avg_ttl"]; ok {
-				if avgTTL, err := strconv.Atoi(avgTTLStr); err == nil {
-					m.DBAvgTTL.WithLabelValues(nodeName, nodeAddress, key).Set(float64(avgTTL))
-				}
-			}
-		}
+func (m *RedisKeyspaceCollector) Delete(nodeName, nodeAddress string) {
+	deleteNodeSeries(nodeName, nodeAddress,
+		m.DBKeys,
+		m.DBExpires,
+		m.DBAvgTTL,
+	)
+}
+
+func (m *RedisKeyspaceCollector) Set(nodeName, nodeAddress string, r *Info) error {
+	for _, db := range r.Keyspace {
+		m.DBKeys.WithLabelValues(nodeName, nodeAddress, db.DB).Set(float64(db.Keys))
+		m.DBExpires.WithLabelValues(nodeName, nodeAddress, db.DB).Set(float64(db.Expires))
+		m.DBAvgTTL.WithLabelValues(nodeName, nodeAddress, db.DB).Set(float64(db.AvgTTL))
 	}
 	return nil
 }
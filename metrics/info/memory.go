@@ -2,7 +2,6 @@ package info
 
 import (
 	"github.com/prometheus/client_golang/prometheus"
-	"strconv"
 )
 
 const (
@@ -522,21 +521,51 @@ func (m *RedisMemoryCollector) Unregister(registry *prometheus.Registry) bool {
 	return true
 }
 
-func (m *RedisMemoryCollector) Set(nodeName, nodeAddress string, r map[string]string) error {
-	if usedMemoryStr, ok := r["used_memory"]; ok {
-		if usedMemory, err := strconv.Atoi(usedMemoryStr); err == nil {
-			m.UsedMemory.WithLabelValues(nodeName, nodeAddress).Set(float64(usedMemory))
-		}
+func (m *RedisMemoryCollector) Delete(nodeName, nodeAddress string) {
+	deleteNodeSeries(nodeName, nodeAddress,
+		m.UsedMemory,
+		m.UsedMemoryRSS,
+		m.UsedMemoryPeak,
+		m.UsedMemoryOverhead,
+		m.UsedMemoryStartup,
+		m.UsedMemoryDataset,
+		m.TotalSystemMemory,
+		m.UsedMemoryLua,
+		m.UsedMemoryScripts,
+		m.Maxmemory,
+		m.MaxmemoryPolicy,
+		m.MemFragmentationRatio,
+		m.MemAllocator,
+		m.ActiveDefragRunning,
+		m.LazyfreePendingObjects,
+		m.AllocatorAllocated,
+		m.AllocatorActive,
+		m.AllocatorResident,
+		m.AllocatorFragRatio,
+		m.AllocatorFragBytes,
+		m.AllocatorRSSRatio,
+		m.AllocatorRSSBytes,
+		m.RSSOverheadRatio,
+		m.RSSOverheadBytes,
+		m.MemFragmentationBytes,
+		m.MemNotCountedForEvict,
+		m.MemReplicationBacklog,
+		m.MemClientsSlaves,
+		m.MemClientsNormal,
+		m.MemAofBuffer,
+		m.NumberOfCachedScripts,
+	)
+}
+
+func (m *RedisMemoryCollector) Set(nodeName, nodeAddress string, r *Info) error {
+	if usedMemory, ok := r.Float("Memory", "used_memory"); ok {
+		m.UsedMemory.WithLabelValues(nodeName, nodeAddress).Set(usedMemory)
 	}
-	if usedMemoryRSSStr, ok := r["used_memory_rss"]; ok {
-		if usedMemoryRSS, err := strconv.Atoi(usedMemoryRSSStr); err == nil {
-			m.UsedMemoryRSS.WithLabelValues(nodeName, nodeAddress).Set(float64(usedMemoryRSS))
-		}
+	if usedMemoryRSS, ok := r.Float("Memory", "used_memory_rss"); ok {
+		m.UsedMemoryRSS.WithLabelValues(nodeName, nodeAddress).Set(usedMemoryRSS)
 	}
-	if usedMemoryPeakStr, ok := r["used_memory_peak"]; ok {
-		if usedMemoryPeak, err := strconv.Atoi(usedMemoryPeakStr); err == nil {
-			m.UsedMemoryPeak.WithLabelValues(nodeName, nodeAddress).Set(float64(usedMemoryPeak))
-		}
+	if usedMemoryPeak, ok := r.Float("Memory", "used_memory_peak"); ok {
+		m.UsedMemoryPeak.WithLabelValues(nodeName, nodeAddress).Set(usedMemoryPeak)
 	}
 
 	//usedMemoryPeakPerc := "0%"
@@ -544,64 +573,42 @@ func (m *RedisMemoryCollector) Set(nodeName, nodeAddress string, r map[string]st
 	//	usedMemoryPeakPerc = "0%"
 	//}
 
-	if usedMemoryOverheadStr, ok := r["used_memory_overhead"]; ok {
-		if usedMemoryOverhead, err := strconv.Atoi(usedMemoryOverheadStr); err == nil {
-			m.UsedMemoryOverhead.WithLabelValues(nodeName, nodeAddress).Set(float64(usedMemoryOverhead))
-		}
+	if usedMemoryOverhead, ok := r.Float("Memory", "used_memory_overhead"); ok {
+		m.UsedMemoryOverhead.WithLabelValues(nodeName, nodeAddress).Set(usedMemoryOverhead)
 	}
-	if usedMemoryStratupStr, ok := r["used_memory_startup"]; ok {
-		if usedMemoryStratup, err := strconv.Atoi(usedMemoryStratupStr); err == nil {
-			m.UsedMemoryStartup.WithLabelValues(nodeName, nodeAddress).Set(float64(usedMemoryStratup))
-		}
+	if usedMemoryStratup, ok := r.Float("Memory", "used_memory_startup"); ok {
+		m.UsedMemoryStartup.WithLabelValues(nodeName, nodeAddress).Set(usedMemoryStratup)
 	}
-	if usedMemoryDatasetStr, ok := r["used_memory_dataset"]; ok {
-		if usedMemoryDataset, err := strconv.Atoi(usedMemoryDatasetStr); err == nil {
-			m.UsedMemoryDataset.WithLabelValues(nodeName, nodeAddress).Set(float64(usedMemoryDataset))
-		}
+	if usedMemoryDataset, ok := r.Float("Memory", "used_memory_dataset"); ok {
+		m.UsedMemoryDataset.WithLabelValues(nodeName, nodeAddress).Set(usedMemoryDataset)
 	}
-	//if usedMemoryDatasetPerc, ok := r["used_memory_dataset_perc"]; ok {
+	//if usedMemoryDatasetPerc, ok := r.String("Memory", "used_memory_dataset_perc"); ok {
 	//}
-	if allocatorAllocatedStr, ok := r["allocator_allocated"]; ok {
-		if allocatorAllocated, err := strconv.Atoi(allocatorAllocatedStr); err == nil {
-			m.AllocatorAllocated.WithLabelValues(nodeName, nodeAddress).Set(float64(allocatorAllocated))
-		}
+	if allocatorAllocated, ok := r.Float("Memory", "allocator_allocated"); ok {
+		m.AllocatorAllocated.WithLabelValues(nodeName, nodeAddress).Set(allocatorAllocated)
 	}
-	if allocatorActiveStr, ok := r["allocator_active"]; ok {
-		if allocatorActive, err := strconv.Atoi(allocatorActiveStr); err == nil {
-			m.AllocatorActive.WithLabelValues(nodeName, nodeAddress).Set(float64(allocatorActive))
-		}
+	if allocatorActive, ok := r.Float("Memory", "allocator_active"); ok {
+		m.AllocatorActive.WithLabelValues(nodeName, nodeAddress).Set(allocatorActive)
 	}
-	if allocatorResidentStr, ok := r["allocator_resident"]; ok {
-		if allocatorResident, err := strconv.Atoi(allocatorResidentStr); err == nil {
-			m.AllocatorResident.WithLabelValues(nodeName, nodeAddress).Set(float64(allocatorResident))
-		}
+	if allocatorResident, ok := r.Float("Memory", "allocator_resident"); ok {
+		m.AllocatorResident.WithLabelValues(nodeName, nodeAddress).Set(allocatorResident)
 	}
-	if totalSystemMemoryStr, ok := r["total_system_memory"]; ok {
-		if totalSystemMemory, err := strconv.Atoi(totalSystemMemoryStr); err == nil {
-			m.TotalSystemMemory.WithLabelValues(nodeName, nodeAddress).Set(float64(totalSystemMemory))
-		}
+	if totalSystemMemory, ok := r.Float("Memory", "total_system_memory"); ok {
+		m.TotalSystemMemory.WithLabelValues(nodeName, nodeAddress).Set(totalSystemMemory)
 	}
-	if usedMemoryLuaStr, ok := r["used_memory_lua"]; ok {
-		if usedMemoryLua, err := strconv.Atoi(usedMemoryLuaStr); err == nil {
-			m.UsedMemoryLua.WithLabelValues(nodeName, nodeAddress).Set(float64(usedMemoryLua))
-		}
+	if usedMemoryLua, ok := r.Float("Memory", "used_memory_lua"); ok {
+		m.UsedMemoryLua.WithLabelValues(nodeName, nodeAddress).Set(usedMemoryLua)
 	}
-	if usedMemoryScriptsStr, ok := r["used_memory_scripts"]; ok {
-		if usedMemoryScripts, err := strconv.Atoi(usedMemoryScriptsStr); err == nil {
-			m.UsedMemoryScripts.WithLabelValues(nodeName, nodeAddress).Set(float64(usedMemoryScripts))
-		}
+	if usedMemoryScripts, ok := r.Float("Memory", "used_memory_scripts"); ok {
+		m.UsedMemoryScripts.WithLabelValues(nodeName, nodeAddress).Set(usedMemoryScripts)
 	}
-	if numberOfCachedScriptsStr, ok := r["number_of_cached_scripts"]; ok {
-		if numberOfCachedScripts, err := strconv.Atoi(numberOfCachedScriptsStr); err == nil {
-			m.NumberOfCachedScripts.WithLabelValues(nodeName, nodeAddress).Set(float64(numberOfCachedScripts))
-		}
+	if numberOfCachedScripts, ok := r.Float("Memory", "number_of_cached_scripts"); ok {
+		m.NumberOfCachedScripts.WithLabelValues(nodeName, nodeAddress).Set(numberOfCachedScripts)
 	}
-	if maxmemoryStr, ok := r["maxmemory"]; ok {
-		if maxmemory, err := strconv.Atoi(maxmemoryStr); err == nil {
-			m.Maxmemory.WithLabelValues(nodeName, nodeAddress).Set(float64(maxmemory))
-		}
+	if maxmemory, ok := r.Float("Memory", "maxmemory"); ok {
+		m.Maxmemory.WithLabelValues(nodeName, nodeAddress).Set(maxmemory)
 	}
-	if maxmemoryPolicyStr, ok := r["maxmemory_policy"]; ok {
+	if maxmemoryPolicyStr, ok := r.String("Memory", "maxmemory_policy"); ok {
 		maxmemoryPolicy := noeviction
 		switch maxmemoryPolicyStr {
 		case "volatile-lru":
@@ -623,73 +630,47 @@ func (m *RedisMemoryCollector) Set(nodeName, nodeAddress string, r map[string]st
 		}
 		m.MaxmemoryPolicy.WithLabelValues(nodeName, nodeAddress).Set(float64(maxmemoryPolicy))
 	}
-	if allocatorFragRatioStr, ok := r["allocator_frag_ratio"]; ok {
-		if allocatorFragRatio, err := strconv.ParseFloat(allocatorFragRatioStr, 64); err == nil {
-			m.AllocatorFragRatio.WithLabelValues(nodeName, nodeAddress).Set(allocatorFragRatio)
-		}
+	if allocatorFragRatio, ok := r.Float("Memory", "allocator_frag_ratio"); ok {
+		m.AllocatorFragRatio.WithLabelValues(nodeName, nodeAddress).Set(allocatorFragRatio)
 	}
-	if allocatorFragBytesStr, ok := r["allocator_frag_bytes"]; ok {
-		if allocatorFragBytes, err := strconv.Atoi(allocatorFragBytesStr); err == nil {
-			m.AllocatorFragBytes.WithLabelValues(nodeName, nodeAddress).Set(float64(allocatorFragBytes))
-		}
+	if allocatorFragBytes, ok := r.Float("Memory", "allocator_frag_bytes"); ok {
+		m.AllocatorFragBytes.WithLabelValues(nodeName, nodeAddress).Set(allocatorFragBytes)
 	}
-	if rssOverheadRatioStr, ok := r["rss_overhead_ratio"]; ok {
-		if rssOverheadRatio, err := strconv.ParseFloat(rssOverheadRatioStr, 64); err == nil {
-			m.RSSOverheadRatio.WithLabelValues(nodeName, nodeAddress).Set(rssOverheadRatio)
-		}
+	if rssOverheadRatio, ok := r.Float("Memory", "rss_overhead_ratio"); ok {
+		m.RSSOverheadRatio.WithLabelValues(nodeName, nodeAddress).Set(rssOverheadRatio)
 	}
-	if rssOverheadBytesStr, ok := r["rss_overhead_bytes"]; ok {
-		if rssOverheadBytes, err := strconv.Atoi(rssOverheadBytesStr); err == nil {
-			m.RSSOverheadBytes.WithLabelValues(nodeName, nodeAddress).Set(float64(rssOverheadBytes))
-		}
+	if rssOverheadBytes, ok := r.Float("Memory", "rss_overhead_bytes"); ok {
+		m.RSSOverheadBytes.WithLabelValues(nodeName, nodeAddress).Set(rssOverheadBytes)
 	}
-	if memFragmentationRatioStr, ok := r["mem_fragmentation_ratio"]; ok {
-		if memFragmentationRatio, err := strconv.ParseFloat(memFragmentationRatioStr, 64); err == nil {
-			m.MemFragmentationRatio.WithLabelValues(nodeName, nodeAddress).Set(memFragmentationRatio)
-		}
+	if memFragmentationRatio, ok := r.Float("Memory", "mem_fragmentation_ratio"); ok {
+		m.MemFragmentationRatio.WithLabelValues(nodeName, nodeAddress).Set(memFragmentationRatio)
 	}
-	if memFragmentationBytesStr, ok := r["mem_fragmentation_bytes"]; ok {
-		if memFragmentationBytes, err := strconv.Atoi(memFragmentationBytesStr); err == nil {
-			m.MemFragmentationBytes.WithLabelValues(nodeName, nodeAddress).Set(float64(memFragmentationBytes))
-		}
+	if memFragmentationBytes, ok := r.Float("Memory", "mem_fragmentation_bytes"); ok {
+		m.MemFragmentationBytes.WithLabelValues(nodeName, nodeAddress).Set(memFragmentationBytes)
 	}
-	if memNotCountedForEvictStr, ok := r["mem_not_counted_for_evict"]; ok {
-		if memNotCountedForEvict, err := strconv.Atoi(memNotCountedForEvictStr); err == nil {
-			m.MemNotCountedForEvict.WithLabelValues(nodeName, nodeAddress).Set(float64(memNotCountedForEvict))
-		}
+	if memNotCountedForEvict, ok := r.Float("Memory", "mem_not_counted_for_evict"); ok {
+		m.MemNotCountedForEvict.WithLabelValues(nodeName, nodeAddress).Set(memNotCountedForEvict)
 	}
-	if memReplicationBacklogStr, ok := r["mem_replication_backlog"]; ok {
-		if memReplicationBacklog, err := strconv.Atoi(memReplicationBacklogStr); err == nil {
-			m.MemReplicationBacklog.WithLabelValues(nodeName, nodeAddress).Set(float64(memReplicationBacklog))
-		}
+	if memReplicationBacklog, ok := r.Float("Memory", "mem_replication_backlog"); ok {
+		m.MemReplicationBacklog.WithLabelValues(nodeName, nodeAddress).Set(memReplicationBacklog)
 	}
-	if memClientsSlavesStr, ok := r["mem_clients_slaves"]; ok {
-		if memClientsSlaves, err := strconv.Atoi(memClientsSlavesStr); err == nil {
-			m.MemClientsSlaves.WithLabelValues(nodeName, nodeAddress).Set(float64(memClientsSlaves))
-		}
+	if memClientsSlaves, ok := r.Float("Memory", "mem_clients_slaves"); ok {
+		m.MemClientsSlaves.WithLabelValues(nodeName, nodeAddress).Set(memClientsSlaves)
 	}
-	if memClientsNormalStr, ok := r["mem_clients_normal"]; ok {
-		if memClientsNormal, err := strconv.Atoi(memClientsNormalStr); err == nil {
-			m.MemClientsNormal.WithLabelValues(nodeName, nodeAddress).Set(float64(memClientsNormal))
-		}
+	if memClientsNormal, ok := r.Float("Memory", "mem_clients_normal"); ok {
+		m.MemClientsNormal.WithLabelValues(nodeName, nodeAddress).Set(memClientsNormal)
 	}
-	if memAofBufferStr, ok := r["mem_aof_buffer"]; ok {
-		if memAofBuffer, err := strconv.Atoi(memAofBufferStr); err == nil {
-			m.MemAofBuffer.WithLabelValues(nodeName, nodeAddress).Set(float64(memAofBuffer))
-		}
+	if memAofBuffer, ok := r.Float("Memory", "mem_aof_buffer"); ok {
+		m.MemAofBuffer.WithLabelValues(nodeName, nodeAddress).Set(memAofBuffer)
 	}
-	if memAllocator, ok := r["mem_allocator"]; ok {
+	if memAllocator, ok := r.String("Memory", "mem_allocator"); ok {
 		m.MemAllocator.WithLabelValues(nodeName, nodeAddress, memAllocator).Set(1)
 	}
-	if activeDefragRunningStr, ok := r["active_defrag_running"]; ok {
-		if activeDefragRunning, err := strconv.Atoi(activeDefragRunningStr); err == nil {
-			m.ActiveDefragRunning.WithLabelValues(nodeName, nodeAddress).Set(float64(activeDefragRunning))
-		}
+	if activeDefragRunning, ok := r.Float("Memory", "active_defrag_running"); ok {
+		m.ActiveDefragRunning.WithLabelValues(nodeName, nodeAddress).Set(activeDefragRunning)
 	}
-	if lazyfreePendingObjectsStr, ok := r["lazyfree_pending_objects"]; ok {
-		if lazyfreePendingObjects, err := strconv.Atoi(lazyfreePendingObjectsStr); err == nil {
-			m.LazyfreePendingObjects.WithLabelValues(nodeName, nodeAddress).Set(float64(lazyfreePendingObjects))
-		}
+	if lazyfreePendingObjects, ok := r.Float("Memory", "lazyfree_pending_objects"); ok {
+		m.LazyfreePendingObjects.WithLabelValues(nodeName, nodeAddress).Set(lazyfreePendingObjects)
 	}
 	return nil
 }
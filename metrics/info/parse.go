@@ -0,0 +1,304 @@
+package info
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Section is the fields of an INFO section, keyed by field name.
+type Section map[string]string
+
+// Info is the parsed result of the INFO command. Sections keeps every field
+// under the "# Section" header it follows, fields before the first header
+// are in the "" section. The nested "k=v,k=v" fields are also parsed into
+// the typed slices, in the order INFO lists them.
+type Info struct {
+	// Down is the reason the server is down, empty when INFO succeeded.
+	Down     string
+	Sections map[string]Section
+
+	Keyspace        []KeyspaceDB
+	Replicas        []Replica
+	Commandstats    []Commandstat
+	Errorstats      []Errorstat
+	Latencystats    []Latencystat
+	SentinelMasters []SentinelMaster
+
+	// Errors describes the lines and values that could not be parsed.
+	Errors []string
+}
+
+// KeyspaceDB is a dbN field: db0:keys=1,expires=0,avg_ttl=0
+type KeyspaceDB struct {
+	DB      string
+	Keys    int64
+	Expires int64
+	AvgTTL  int64
+}
+
+// Replica is a slaveN field of a master:
+// slave0:ip=10.0.0.2,port=6379,state=online,offset=2918675,lag=0
+type Replica struct {
+	ID     string
+	IP     string
+	Port   string
+	State  string
+	Offset int64
+	Lag    int64
+}
+
+// Commandstat is a cmdstat_<command> field:
+// cmdstat_get:calls=8,usec=32,usec_per_call=4.00,rejected_calls=0,failed_calls=0
+type Commandstat struct {
+	Command       string
+	Calls         int64
+	Usec          int64
+	UsecPerCall   float64
+	RejectedCalls int64
+	FailedCalls   int64
+}
+
+// Errorstat is an errorstat_<prefix> field: errorstat_ERR:count=1
+type Errorstat struct {
+	Error string
+	Count int64
+}
+
+// Latencystat is a latency_percentiles_usec_<command> field:
+// latency_percentiles_usec_get:p50=1.003,p99=3.007,p99.9=10.047
+type Latencystat struct {
+	Command     string
+	Percentiles map[string]float64
+}
+
+// SentinelMaster is a masterN field of a sentinel:
+// master0:name=mymaster,status=ok,address=10.0.0.1:6379,slaves=2,sentinels=3
+type SentinelMaster struct {
+	ID        string
+	Name      string
+	Status    string
+	Address   string
+	Slaves    int64
+	Sentinels int64
+}
+
+var (
+	keyspaceKey = regexp.MustCompile(`^db[0-9]+$`)
+	replicaKey  = regexp.MustCompile(`^slave[0-9]+$`)
+	masterKey   = regexp.MustCompile(`^master[0-9]+$`)
+)
+
+// ParseInfo parses the result of INFO. Lines may end with "\r\n" or "\n".
+func ParseInfo(raw string) *Info {
+	i := &Info{Sections: make(map[string]Section)}
+	section := ""
+	for n, line := range strings.Split(raw, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			section = strings.TrimSpace(strings.TrimPrefix(line, "#"))
+			continue
+		}
+		item := strings.SplitN(line, ":", 2)
+		if len(item) != 2 || item[0] == "" {
+			i.errorf("line %d: no field name: %q", n+1, line)
+			continue
+		}
+		key, value := item[0], item[1]
+		if i.Sections[section] == nil {
+			i.Sections[section] = make(Section)
+		}
+		i.Sections[section][key] = value
+		i.parseNested(key, value)
+	}
+	return i
+}
+
+// DownInfo returns the Info of a server that is down for reason.
+func DownInfo(reason string) *Info {
+	if reason == "" {
+		reason = RedisServerDownReasonUnknown
+	}
+	return &Info{Down: reason, Sections: make(map[string]Section)}
+}
+
+func (i *Info) errorf(format string, args ...interface{}) {
+	i.Errors = append(i.Errors, fmt.Sprintf(format, args...))
+}
+
+func (i *Info) parseNested(key, value string) {
+	switch {
+	case keyspaceKey.MatchString(key):
+		f := i.fields(key, value)
+		i.Keyspace = append(i.Keyspace, KeyspaceDB{
+			DB:      key,
+			Keys:    f.int("keys"),
+			Expires: f.int("expires"),
+			AvgTTL:  f.int("avg_ttl"),
+		})
+	case replicaKey.MatchString(key) && strings.Contains(value, "="):
+		f := i.fields(key, value)
+		i.Replicas = append(i.Replicas, Replica{
+			ID:     key,
+			IP:     f.values["ip"],
+			Port:   f.values["port"],
+			State:  f.values["state"],
+			Offset: f.int("offset"),
+			Lag:    f.int("lag"),
+		})
+	case strings.HasPrefix(key, "cmdstat_"):
+		f := i.fields(key, value)
+		i.Commandstats = append(i.Commandstats, Commandstat{
+			Command:       strings.TrimPrefix(key, "cmdstat_"),
+			Calls:         f.int("calls"),
+			Usec:          f.int("usec"),
+			UsecPerCall:   f.float("usec_per_call"),
+			RejectedCalls: f.int("rejected_calls"),
+			FailedCalls:   f.int("failed_calls"),
+		})
+	case strings.HasPrefix(key, "errorstat_"):
+		f := i.fields(key, value)
+		i.Errorstats = append(i.Errorstats, Errorstat{
+			Error: strings.TrimPrefix(key, "errorstat_"),
+			Count: f.int("count"),
+		})
+	case strings.HasPrefix(key, "latency_percentiles_usec_"):
+		f := i.fields(key, value)
+		stat := Latencystat{
+			Command:     strings.TrimPrefix(key, "latency_percentiles_usec_"),
+			Percentiles: make(map[string]float64, len(f.keys)),
+		}
+		for _, p := range f.keys {
+			stat.Percentiles[p] = f.float(p)
+		}
+		i.Latencystats = append(i.Latencystats, stat)
+	case masterKey.MatchString(key) && strings.Contains(value, "="):
+		f := i.fields(key, value)
+		i.SentinelMasters = append(i.SentinelMasters, SentinelMaster{
+			ID:        key,
+			Name:      f.values["name"],
+			Status:    f.values["status"],
+			Address:   f.values["address"],
+			Slaves:    f.int("slaves"),
+			Sentinels: f.int("sentinels"),
+		})
+	}
+}
+
+// nestedFields is a parsed "k=v,k=v" value.
+type nestedFields struct {
+	info   *Info
+	key    string
+	keys   []string
+	values map[string]string
+}
+
+// fields parses a "k=v,k=v" value. An item without "=" belongs to the value
+// before it, which contained a ",".
+func (i *Info) fields(key, value string) *nestedFields {
+	f := &nestedFields{info: i, key: key, values: make(map[string]string)}
+	for _, item := range strings.Split(value, ",") {
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) == 2 && kv[0] != "" {
+			f.keys = append(f.keys, kv[0])
+			f.values[kv[0]] = kv[1]
+			continue
+		}
+		if len(f.keys) == 0 {
+			i.errorf("%s: no field name: %q", key, item)
+			continue
+		}
+		last := f.keys[len(f.keys)-1]
+		f.values[last] += "," + item
+	}
+	return f
+}
+
+func (f *nestedFields) int(name string) int64 {
+	value, ok := f.values[name]
+	if !ok {
+		return 0
+	}
+	v, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		f.info.errorf("%s: %s is not an integer: %q", f.key, name, value)
+	}
+	return v
+}
+
+func (f *nestedFields) float(name string) float64 {
+	value, ok := f.values[name]
+	if !ok {
+		return 0
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		f.info.errorf("%s: %s is not a number: %q", f.key, name, value)
+	}
+	return v
+}
+
+// Section returns the fields of the section name, empty when INFO did not
+// return it.
+func (i *Info) Section(name string) Section {
+	if s, ok := i.Sections[name]; ok {
+		return s
+	}
+	return Section{}
+}
+
+// Get returns the field key of whichever section has it, for the callers
+// that do not care about sections.
+func (i *Info) Get(key string) (string, bool) {
+	for _, s := range i.Sections {
+		if value, ok := s[key]; ok {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// Flat returns every field in a single map. Keys present in several
+// sections keep one of their values.
+func (i *Info) Flat() map[string]string {
+	flat := make(map[string]string)
+	for _, s := range i.Sections {
+		for key, value := range s {
+			flat[key] = value
+		}
+	}
+	return flat
+}
+
+// String returns the field key of section.
+func (i *Info) String(section, key string) (string, bool) {
+	value, ok := i.Section(section)[key]
+	return value, ok
+}
+
+// Float returns the field key as a number.
+func (s Section) Float(key string) (float64, bool) {
+	value, ok := s[key]
+	if !ok {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	return v, err == nil
+}
+
+// Float returns the field key of section as a number. A field that is not a
+// number is recorded in Errors, so Float is only for the collectors, which
+// run before the result is shared.
+func (i *Info) Float(section, key string) (float64, bool) {
+	v, ok := i.Section(section).Float(key)
+	if !ok {
+		if value, present := i.Section(section)[key]; present {
+			i.errorf("%s: %s is not a number: %q", section, key, value)
+		}
+	}
+	return v, ok
+}
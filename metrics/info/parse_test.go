@@ -0,0 +1,84 @@
+package info
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseInfoSections(t *testing.T) {
+	// \n-only line endings, a key in two sections, and a line without ':'.
+	raw := "# Server\nredis_version:7.2.4\nrole:sentinel\n\n# Replication\r\nrole:master\r\nbroken line\r\n"
+	i := ParseInfo(raw)
+	if v, _ := i.String("Server", "redis_version"); v != "7.2.4" {
+		t.Errorf("redis_version %q", v)
+	}
+	if v, _ := i.String("Server", "role"); v != "sentinel" {
+		t.Errorf("Server role %q", v)
+	}
+	if v, _ := i.String("Replication", "role"); v != "master" {
+		t.Errorf("Replication role %q", v)
+	}
+	if len(i.Errors) != 1 {
+		t.Errorf("errors %q, want one", i.Errors)
+	}
+}
+
+func TestParseInfoNested(t *testing.T) {
+	raw := "# Replication\r\n" +
+		"slave0:ip=::1,port=6380,state=online,offset=100,lag=1\r\n" +
+		"slave_repl_offset:7\r\n" +
+		"# Commandstats\r\n" +
+		"cmdstat_client|list:calls=2,usec=10,usec_per_call=5.00,rejected_calls=1,failed_calls=0\r\n" +
+		"# Errorstats\r\n" +
+		"errorstat_ERR:count=3\r\n" +
+		"# Latencystats\r\n" +
+		"latency_percentiles_usec_get:p50=1.003,p99.9=10.047\r\n" +
+		"# Sentinel\r\n" +
+		"master0:name=my,master,status=ok,address=10.0.0.1:6379,slaves=2,sentinels=3\r\n" +
+		"# Keyspace\r\n" +
+		"db0:keys=5,expires=x,avg_ttl=0\r\n"
+	i := ParseInfo(raw)
+
+	wantReplicas := []Replica{{ID: "slave0", IP: "::1", Port: "6380", State: "online", Offset: 100, Lag: 1}}
+	if !reflect.DeepEqual(i.Replicas, wantReplicas) {
+		t.Errorf("replicas %+v", i.Replicas)
+	}
+	wantCommands := []Commandstat{{Command: "client|list", Calls: 2, Usec: 10, UsecPerCall: 5, RejectedCalls: 1}}
+	if !reflect.DeepEqual(i.Commandstats, wantCommands) {
+		t.Errorf("commandstats %+v", i.Commandstats)
+	}
+	if !reflect.DeepEqual(i.Errorstats, []Errorstat{{Error: "ERR", Count: 3}}) {
+		t.Errorf("errorstats %+v", i.Errorstats)
+	}
+	wantLatency := []Latencystat{{Command: "get", Percentiles: map[string]float64{"p50": 1.003, "p99.9": 10.047}}}
+	if !reflect.DeepEqual(i.Latencystats, wantLatency) {
+		t.Errorf("latencystats %+v", i.Latencystats)
+	}
+	// A value with a "," keeps it.
+	wantMasters := []SentinelMaster{{ID: "master0", Name: "my,master", Status: "ok", Address: "10.0.0.1:6379", Slaves: 2, Sentinels: 3}}
+	if !reflect.DeepEqual(i.SentinelMasters, wantMasters) {
+		t.Errorf("sentinel masters %+v", i.SentinelMasters)
+	}
+	if !reflect.DeepEqual(i.Keyspace, []KeyspaceDB{{DB: "db0", Keys: 5}}) {
+		t.Errorf("keyspace %+v", i.Keyspace)
+	}
+	if len(i.Errors) != 1 {
+		t.Errorf("errors %q, want the expires of db0", i.Errors)
+	}
+}
+
+func TestInfoFloat(t *testing.T) {
+	i := ParseInfo("# Memory\r\nused_memory:1024\r\nmem_fragmentation_ratio:oops\r\n")
+	if v, ok := i.Float("Memory", "used_memory"); !ok || v != 1024 {
+		t.Errorf("used_memory %v %v", v, ok)
+	}
+	if _, ok := i.Float("Memory", "mem_fragmentation_ratio"); ok {
+		t.Error("mem_fragmentation_ratio parsed")
+	}
+	if _, ok := i.Float("Stats", "used_memory"); ok {
+		t.Error("used_memory found in Stats")
+	}
+	if len(i.Errors) != 1 {
+		t.Errorf("errors %q, want one", i.Errors)
+	}
+}
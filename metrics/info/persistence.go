@@ -2,7 +2,6 @@ package info
 
 import (
 	"github.com/prometheus/client_golang/prometheus"
-	"strconv"
 )
 
 type RedisPersistenceCollector struct {
@@ -263,30 +262,43 @@ func (m *RedisPersistenceCollector) Unregister(registry *prometheus.Registry) bo
 	return true
 }
 
-func (m *RedisPersistenceCollector) Set(nodeName, nodeAddress string, r map[string]string) error {
-	if loadingStr, ok := r["loading"]; ok {
-		if loading, err := strconv.Atoi(loadingStr); err == nil {
-			m.Loading.WithLabelValues(nodeName, nodeAddress).Set(float64(loading))
-		}
+func (m *RedisPersistenceCollector) Delete(nodeName, nodeAddress string) {
+	deleteNodeSeries(nodeName, nodeAddress,
+		m.Loading,
+		m.RdbChangesSinceLastSave,
+		m.RdbBgsaveInProgress,
+		m.RdbLastSaveTime,
+		m.RdbLastBgsaveStatus,
+		m.RdbLastBgsaveTimeSec,
+		m.RdbCurrentBgsaveTimeSec,
+		m.RdbLastCowSize,
+		m.AofEnabled,
+		m.AofRewriteInProgress,
+		m.AofRewriteScheduled,
+		m.AofLastRewriteTimeSec,
+		m.AofCurrentRewriteTimeSec,
+		m.AofLastBgrewriteStatus,
+		m.AofLastWriteStatus,
+		m.AofLastCowSize,
+	)
+}
+
+func (m *RedisPersistenceCollector) Set(nodeName, nodeAddress string, r *Info) error {
+	if loading, ok := r.Float("Persistence", "loading"); ok {
+		m.Loading.WithLabelValues(nodeName, nodeAddress).Set(loading)
 	}
-	if rdbChangersSinceLastSaveStr, ok := r["rdb_changes_since_last_save"]; ok {
-		if rdbChangersSinceLastSave, err := strconv.Atoi(rdbChangersSinceLastSaveStr); err == nil {
-			m.RdbChangesSinceLastSave.WithLabelValues(nodeName, nodeAddress).Set(
-				float64(rdbChangersSinceLastSave))
-		}
+	if rdbChangersSinceLastSave, ok := r.Float("Persistence", "rdb_changes_since_last_save"); ok {
+		m.RdbChangesSinceLastSave.WithLabelValues(nodeName, nodeAddress).Set(
+			rdbChangersSinceLastSave)
 	}
-	if rdbBgsaveInProgressStr, ok := r["rdb_bgsave_in_progress"]; ok {
-		if rdbBgsaveInProgress, err := strconv.Atoi(rdbBgsaveInProgressStr); err == nil {
-			m.RdbBgsaveInProgress.WithLabelValues(nodeName, nodeAddress).Set(
-				float64(rdbBgsaveInProgress))
-		}
+	if rdbBgsaveInProgress, ok := r.Float("Persistence", "rdb_bgsave_in_progress"); ok {
+		m.RdbBgsaveInProgress.WithLabelValues(nodeName, nodeAddress).Set(
+			rdbBgsaveInProgress)
 	}
-	if rdbLastSaveTimeStr, ok := r["rdb_last_save_time"]; ok {
-		if rdbLastSaveTime, err := strconv.Atoi(rdbLastSaveTimeStr); err == nil {
-			m.RdbLastSaveTime.WithLabelValues(nodeName, nodeAddress).Set(float64(rdbLastSaveTime))
-		}
+	if rdbLastSaveTime, ok := r.Float("Persistence", "rdb_last_save_time"); ok {
+		m.RdbLastSaveTime.WithLabelValues(nodeName, nodeAddress).Set(rdbLastSaveTime)
 	}
-	if rdbLastBgsaveStatus, ok := r["rdb_last_bgsave_status"]; ok {
+	if rdbLastBgsaveStatus, ok := r.String("Persistence", "rdb_last_bgsave_status"); ok {
 		switch rdbLastBgsaveStatus {
 		case "ok":
 			m.RdbLastBgsaveStatus.WithLabelValues(nodeName, nodeAddress).Set(1)
@@ -294,52 +306,36 @@ func (m *RedisPersistenceCollector) Set(nodeName, nodeAddress string, r map[stri
 			m.RdbLastBgsaveStatus.WithLabelValues(nodeName, nodeAddress).Set(0)
 		}
 	}
-	if rdbLastBgsaveTimeSecStr, ok := r["rdb_last_bgsave_time_sec"]; ok {
-		if rdbLastBgsaveTimeSec, err := strconv.Atoi(rdbLastBgsaveTimeSecStr); err == nil {
-			m.RdbLastBgsaveTimeSec.WithLabelValues(nodeName, nodeAddress).Set(
-				float64(rdbLastBgsaveTimeSec))
-		}
+	if rdbLastBgsaveTimeSec, ok := r.Float("Persistence", "rdb_last_bgsave_time_sec"); ok {
+		m.RdbLastBgsaveTimeSec.WithLabelValues(nodeName, nodeAddress).Set(
+			rdbLastBgsaveTimeSec)
 	}
-	if rdbCurrentBgsaveTimeSecStr, ok := r["rdb_current_bgsave_time_sec"]; ok {
-		if rdbCurrentBgsaveTimeSec, err := strconv.Atoi(rdbCurrentBgsaveTimeSecStr); err == nil {
-			m.RdbCurrentBgsaveTimeSec.WithLabelValues(nodeName, nodeAddress).Set(
-				float64(rdbCurrentBgsaveTimeSec))
-		}
+	if rdbCurrentBgsaveTimeSec, ok := r.Float("Persistence", "rdb_current_bgsave_time_sec"); ok {
+		m.RdbCurrentBgsaveTimeSec.WithLabelValues(nodeName, nodeAddress).Set(
+			rdbCurrentBgsaveTimeSec)
 	}
-	if rdbLastCowSizeStr, ok := r["rdb_last_cow_size"]; ok {
-		if rdbLastCowSize, err := strconv.Atoi(rdbLastCowSizeStr); err == nil {
-			m.RdbLastCowSize.WithLabelValues(nodeName, nodeAddress).Set(float64(rdbLastCowSize))
-		}
+	if rdbLastCowSize, ok := r.Float("Persistence", "rdb_last_cow_size"); ok {
+		m.RdbLastCowSize.WithLabelValues(nodeName, nodeAddress).Set(rdbLastCowSize)
 	}
-	if aofEnabledStr, ok := r["aof_enabled"]; ok {
-		if aofEnabled, err := strconv.Atoi(aofEnabledStr); err == nil {
-			m.AofEnabled.WithLabelValues(nodeName, nodeAddress).Set(float64(aofEnabled))
-		}
+	if aofEnabled, ok := r.Float("Persistence", "aof_enabled"); ok {
+		m.AofEnabled.WithLabelValues(nodeName, nodeAddress).Set(aofEnabled)
 	}
-	if aofRewriteInProgressStr, ok := r["aof_rewrite_in_progress"]; ok {
-		if aofRewriteInProgress, err := strconv.Atoi(aofRewriteInProgressStr); err == nil {
-			m.AofRewriteInProgress.WithLabelValues(nodeName, nodeAddress).Set(
-				float64(aofRewriteInProgress))
-		}
+	if aofRewriteInProgress, ok := r.Float("Persistence", "aof_rewrite_in_progress"); ok {
+		m.AofRewriteInProgress.WithLabelValues(nodeName, nodeAddress).Set(
+			aofRewriteInProgress)
 	}
-	if aofRewriteScheduledStr, ok := r["aof_rewrite_scheduled"]; ok {
-		if aofRewriteScheduled, err := strconv.Atoi(aofRewriteScheduledStr); err == nil {
-			m.AofRewriteScheduled.WithLabelValues(nodeName, nodeAddress).Set(float64(aofRewriteScheduled))
-		}
+	if aofRewriteScheduled, ok := r.Float("Persistence", "aof_rewrite_scheduled"); ok {
+		m.AofRewriteScheduled.WithLabelValues(nodeName, nodeAddress).Set(aofRewriteScheduled)
 	}
-	if aofLastRewriteTimeSecStr, ok := r["aof_last_rewrite_time_sec"]; ok {
-		if aofLastRewriteTimeSec, err := strconv.Atoi(aofLastRewriteTimeSecStr); err == nil {
-			m.AofLastRewriteTimeSec.WithLabelValues(nodeName, nodeAddress).Set(
-				float64(aofLastRewriteTimeSec))
-		}
+	if aofLastRewriteTimeSec, ok := r.Float("Persistence", "aof_last_rewrite_time_sec"); ok {
+		m.AofLastRewriteTimeSec.WithLabelValues(nodeName, nodeAddress).Set(
+			aofLastRewriteTimeSec)
 	}
-	if aofCurrentRewriteTimeSecStr, ok := r["aof_current_rewrite_time_sec"]; ok {
-		if aofCurrentRewriteTimeSec, err := strconv.Atoi(aofCurrentRewriteTimeSecStr); err == nil {
-			m.AofCurrentRewriteTimeSec.WithLabelValues(nodeName, nodeAddress).Set(
-				float64(aofCurrentRewriteTimeSec))
-		}
+	if aofCurrentRewriteTimeSec, ok := r.Float("Persistence", "aof_current_rewrite_time_sec"); ok {
+		m.AofCurrentRewriteTimeSec.WithLabelValues(nodeName, nodeAddress).Set(
+			aofCurrentRewriteTimeSec)
 	}
-	if aofLastBgrewriteStatus, ok := r["aof_last_bgrewrite_status"]; ok {
+	if aofLastBgrewriteStatus, ok := r.String("Persistence", "aof_last_bgrewrite_status"); ok {
 		switch aofLastBgrewriteStatus {
 		case "ok":
 			m.AofLastBgrewriteStatus.WithLabelValues(nodeName, nodeAddress).Set(1)
@@ -347,7 +343,7 @@ func (m *RedisPersistenceCollector) Set(nodeName, nodeAddress string, r map[stri
 			m.AofLastBgrewriteStatus.WithLabelValues(nodeName, nodeAddress).Set(0)
 		}
 	}
-	if aofLastWriteStatus, ok := r["aof_last_write_status"]; ok {
+	if aofLastWriteStatus, ok := r.String("Persistence", "aof_last_write_status"); ok {
 		switch aofLastWriteStatus {
 		case "ok":
 			m.AofLastWriteStatus.WithLabelValues(nodeName, nodeAddress).Set(1)
@@ -355,10 +351,8 @@ func (m *RedisPersistenceCollector) Set(nodeName, nodeAddress string, r map[stri
 			m.AofLastWriteStatus.WithLabelValues(nodeName, nodeAddress).Set(0)
 		}
 	}
-	if aofLastCowSizeStr, ok := r["aof_last_cow_size"]; ok {
-		if aofLastCowSize, err := strconv.Atoi(aofLastCowSizeStr); err == nil {
-			m.AofLastCowSize.WithLabelValues(nodeName, nodeAddress).Set(float64(aofLastCowSize))
-		}
+	if aofLastCowSize, ok := r.Float("Persistence", "aof_last_cow_size"); ok {
+		m.AofLastCowSize.WithLabelValues(nodeName, nodeAddress).Set(aofLastCowSize)
 	}
 	return nil
 }
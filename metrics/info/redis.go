@@ -1,7 +1,10 @@
 package info
 
 import (
+	"time"
+
 	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -15,7 +18,8 @@ var (
 type Collector interface {
 	MustRegister(registry *prometheus.Registry)
 	Unregister(registry *prometheus.Registry) bool
-	Set(nodeName, nodeAddress string, r map[string]string) error
+	Set(nodeName, nodeAddress string, r *Info) error
+	Delete(nodeName, nodeAddress string)
 }
 
 type RedisCollector map[string]Collector
@@ -33,6 +37,7 @@ func NewRedisCollector() RedisCollector {
 		"Cluster":      NewRedisClusterCollector(),
 		"Keyspace":     NewRedisKeyspaceCollector(),
 		"Sentinel":     NewRedisSentinelCollector(),
+		"Derived":      NewRedisDerivedCollector(),
 	}
 }
 
@@ -65,7 +70,7 @@ func (m RedisCollector) Unregister(registry *prometheus.Registry) bool {
 	return true
 }
 
-func (m RedisCollector) Set(nodeName, nodeAddress string, r map[string]string) error {
+func (m RedisCollector) Set(nodeName, nodeAddress string, r *Info) error {
 
 	for _, section := range RedisInfoSections {
 		log.WithFields(log.Fields{
@@ -85,5 +90,85 @@ func (m RedisCollector) Set(nodeName, nodeAddress string, r map[string]string) e
 			continue
 		}
 	}
+	// Derived values read the sections set above.
+	if metrics, ok := m["Derived"]; ok {
+		if err := metrics.Set(nodeName, nodeAddress, r); err != nil {
+			return err
+		}
+	}
+	// The collectors add the values they could not parse to r.Errors.
+	if metrics, ok := m["Server"].(*RedisServerCollector); ok {
+		metrics.SetInfoParseErrors(nodeName, nodeAddress, len(r.Errors))
+	}
+	for _, e := range r.Errors {
+		log.WithFields(log.Fields{
+			"node": nodeName,
+			"addr": nodeAddress,
+		}).Warnf("Parse INFO error: %s", e)
+	}
 	return nil
 }
+
+func (m RedisCollector) SetPingLatency(nodeName, nodeAddress string, latency time.Duration) {
+	if metrics, ok := m["Server"].(*RedisServerCollector); ok {
+		metrics.SetPingLatency(nodeName, nodeAddress, latency)
+	}
+}
+
+// SetMasterName sets the function resolving the master of a replica to the
+// node_name of a target.
+func (m RedisCollector) SetMasterName(masterName func(host, port string) string) {
+	if metrics, ok := m["Replication"].(*RedisReplicationCollector); ok {
+		metrics.MasterName = masterName
+	}
+}
+
+// MasterChangedFrom returns the address of the previous master of a replica
+// when the last Set saw its master change.
+func (m RedisCollector) MasterChangedFrom(nodeName, nodeAddress string) (string, bool) {
+	if metrics, ok := m["Replication"].(*RedisReplicationCollector); ok {
+		return metrics.MasterChangedFrom(nodeName, nodeAddress)
+	}
+	return "", false
+}
+
+func (m RedisCollector) Delete(nodeName, nodeAddress string) {
+	for _, metrics := range m {
+		metrics.Delete(nodeName, nodeAddress)
+	}
+}
+
+type metricVec interface {
+	prometheus.Collector
+	Delete(labels prometheus.Labels) bool
+}
+
+// deleteNodeSeries removes every series of the given vectors whose node_name
+// and node_address labels match, whatever their other labels are.
+func deleteNodeSeries(nodeName, nodeAddress string, vecs ...metricVec) {
+	for _, vec := range vecs {
+		var matched []prometheus.Labels
+		ch := make(chan prometheus.Metric)
+		go func() {
+			vec.Collect(ch)
+			close(ch)
+		}()
+		for metric := range ch {
+			var pb dto.Metric
+			if err := metric.Write(&pb); err != nil {
+				continue
+			}
+			labels := prometheus.Labels{}
+			for _, label := range pb.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if labels["node_name"] == nodeName && labels["node_address"] == nodeAddress {
+				matched = append(matched, labels)
+			}
+		}
+		// Delete only after Collect has returned, it holds the vector's lock.
+		for _, labels := range matched {
+			vec.Delete(labels)
+		}
+	}
+}
@@ -2,8 +2,8 @@ package info
 
 import (
 	"fmt"
-	"strconv"
-	"strings"
+	"net"
+	"sync"
 
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -32,7 +32,8 @@ type RedisReplicationCollector struct {
 	ReplBacklogSize            *prometheus.GaugeVec
 	ReplBacklogFirstByteOffset *prometheus.GaugeVec
 	ReplBacklogHistlen         *prometheus.GaugeVec
-	MasterHostPort             *prometheus.CounterVec
+	MasterInfo                 *prometheus.GaugeVec
+	MasterChanges              *prometheus.CounterVec
 	MasterLinkStatus           *prometheus.GaugeVec
 	MasterLastIOSecondsAgo     *prometheus.GaugeVec
 	MasterSyncInProgress       *prometheus.GaugeVec
@@ -46,6 +47,25 @@ type RedisReplicationCollector struct {
 	SlaveState                 *prometheus.GaugeVec
 	SlaveOffset                *prometheus.GaugeVec
 	SlaveLag                   *prometheus.GaugeVec
+
+	// MasterName returns the node_name of the target at the master's
+	// address, or "" when the master is not a target.
+	MasterName func(host, port string) string
+
+	masters *replicaMasters
+}
+
+// replicaMasters is the master each replica was last seen replicating from.
+type replicaMasters struct {
+	mu      sync.Mutex
+	masters map[string]replicaMaster
+}
+
+type replicaMaster struct {
+	host, port, name string
+	// changedFrom is the address of the previous master when the last Set
+	// saw the master change.
+	changedFrom string
 }
 
 func NewRedisReplicationCollector() *RedisReplicationCollector {
@@ -174,14 +194,23 @@ func NewRedisReplicationCollector() *RedisReplicationCollector {
 		},
 			[]string{"node_name", "node_address"})
 
-		// master_host
-		redisReplicationMasterHostPort = prometheus.NewCounterVec(prometheus.CounterOpts{
+		// master_host, master_port
+		redisReplicationMasterInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "redis",
+			Subsystem: "replication",
+			Name:      "master_info",
+			Help:      "Master a replica replicates from, with the node_name of the master when it is a target.",
+		},
+			[]string{"node_name", "node_address", "master_host", "master_port", "master_name"})
+
+		// master changes
+		redisReplicationMasterChanges = prometheus.NewCounterVec(prometheus.CounterOpts{
 			Namespace: "redis",
 			Subsystem: "replication",
-			Name:      "master",
-			Help:      "Host or IP address of the master. Master listening TCP port.",
+			Name:      "master_changes_total",
+			Help:      "Number of times the master of a replica was seen to change.",
 		},
-			[]string{"node_name", "node_address", "host", "port"})
+			[]string{"node_name", "node_address"})
 
 		// master_port
 		//RedisReplicationMasterPort = prometheus.NewGaugeVec(prometheus.GaugeOpts{
@@ -329,7 +358,8 @@ func NewRedisReplicationCollector() *RedisReplicationCollector {
 		redisReplicationReplBacklogSize,
 		redisReplicationReplBacklogFirstByteOffset,
 		redisReplicationReplBacklogHistlen,
-		redisReplicationMasterHostPort,
+		redisReplicationMasterInfo,
+		redisReplicationMasterChanges,
 		redisReplicationMasterLinkStatus,
 		redisReplicationMasterLastIOSecondsAgo,
 		redisReplicationMasterSyncInProgress,
@@ -343,6 +373,8 @@ func NewRedisReplicationCollector() *RedisReplicationCollector {
 		redisReplicationSlaveState,
 		redisReplicationSlaveOffset,
 		redisReplicationSlaveLag,
+		nil,
+		&replicaMasters{masters: make(map[string]replicaMaster)},
 	}
 }
 
@@ -356,7 +388,8 @@ func (m *RedisReplicationCollector) MustRegister(registry *prometheus.Registry)
 	registry.MustRegister(m.ReplBacklogSize)
 	registry.MustRegister(m.ReplBacklogFirstByteOffset)
 	registry.MustRegister(m.ReplBacklogHistlen)
-	registry.MustRegister(m.MasterHostPort)
+	registry.MustRegister(m.MasterInfo)
+	registry.MustRegister(m.MasterChanges)
 	registry.MustRegister(m.MasterLinkStatus)
 	registry.MustRegister(m.MasterLastIOSecondsAgo)
 	registry.MustRegister(m.MasterSyncInProgress)
@@ -398,7 +431,10 @@ func (m *RedisReplicationCollector) Unregister(registry *prometheus.Registry) bo
 	if !registry.Unregister(m.ReplBacklogHistlen) {
 		return false
 	}
-	if !registry.Unregister(m.MasterHostPort) {
+	if !registry.Unregister(m.MasterInfo) {
+		return false
+	}
+	if !registry.Unregister(m.MasterChanges) {
 		return false
 	}
 	if !registry.Unregister(m.MasterLinkStatus) {
@@ -443,9 +479,40 @@ func (m *RedisReplicationCollector) Unregister(registry *prometheus.Registry) bo
 	return true
 }
 
-func (m *RedisReplicationCollector) Set(nodeName, nodeAddress string, r map[string]string) error {
+func (m *RedisReplicationCollector) Delete(nodeName, nodeAddress string) {
+	deleteNodeSeries(nodeName, nodeAddress,
+		m.Role,
+		m.ConnectedSlaves,
+		m.MasterReplOffset,
+		m.SecondReplOffset,
+		m.ReplBacklogActive,
+		m.ReplBacklogSize,
+		m.ReplBacklogFirstByteOffset,
+		m.ReplBacklogHistlen,
+		m.MasterInfo,
+		m.MasterChanges,
+		m.MasterLinkStatus,
+		m.MasterLastIOSecondsAgo,
+		m.MasterSyncInProgress,
+		m.SlaveReplOffset,
+		m.SlavePriority,
+		m.SlaveReadOnly,
+		m.MasterSyncLeftBytes,
+		m.MasterSyncLastIOSecondsAgo,
+		m.MasterLinkDownSinceSeconds,
+		m.MinSlavesGoodSlaves,
+		m.SlaveState,
+		m.SlaveOffset,
+		m.SlaveLag,
+	)
+	m.masters.mu.Lock()
+	delete(m.masters.masters, nodeName+"\x00"+nodeAddress)
+	m.masters.mu.Unlock()
+}
+
+func (m *RedisReplicationCollector) Set(nodeName, nodeAddress string, r *Info) error {
 	// role:master
-	if role, ok := r["role"]; ok {
+	if role, ok := r.String("Replication", "role"); ok {
 		switch role {
 		case "master":
 			m.Role.WithLabelValues(nodeName, nodeAddress).Set(float64(RedisReplicationRoleMaster))
@@ -454,57 +521,46 @@ func (m *RedisReplicationCollector) Set(nodeName, nodeAddress string, r map[stri
 		}
 	}
 	//connected_slaves:2
-	if connectedSlavesStr, ok := r["connected_slaves"]; ok {
-		if connectedSlaves, err := strconv.Atoi(connectedSlavesStr); err == nil {
-			m.ConnectedSlaves.WithLabelValues(nodeName, nodeAddress).Set(float64(connectedSlaves))
-		}
+	if connectedSlaves, ok := r.Float("Replication", "connected_slaves"); ok {
+		m.ConnectedSlaves.WithLabelValues(nodeName, nodeAddress).Set(connectedSlaves)
 	}
 	//master_repl_offset:2918675
-	if masterReplOffsetStr, ok := r["master_repl_offset"]; ok {
-		if masterReplOffset, err := strconv.Atoi(masterReplOffsetStr); err == nil {
-			m.MasterReplOffset.WithLabelValues(nodeName, nodeAddress).Set(float64(masterReplOffset))
-		}
+	if masterReplOffset, ok := r.Float("Replication", "master_repl_offset"); ok {
+		m.MasterReplOffset.WithLabelValues(nodeName, nodeAddress).Set(masterReplOffset)
 	}
 	//second_repl_offset:-1
-	if secondReplOffsetStr, ok := r["second_repl_offset"]; ok {
-		if secondReplOffset, err := strconv.Atoi(secondReplOffsetStr); err == nil {
-			m.SecondReplOffset.WithLabelValues(nodeName, nodeAddress).Set(float64(secondReplOffset))
-		}
+	if secondReplOffset, ok := r.Float("Replication", "second_repl_offset"); ok {
+		m.SecondReplOffset.WithLabelValues(nodeName, nodeAddress).Set(secondReplOffset)
 	}
 	//repl_backlog_active:1
-	if replBacklogActiveStr, ok := r["repl_backlog_active"]; ok {
-		if replBacklogActive, err := strconv.Atoi(replBacklogActiveStr); err == nil {
-			m.ReplBacklogActive.WithLabelValues(nodeName, nodeAddress).Set(float64(replBacklogActive))
-		}
+	if replBacklogActive, ok := r.Float("Replication", "repl_backlog_active"); ok {
+		m.ReplBacklogActive.WithLabelValues(nodeName, nodeAddress).Set(replBacklogActive)
 	}
 	//repl_backlog_size:1048576
-	if replBacklogSizeStr, ok := r["repl_backlog_size"]; ok {
-		if replBacklogSize, err := strconv.Atoi(replBacklogSizeStr); err == nil {
-			m.ReplBacklogSize.WithLabelValues(nodeName, nodeAddress).Set(float64(replBacklogSize))
-		}
+	if replBacklogSize, ok := r.Float("Replication", "repl_backlog_size"); ok {
+		m.ReplBacklogSize.WithLabelValues(nodeName, nodeAddress).Set(replBacklogSize)
 	}
 	//repl_backlog_first_byte_offset:1870100
-	if replBacklogFirstByteOffsetStr, ok := r["repl_backlog_first_byte_offset"]; ok {
-		if replBacklogFirstByteOffset, err := strconv.Atoi(replBacklogFirstByteOffsetStr); err == nil {
-			m.ReplBacklogFirstByteOffset.WithLabelValues(nodeName, nodeAddress).Set(
-				float64(replBacklogFirstByteOffset))
-		}
+	if replBacklogFirstByteOffset, ok := r.Float("Replication", "repl_backlog_first_byte_offset"); ok {
+		m.ReplBacklogFirstByteOffset.WithLabelValues(nodeName, nodeAddress).Set(
+			replBacklogFirstByteOffset)
 	}
 	//repl_backlog_histlen:1048576
-	if replBacklogHistlenStr, ok := r["repl_backlog_histlen"]; ok {
-		if replBacklogHistlen, err := strconv.Atoi(replBacklogHistlenStr); err == nil {
-			m.ReplBacklogHistlen.WithLabelValues(nodeName, nodeAddress).Set(float64(replBacklogHistlen))
-		}
+	if replBacklogHistlen, ok := r.Float("Replication", "repl_backlog_histlen"); ok {
+		m.ReplBacklogHistlen.WithLabelValues(nodeName, nodeAddress).Set(replBacklogHistlen)
 	}
 	// master_host:172.25.1.3
 	// master_port:6379
-	masterHost, hasMasterHost := r["masterHost"]
-	masterPort, hasMasterPort := r["masterPort"]
-	if hasMasterHost && hasMasterPort {
-		m.MasterHostPort.WithLabelValues(nodeName, nodeAddress, masterHost, masterPort).Inc()
+	if role, _ := r.String("Replication", "role"); role == "slave" {
+		masterHost, _ := r.String("Replication", "master_host")
+		masterPort, _ := r.String("Replication", "master_port")
+		m.setMaster(nodeName, nodeAddress, masterHost, masterPort)
+	} else {
+		deleteNodeSeries(nodeName, nodeAddress, m.MasterInfo)
+		m.masters.clearChange(nodeName + "\x00" + nodeAddress)
 	}
 	//master_link_status:up
-	if masterLinkStatus, ok := r["master_link_status"]; ok {
+	if masterLinkStatus, ok := r.String("Replication", "master_link_status"); ok {
 		switch masterLinkStatus {
 		case "up":
 			m.MasterLinkStatus.WithLabelValues(nodeName, nodeAddress).Set(
@@ -516,80 +572,92 @@ func (m *RedisReplicationCollector) Set(nodeName, nodeAddress string, r map[stri
 
 	}
 	//master_last_io_seconds_ago:1
-	if masterLastIOSecondsAgoStr, ok := r["master_last_io_seconds_ago"]; ok {
-		if masterLastIOSecondsAgo, err := strconv.Atoi(masterLastIOSecondsAgoStr); err == nil {
-			m.MasterLastIOSecondsAgo.WithLabelValues(nodeName, nodeAddress).Set(
-				float64(masterLastIOSecondsAgo))
-		}
+	if masterLastIOSecondsAgo, ok := r.Float("Replication", "master_last_io_seconds_ago"); ok {
+		m.MasterLastIOSecondsAgo.WithLabelValues(nodeName, nodeAddress).Set(
+			masterLastIOSecondsAgo)
 	}
 	//master_sync_in_progress:0
-	if masterSyncInProgressStr, ok := r["master_sync_in_progress"]; ok {
-		if masterSyncInProgress, err := strconv.Atoi(masterSyncInProgressStr); err == nil {
-			m.MasterSyncInProgress.WithLabelValues(nodeName, nodeAddress).Set(
-				float64(masterSyncInProgress))
-		}
+	if masterSyncInProgress, ok := r.Float("Replication", "master_sync_in_progress"); ok {
+		m.MasterSyncInProgress.WithLabelValues(nodeName, nodeAddress).Set(
+			masterSyncInProgress)
 	}
 	//slave_repl_offset:5084610
-	if slaveReplOffsetStr, ok := r["slave_repl_offset"]; ok {
-		if slaveReplOffset, err := strconv.Atoi(slaveReplOffsetStr); err == nil {
-			m.SlaveReplOffset.WithLabelValues(nodeName, nodeAddress).Set(float64(slaveReplOffset))
-		}
+	if slaveReplOffset, ok := r.Float("Replication", "slave_repl_offset"); ok {
+		m.SlaveReplOffset.WithLabelValues(nodeName, nodeAddress).Set(slaveReplOffset)
 	}
 	//slave_priority:100
-	if slavePriorityStr, ok := r["slave_priority"]; ok {
-		if slavePriority, err := strconv.Atoi(slavePriorityStr); err == nil {
-			m.SlavePriority.WithLabelValues(nodeName, nodeAddress).Set(float64(slavePriority))
-		}
+	if slavePriority, ok := r.Float("Replication", "slave_priority"); ok {
+		m.SlavePriority.WithLabelValues(nodeName, nodeAddress).Set(slavePriority)
 	}
 	//slave_read_only:1
-	if slaveReadOnlyStr, ok := r["slave_read_only"]; ok {
-		if slaveReadOnly, err := strconv.Atoi(slaveReadOnlyStr); err == nil {
-			m.SlaveReadOnly.WithLabelValues(nodeName, nodeAddress).Set(float64(slaveReadOnly))
-		}
+	if slaveReadOnly, ok := r.Float("Replication", "slave_read_only"); ok {
+		m.SlaveReadOnly.WithLabelValues(nodeName, nodeAddress).Set(slaveReadOnly)
 	}
 	//slave0:ip=172.25.1.5,port=6379,state=online,offset=2918675,lag=0
 	//slave1:ip=172.25.1.4,port=6379,state=online,offset=2918540,lag=1
-	for key, value := range r {
-		ok1 := strings.Contains(key, "slave")
-		ok2 := strings.Contains(value, "state=")
-		if ok1 && ok2 {
-			valueMap := stringToMap(value)
-			addr := fmt.Sprintf("%s:%s", valueMap["ip"], valueMap["port"])
-			if state, ok := valueMap["state"]; ok {
-				switch state {
-				case "online":
-					m.SlaveState.WithLabelValues(nodeName, nodeAddress, key, addr).Set(float64(
-						RedisReplicationSlaveStateOnline))
-				default:
-					m.SlaveState.WithLabelValues(nodeName, nodeAddress, key, addr).Set(float64(
-						RedisReplicationSlaveStateOffline))
-				}
-			}
-			if offsetStr, ok := valueMap["offset"]; ok {
-				if offset, err := strconv.Atoi(offsetStr); err == nil {
-					m.SlaveOffset.WithLabelValues(nodeName, nodeAddress, key, addr).Set(float64(offset))
-				}
-			}
-			if lagStr, ok := valueMap["lag"]; ok {
-				if lag, err := strconv.Atoi(lagStr); err == nil {
-					m.SlaveLag.WithLabelValues(nodeName, nodeAddress, key, addr).Set(float64(lag))
-				}
-			}
-
+	for _, replica := range r.Replicas {
+		addr := fmt.Sprintf("%s:%s", replica.IP, replica.Port)
+		switch replica.State {
+		case "online":
+			m.SlaveState.WithLabelValues(nodeName, nodeAddress, replica.ID, addr).Set(float64(
+				RedisReplicationSlaveStateOnline))
+		default:
+			m.SlaveState.WithLabelValues(nodeName, nodeAddress, replica.ID, addr).Set(float64(
+				RedisReplicationSlaveStateOffline))
 		}
+		m.SlaveOffset.WithLabelValues(nodeName, nodeAddress, replica.ID, addr).Set(float64(replica.Offset))
+		m.SlaveLag.WithLabelValues(nodeName, nodeAddress, replica.ID, addr).Set(float64(replica.Lag))
 	}
 
 	return nil
 }
 
-func stringToMap(v string) map[string]string {
-	m := make(map[string]string)
-	values := strings.Split(v, ",")
-	for _, item := range values {
-		keyValue := strings.Split(item, "=")
-		if len(keyValue) == 2 {
-			m[keyValue[0]] = keyValue[1]
-		}
+// setMaster sets the master info of a replica, replacing the series of its
+// previous master, and counts the changes of master.
+func (m *RedisReplicationCollector) setMaster(nodeName, nodeAddress, host, port string) {
+	current := replicaMaster{host: host, port: port}
+	if m.MasterName != nil {
+		current.name = m.MasterName(host, port)
 	}
-	return m
+	key := nodeName + "\x00" + nodeAddress
+	m.masters.mu.Lock()
+	previous, seen := m.masters.masters[key]
+	changed := seen && (previous.host != host || previous.port != port)
+	if changed {
+		current.changedFrom = net.JoinHostPort(previous.host, previous.port)
+	}
+	m.masters.masters[key] = current
+	m.masters.mu.Unlock()
+
+	if changed {
+		m.MasterChanges.WithLabelValues(nodeName, nodeAddress).Inc()
+	}
+	// Create the counter at zero for the replicas that never changed master.
+	m.MasterChanges.WithLabelValues(nodeName, nodeAddress)
+	if changed || seen && previous.name != current.name {
+		m.MasterInfo.DeleteLabelValues(nodeName, nodeAddress, previous.host, previous.port, previous.name)
+	}
+	m.MasterInfo.WithLabelValues(nodeName, nodeAddress, host, port, current.name).Set(1)
+}
+
+// clearChange forgets the change of master seen by an earlier Set, once the
+// node is no longer a replica. The master is kept to count the change when
+// the node replicates from another one.
+func (m *replicaMasters) clearChange(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if master, ok := m.masters[key]; ok {
+		master.changedFrom = ""
+		m.masters[key] = master
+	}
+}
+
+// MasterChangedFrom returns the address of the previous master of a replica
+// when the last Set saw its master change, the change counted in
+// MasterChanges.
+func (m *RedisReplicationCollector) MasterChangedFrom(nodeName, nodeAddress string) (string, bool) {
+	m.masters.mu.Lock()
+	defer m.masters.mu.Unlock()
+	from := m.masters.masters[nodeName+"\x00"+nodeAddress].changedFrom
+	return from, from != ""
 }
@@ -0,0 +1,70 @@
+package info
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestReplicationMaster(t *testing.T) {
+	m := NewRedisReplicationCollector()
+	m.MasterName = func(host, port string) string {
+		if host == "10.0.0.1" {
+			return "master-a"
+		}
+		return ""
+	}
+	replica := func(host string) *Info {
+		return ParseInfo("# Replication\r\nrole:slave\r\nmaster_host:" + host + "\r\nmaster_port:6379\r\n")
+	}
+
+	m.Set("replica", "10.0.0.3:6379", replica("10.0.0.1"))
+	m.Set("replica", "10.0.0.3:6379", replica("10.0.0.1"))
+	if n := counterValue(m.MasterChanges.WithLabelValues("replica", "10.0.0.3:6379")); n != 0 {
+		t.Errorf("%v changes without a change of master", n)
+	}
+
+	m.Set("replica", "10.0.0.3:6379", replica("10.0.0.2"))
+	if n := counterValue(m.MasterChanges.WithLabelValues("replica", "10.0.0.3:6379")); n != 1 {
+		t.Errorf("%v changes, want 1", n)
+	}
+	if from, ok := m.MasterChangedFrom("replica", "10.0.0.3:6379"); !ok || from != "10.0.0.1:6379" {
+		t.Errorf("master changed from %q, want 10.0.0.1:6379", from)
+	}
+	if n := testSeries(m.MasterInfo); n != 1 {
+		t.Errorf("%d master info series, want only the new master's", n)
+	}
+	if !m.MasterInfo.DeleteLabelValues("replica", "10.0.0.3:6379", "10.0.0.2", "6379", "") {
+		t.Error("no master info series for the new master")
+	}
+
+	// A promoted replica has no master.
+	m.Set("replica", "10.0.0.3:6379", replica("10.0.0.2"))
+	m.Set("replica", "10.0.0.3:6379", ParseInfo("# Replication\r\nrole:master\r\n"))
+	if n := testSeries(m.MasterInfo); n != 0 {
+		t.Errorf("%d master info series for a master", n)
+	}
+	if from, ok := m.MasterChangedFrom("replica", "10.0.0.3:6379"); ok {
+		t.Errorf("master changed from %q for a master", from)
+	}
+}
+
+func counterValue(c prometheus.Counter) float64 {
+	var m dto.Metric
+	c.Write(&m)
+	return m.GetCounter().GetValue()
+}
+
+func testSeries(c prometheus.Collector) int {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		c.Collect(ch)
+		close(ch)
+	}()
+	n := 0
+	for range ch {
+		n++
+	}
+	return n
+}
@@ -174,7 +174,19 @@ func (m *RedisSentinelCollector) Unregister(registry *prometheus.Registry) bool
 	return true
 }
 
-func (m *RedisSentinelCollector) Set(nodeName, nodeAddress string, r map[string]string) error {
+func (m *RedisSentinelCollector) Delete(nodeName, nodeAddress string) {
+	deleteNodeSeries(nodeName, nodeAddress,
+		m.Up,
+		m.SentinelMasters,
+		m.SentinelTilt,
+		m.SentinelRunningScripts,
+		m.SentinelScriptsQueueLength,
+		m.SentinelSimulateFailureFlags,
+		m.Master,
+	)
+}
+
+func (m *RedisSentinelCollector) Set(nodeName, nodeAddress string, r *Info) error {
 	return nil
 }
 
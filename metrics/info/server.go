@@ -2,13 +2,44 @@ package info
 
 import (
 	"errors"
-	"strconv"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 )
 
+// Reasons reported by redis_server_up_reason. RedisServerDownReasonNone is
+// used while the server is up.
+const (
+	RedisServerDownReasonNone              = "none"
+	RedisServerDownReasonConnectionRefused = "connection_refused"
+	RedisServerDownReasonDNS               = "dns"
+	RedisServerDownReasonTimeout           = "timeout"
+	RedisServerDownReasonAuth              = "auth"
+	RedisServerDownReasonLoading           = "loading"
+	RedisServerDownReasonMasterDown        = "masterdown"
+	RedisServerDownReasonBusy              = "busy"
+	RedisServerDownReasonUnreachable       = "unreachable"
+	RedisServerDownReasonUnknown           = "unknown"
+)
+
+var RedisServerDownReasons = []string{
+	RedisServerDownReasonNone,
+	RedisServerDownReasonConnectionRefused,
+	RedisServerDownReasonDNS,
+	RedisServerDownReasonTimeout,
+	RedisServerDownReasonAuth,
+	RedisServerDownReasonLoading,
+	RedisServerDownReasonMasterDown,
+	RedisServerDownReasonBusy,
+	RedisServerDownReasonUnreachable,
+	RedisServerDownReasonUnknown,
+}
+
 type RedisServerCollector struct {
 	Up              *prometheus.GaugeVec
+	UpReason        *prometheus.GaugeVec
+	PingLatency     *prometheus.GaugeVec
+	InfoParseErrors *prometheus.GaugeVec
 	Info            *prometheus.GaugeVec
 	UptimeInSeconds *prometheus.GaugeVec
 	UptimeInDays    *prometheus.GaugeVec
@@ -30,6 +61,33 @@ func NewRedisServerCollector() *RedisServerCollector {
 		},
 			[]string{"node_name", "node_address"})
 
+		// up reason
+		redisServerUpReason = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "redis",
+			Subsystem: "server",
+			Name:      "up_reason",
+			Help:      "Value is 1 for the reason the Redis server is considered down (none while it is up), 0 otherwise.",
+		},
+			[]string{"node_name", "node_address", "reason"})
+
+		// ping latency
+		redisServerPingLatency = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "redis",
+			Subsystem: "server",
+			Name:      "ping_latency_seconds",
+			Help:      "Round-trip time of the last PING sent to the Redis server.",
+		},
+			[]string{"node_name", "node_address"})
+
+		// info parse errors
+		redisServerInfoParseErrors = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "redis",
+			Subsystem: "server",
+			Name:      "info_parse_errors",
+			Help:      "Number of INFO lines and values the last collection could not parse.",
+		},
+			[]string{"node_name", "node_address"})
+
 		// info
 		//redis_version:5.0.7
 		//redis_git_sha1:00000000
@@ -146,6 +204,9 @@ func NewRedisServerCollector() *RedisServerCollector {
 	)
 	return &RedisServerCollector{
 		redisServerUp,
+		redisServerUpReason,
+		redisServerPingLatency,
+		redisServerInfoParseErrors,
 		redisServerInfo,
 		redisServerUptimeInSeconds,
 		redisServerUptimeInDays,
@@ -157,6 +218,9 @@ func NewRedisServerCollector() *RedisServerCollector {
 
 func (m *RedisServerCollector) MustRegister(registry *prometheus.Registry) {
 	registry.MustRegister(m.Up)
+	registry.MustRegister(m.UpReason)
+	registry.MustRegister(m.PingLatency)
+	registry.MustRegister(m.InfoParseErrors)
 	registry.MustRegister(m.Info)
 	registry.MustRegister(m.UptimeInSeconds)
 	registry.MustRegister(m.UptimeInDays)
@@ -169,6 +233,15 @@ func (m *RedisServerCollector) Unregister(registry *prometheus.Registry) bool {
 	if !registry.Unregister(m.Up) {
 		return false
 	}
+	if !registry.Unregister(m.UpReason) {
+		return false
+	}
+	if !registry.Unregister(m.PingLatency) {
+		return false
+	}
+	if !registry.Unregister(m.InfoParseErrors) {
+		return false
+	}
 	if !registry.Unregister(m.Info) {
 		return false
 	}
@@ -190,80 +263,119 @@ func (m *RedisServerCollector) Unregister(registry *prometheus.Registry) bool {
 	return true
 }
 
+func (m *RedisServerCollector) Delete(nodeName, nodeAddress string) {
+	deleteNodeSeries(nodeName, nodeAddress,
+		m.Up,
+		m.UpReason,
+		m.PingLatency,
+		m.InfoParseErrors,
+		m.Info,
+		m.UptimeInSeconds,
+		m.UptimeInDays,
+		m.Hz,
+		m.ConfiguredHz,
+		m.LruClock,
+	)
+}
+
 func (m *RedisServerCollector) SetServerUp(nodeName, nodeAddress string) {
 	m.Up.WithLabelValues(nodeName, nodeAddress).Set(1)
+	m.setUpReason(nodeName, nodeAddress, RedisServerDownReasonNone)
 }
 
-func (m *RedisServerCollector) SetServerDown(nodeName, nodeAddress string) {
+func (m *RedisServerCollector) SetServerDown(nodeName, nodeAddress, reason string) {
+	if reason == "" {
+		reason = RedisServerDownReasonUnknown
+	}
 	m.Up.WithLabelValues(nodeName, nodeAddress).Set(0)
+	m.setUpReason(nodeName, nodeAddress, reason)
+	m.PingLatency.DeleteLabelValues(nodeName, nodeAddress)
+}
+
+func (m *RedisServerCollector) SetPingLatency(nodeName, nodeAddress string, latency time.Duration) {
+	m.PingLatency.WithLabelValues(nodeName, nodeAddress).Set(latency.Seconds())
+}
+
+func (m *RedisServerCollector) SetInfoParseErrors(nodeName, nodeAddress string, errors int) {
+	m.InfoParseErrors.WithLabelValues(nodeName, nodeAddress).Set(float64(errors))
+}
+
+func (m *RedisServerCollector) setUpReason(nodeName, nodeAddress, reason string) {
+	for _, r := range RedisServerDownReasons {
+		if r == reason {
+			m.UpReason.WithLabelValues(nodeName, nodeAddress, r).Set(1)
+		} else {
+			m.UpReason.WithLabelValues(nodeName, nodeAddress, r).Set(0)
+		}
+	}
 }
 
-func (m *RedisServerCollector) Set(nodeName, nodeAddress string, r map[string]string) error {
-	// redis active status
-	if _, ok := r["down"]; ok {
-		m.SetServerDown(nodeName, nodeAddress)
+func (m *RedisServerCollector) Set(nodeName, nodeAddress string, r *Info) error {
+	// redis active status, Down is the reason
+	if r.Down != "" {
+		m.SetServerDown(nodeName, nodeAddress, r.Down)
 		return errors.New("redis server down")
 	} else {
 		m.SetServerUp(nodeName, nodeAddress)
 	}
 
-	redisVersion, ok := r["redis_version"]
+	redisVersion, ok := r.String("Server", "redis_version")
 	if !ok {
 		redisVersion = "unknown"
 	}
-	redisGitSha1, ok := r["redis_git_sha1"]
+	redisGitSha1, ok := r.String("Server", "redis_git_sha1")
 	if !ok {
 		redisGitSha1 = "unknown"
 	}
-	redisGitDirty, ok := r["redis_git_dirty"]
+	redisGitDirty, ok := r.String("Server", "redis_git_dirty")
 	if !ok {
 		redisGitDirty = "unknown"
 	}
-	redisBuildId, ok := r["redis_build_id"]
+	redisBuildId, ok := r.String("Server", "redis_build_id")
 	if !ok {
 		redisBuildId = "unknown"
 	}
-	redisMode, ok := r["redis_mode"]
+	redisMode, ok := r.String("Server", "redis_mode")
 	if !ok {
 		redisMode = "standalone"
 	}
-	os, ok := r["os"]
+	os, ok := r.String("Server", "os")
 	if !ok {
 		os = "unknown"
 	}
-	archBits, ok := r["arch_bits"]
+	archBits, ok := r.String("Server", "arch_bits")
 	if !ok {
 		archBits = "unknown"
 	}
-	multiplexingAPI, ok := r["multiplexing_api"]
+	multiplexingAPI, ok := r.String("Server", "multiplexing_api")
 	if !ok {
 		multiplexingAPI = "unknown"
 	}
-	atomicvarAPI, ok := r["atomicvar_api"]
+	atomicvarAPI, ok := r.String("Server", "atomicvar_api")
 	if !ok {
 		atomicvarAPI = "unknown"
 	}
-	gccVersion, ok := r["gcc_version"]
+	gccVersion, ok := r.String("Server", "gcc_version")
 	if !ok {
 		gccVersion = "unknown"
 	}
-	//processId, ok := r["process_id"]
+	//processId, ok := r.String("Server", "process_id")
 	//if !ok {
 	//	processId = "unknown"
 	//}
-	//runId, ok := r["run_id"]
+	//runId, ok := r.String("Server", "run_id")
 	//if !ok {
 	//	runId = "unknown"
 	//}
-	tcpPort, ok := r["tcp_port"]
+	tcpPort, ok := r.String("Server", "tcp_port")
 	if !ok {
 		tcpPort = "unknown"
 	}
-	executable, ok := r["executable"]
+	executable, ok := r.String("Server", "executable")
 	if !ok {
 		executable = "unknown"
 	}
-	configFile, ok := r["config_file"]
+	configFile, ok := r.String("Server", "config_file")
 	if !ok {
 		configFile = "unknown"
 	}
@@ -273,34 +385,24 @@ func (m *RedisServerCollector) Set(nodeName, nodeAddress string, r map[string]st
 		archBits, multiplexingAPI, atomicvarAPI, gccVersion, tcpPort, executable, configFile,
 	).Set(1)
 
-	if uptimeInSecondsStr, ok := r["uptime_in_seconds"]; ok {
-		if uptimeInSeconds, err := strconv.Atoi(uptimeInSecondsStr); err == nil {
-			m.UptimeInSeconds.WithLabelValues(nodeName, nodeAddress).Set(float64(uptimeInSeconds))
-		}
+	if uptimeInSeconds, ok := r.Float("Server", "uptime_in_seconds"); ok {
+		m.UptimeInSeconds.WithLabelValues(nodeName, nodeAddress).Set(uptimeInSeconds)
 	}
 
-	if uptimeInDaysStr, ok := r["uptime_in_days"]; ok {
-		if uptimeInDays, err := strconv.Atoi(uptimeInDaysStr); err == nil {
-			m.UptimeInDays.WithLabelValues(nodeName, nodeAddress).Set(float64(uptimeInDays))
-		}
+	if uptimeInDays, ok := r.Float("Server", "uptime_in_days"); ok {
+		m.UptimeInDays.WithLabelValues(nodeName, nodeAddress).Set(uptimeInDays)
 	}
 
-	if hzStr, ok := r["hz"]; ok {
-		if hz, err := strconv.Atoi(hzStr); err == nil {
-			m.Hz.WithLabelValues(nodeName, nodeAddress).Set(float64(hz))
-		}
+	if hz, ok := r.Float("Server", "hz"); ok {
+		m.Hz.WithLabelValues(nodeName, nodeAddress).Set(hz)
 	}
 
-	if configuredHzStr, ok := r["configured_hz"]; ok {
-		if configuredHz, err := strconv.Atoi(configuredHzStr); err == nil {
-			m.ConfiguredHz.WithLabelValues(nodeName, nodeAddress).Set(float64(configuredHz))
-		}
+	if configuredHz, ok := r.Float("Server", "configured_hz"); ok {
+		m.ConfiguredHz.WithLabelValues(nodeName, nodeAddress).Set(configuredHz)
 	}
 
-	if lruClockStr, ok := r["lru_clock"]; ok {
-		if lruClock, err := strconv.Atoi(lruClockStr); err == nil {
-			m.LruClock.WithLabelValues(nodeName, nodeAddress).Set(float64(lruClock))
-		}
+	if lruClock, ok := r.Float("Server", "lru_clock"); ok {
+		m.LruClock.WithLabelValues(nodeName, nodeAddress).Set(lruClock)
 	}
 	return nil
 }
@@ -1,8 +1,6 @@
 package info
 
 import (
-	"strconv"
-
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -388,165 +386,144 @@ func (m *RedisStatsCollector) Unregister(registry *prometheus.Registry) bool {
 	return true
 }
 
-func (m *RedisStatsCollector) Set(nodeName, nodeAddress string, r map[string]string) error {
+func (m *RedisStatsCollector) Delete(nodeName, nodeAddress string) {
+	deleteNodeSeries(nodeName, nodeAddress,
+		m.TotalConnectionsReceived,
+		m.TotalCommandsProcessed,
+		m.InstantaneousOpsPerSec,
+		m.TotalNetInputBytes,
+		m.TotalNetOutputBytes,
+		m.InstantaneousInputKbps,
+		m.InstantaneousOutputKbps,
+		m.RejectedConnections,
+		m.SyncFull,
+		m.SyncPartialOK,
+		m.SyncPartialErr,
+		m.ExpiredKeys,
+		m.ExpiredStalePerc,
+		m.ExpiredTimeCapReachedCount,
+		m.EvictedKeys,
+		m.KeyspaceHits,
+		m.KeyspaceMisses,
+		m.PubsubChannels,
+		m.PubsubPatterns,
+		m.LatestForkUsec,
+		m.MigrateCachedSockets,
+		m.SlaveExpiresTrackedKeys,
+		m.ActiveDefragHits,
+		m.ActiveDefragMisses,
+		m.ActiveDefragKeyHits,
+		m.ActiveDefragKeyMisses,
+	)
+}
+
+func (m *RedisStatsCollector) Set(nodeName, nodeAddress string, r *Info) error {
 	// total_connections_received:33
-	if totalConnectionsReceivedStr, ok := r["total_connections_received"]; ok {
-		if totalConnectionsReceived, err := strconv.Atoi(totalConnectionsReceivedStr); err == nil {
-			m.TotalConnectionsReceived.WithLabelValues(nodeName, nodeAddress).Set(
-				float64(totalConnectionsReceived))
-		}
+	if totalConnectionsReceived, ok := r.Float("Stats", "total_connections_received"); ok {
+		m.TotalConnectionsReceived.WithLabelValues(nodeName, nodeAddress).Set(
+			totalConnectionsReceived)
 	}
 	//total_commands_processed:96955
-	if totalCommandsProcessedStr, ok := r["total_commands_processed"]; ok {
-		if totalCommandsProcessed, err := strconv.Atoi(totalCommandsProcessedStr); err == nil {
-			m.TotalCommandsProcessed.WithLabelValues(nodeName, nodeAddress).Set(float64(totalCommandsProcessed))
-		}
+	if totalCommandsProcessed, ok := r.Float("Stats", "total_commands_processed"); ok {
+		m.TotalCommandsProcessed.WithLabelValues(nodeName, nodeAddress).Set(totalCommandsProcessed)
 	}
 	//instantaneous_ops_per_sec:7
-	if instantaneousOpsPerSecStr, ok := r["instantaneous_ops_per_sec"]; ok {
-		if instantaneousOpsPerSec, err := strconv.Atoi(instantaneousOpsPerSecStr); err == nil {
-			m.InstantaneousOpsPerSec.WithLabelValues(nodeName, nodeAddress).Set(float64(instantaneousOpsPerSec))
-		}
+	if instantaneousOpsPerSec, ok := r.Float("Stats", "instantaneous_ops_per_sec"); ok {
+		m.InstantaneousOpsPerSec.WithLabelValues(nodeName, nodeAddress).Set(instantaneousOpsPerSec)
 	}
 	//total_net_input_bytes:4695881
-	if totalNetInputBytesStr, ok := r["total_net_input_bytes"]; ok {
-		if totalNetInputBytes, err := strconv.Atoi(totalNetInputBytesStr); err == nil {
-			m.TotalNetInputBytes.WithLabelValues(nodeName, nodeAddress).Set(float64(totalNetInputBytes))
-		}
+	if totalNetInputBytes, ok := r.Float("Stats", "total_net_input_bytes"); ok {
+		m.TotalNetInputBytes.WithLabelValues(nodeName, nodeAddress).Set(totalNetInputBytes)
 	}
 	//total_net_output_bytes:30079574
-	if totalNetOutputBytesStr, ok := r["total_net_output_bytes"]; ok {
-		if totalNetOutputBytes, err := strconv.Atoi(totalNetOutputBytesStr); err == nil {
-			m.TotalNetOutputBytes.WithLabelValues(nodeName, nodeAddress).Set(float64(totalNetOutputBytes))
-		}
+	if totalNetOutputBytes, ok := r.Float("Stats", "total_net_output_bytes"); ok {
+		m.TotalNetOutputBytes.WithLabelValues(nodeName, nodeAddress).Set(totalNetOutputBytes)
 	}
 	//instantaneous_input_kbps:0.31
-	if instantaneousInputKbpsStr, ok := r["instantaneous_input_kbps"]; ok {
-		if instantaneousInputKbps, err := strconv.ParseFloat(instantaneousInputKbpsStr, 64); err == nil {
-			m.InstantaneousInputKbps.WithLabelValues(nodeName, nodeAddress).Set(instantaneousInputKbps)
-		}
+	if instantaneousInputKbps, ok := r.Float("Stats", "instantaneous_input_kbps"); ok {
+		m.InstantaneousInputKbps.WithLabelValues(nodeName, nodeAddress).Set(instantaneousInputKbps)
 	}
 	//instantaneous_output_kbps:7.70
-	if instantaneusOutputKbpsStr, ok := r["instantaneous_output_kbps"]; ok {
-		if instantaneusOutputKbps, err := strconv.ParseFloat(instantaneusOutputKbpsStr, 64); err == nil {
-			m.InstantaneousOutputKbps.WithLabelValues(nodeName, nodeAddress).Set(instantaneusOutputKbps)
-		}
+	if instantaneusOutputKbps, ok := r.Float("Stats", "instantaneous_output_kbps"); ok {
+		m.InstantaneousOutputKbps.WithLabelValues(nodeName, nodeAddress).Set(instantaneusOutputKbps)
 	}
 	//rejected_connections:0
-	if rejectedConnectionsStr, ok := r["rejected_connections"]; ok {
-		if rejectedConnections, err := strconv.Atoi(rejectedConnectionsStr); err == nil {
-			m.RejectedConnections.WithLabelValues(nodeName, nodeAddress).Set(float64(rejectedConnections))
-		}
+	if rejectedConnections, ok := r.Float("Stats", "rejected_connections"); ok {
+		m.RejectedConnections.WithLabelValues(nodeName, nodeAddress).Set(rejectedConnections)
 	}
 	//sync_full:2
-	if syncFullStr, ok := r["sync_full"]; ok {
-		if syncFull, err := strconv.Atoi(syncFullStr); err == nil {
-			m.SyncFull.WithLabelValues(nodeName, nodeAddress).Set(float64(syncFull))
-		}
+	if syncFull, ok := r.Float("Stats", "sync_full"); ok {
+		m.SyncFull.WithLabelValues(nodeName, nodeAddress).Set(syncFull)
 	}
 	//sync_partial_ok:2
-	if syncPartialOKStr, ok := r["sync_partial_ok"]; ok {
-		if syncPartialOK, err := strconv.Atoi(syncPartialOKStr); err == nil {
-			m.SyncPartialOK.WithLabelValues(nodeName, nodeAddress).Set(float64(syncPartialOK))
-		}
+	if syncPartialOK, ok := r.Float("Stats", "sync_partial_ok"); ok {
+		m.SyncPartialOK.WithLabelValues(nodeName, nodeAddress).Set(syncPartialOK)
 	}
 	//sync_partial_err:2
-	if syncPartialERRStr, ok := r["sync_partial_err"]; ok {
-		if syncPartialERR, err := strconv.Atoi(syncPartialERRStr); err == nil {
-			m.SyncPartialErr.WithLabelValues(nodeName, nodeAddress).Set(float64(syncPartialERR))
-		}
+	if syncPartialERR, ok := r.Float("Stats", "sync_partial_err"); ok {
+		m.SyncPartialErr.WithLabelValues(nodeName, nodeAddress).Set(syncPartialERR)
 	}
 	//expired_keys:0
-	if expiredKeysStr, ok := r["expired_keys"]; ok {
-		if expiredKeys, err := strconv.Atoi(expiredKeysStr); err == nil {
-			m.ExpiredKeys.WithLabelValues(nodeName, nodeAddress).Set(float64(expiredKeys))
-		}
+	if expiredKeys, ok := r.Float("Stats", "expired_keys"); ok {
+		m.ExpiredKeys.WithLabelValues(nodeName, nodeAddress).Set(expiredKeys)
 	}
 	//expired_stale_perc:0.00
-	if expiredStalePercStr, ok := r["expired_stale_perc"]; ok {
-		if expiredStalePerc, err := strconv.ParseFloat(expiredStalePercStr, 64); err == nil {
-			m.ExpiredStalePerc.WithLabelValues(nodeName, nodeAddress).Set(expiredStalePerc)
-		}
+	if expiredStalePerc, ok := r.Float("Stats", "expired_stale_perc"); ok {
+		m.ExpiredStalePerc.WithLabelValues(nodeName, nodeAddress).Set(expiredStalePerc)
 	}
 	//expired_time_cap_reached_count:0
-	if expiredTimeCapReadchedCountStr, ok := r["expired_time_cap_reached_count"]; ok {
-		if expiredTimeCapReadchedCount, err := strconv.Atoi(expiredTimeCapReadchedCountStr); err == nil {
-			m.ExpiredTimeCapReachedCount.WithLabelValues(nodeName, nodeAddress).Set(
-				float64(expiredTimeCapReadchedCount))
-		}
+	if expiredTimeCapReadchedCount, ok := r.Float("Stats", "expired_time_cap_reached_count"); ok {
+		m.ExpiredTimeCapReachedCount.WithLabelValues(nodeName, nodeAddress).Set(
+			expiredTimeCapReadchedCount)
 	}
 	//evicted_keys:0
-	if evictedKeysStr, ok := r["evicted_keys"]; ok {
-		if evictedKeys, err := strconv.Atoi(evictedKeysStr); err == nil {
-			m.EvictedKeys.WithLabelValues(nodeName, nodeAddress).Set(float64(evictedKeys))
-		}
+	if evictedKeys, ok := r.Float("Stats", "evicted_keys"); ok {
+		m.EvictedKeys.WithLabelValues(nodeName, nodeAddress).Set(evictedKeys)
 	}
 	//keyspace_hits:0
-	if keyspaceHitsStr, ok := r["keyspace_hits"]; ok {
-		if keyspaceHits, err := strconv.Atoi(keyspaceHitsStr); err == nil {
-			m.KeyspaceHits.WithLabelValues(nodeName, nodeAddress).Set(float64(keyspaceHits))
-		}
+	if keyspaceHits, ok := r.Float("Stats", "keyspace_hits"); ok {
+		m.KeyspaceHits.WithLabelValues(nodeName, nodeAddress).Set(keyspaceHits)
 	}
 	//keyspace_misses:0
-	if keyspaceMissesStr, ok := r["keyspace_misses"]; ok {
-		if keyspaceMisses, err := strconv.Atoi(keyspaceMissesStr); err == nil {
-			m.KeyspaceMisses.WithLabelValues(nodeName, nodeAddress).Set(float64(keyspaceMisses))
-		}
+	if keyspaceMisses, ok := r.Float("Stats", "keyspace_misses"); ok {
+		m.KeyspaceMisses.WithLabelValues(nodeName, nodeAddress).Set(keyspaceMisses)
 	}
 	//pubsub_channels:1
-	if pubsubChannelsStr, ok := r["pubsub_channels"]; ok {
-		if pubsubChannels, err := strconv.Atoi(pubsubChannelsStr); err == nil {
-			m.PubsubChannels.WithLabelValues(nodeName, nodeAddress).Set(float64(pubsubChannels))
-		}
+	if pubsubChannels, ok := r.Float("Stats", "pubsub_channels"); ok {
+		m.PubsubChannels.WithLabelValues(nodeName, nodeAddress).Set(pubsubChannels)
 	}
 	//pubsub_patterns:0
-	if pubsubPatternsStr, ok := r["pubsub_patterns"]; ok {
-		if pubsubPatterns, err := strconv.Atoi(pubsubPatternsStr); err == nil {
-			m.PubsubChannels.WithLabelValues(nodeName, nodeAddress).Set(float64(pubsubPatterns))
-		}
+	if pubsubPatterns, ok := r.Float("Stats", "pubsub_patterns"); ok {
+		m.PubsubChannels.WithLabelValues(nodeName, nodeAddress).Set(pubsubPatterns)
 	}
 	//latest_fork_usec:366
-	if latestForkUsecStr, ok := r["latest_fork_usec"]; ok {
-		if latestForkUsec, err := strconv.Atoi(latestForkUsecStr); err == nil {
-			m.LatestForkUsec.WithLabelValues(nodeName, nodeAddress).Set(float64(latestForkUsec))
-		}
+	if latestForkUsec, ok := r.Float("Stats", "latest_fork_usec"); ok {
+		m.LatestForkUsec.WithLabelValues(nodeName, nodeAddress).Set(latestForkUsec)
 	}
 	//migrate_cached_sockets:0
-	if migrateCachedSocketsStr, ok := r["migrate_cached_sockets"]; ok {
-		if migrateCachedSockets, err := strconv.Atoi(migrateCachedSocketsStr); err == nil {
-			m.MigrateCachedSockets.WithLabelValues(nodeName, nodeAddress).Set(float64(migrateCachedSockets))
-		}
+	if migrateCachedSockets, ok := r.Float("Stats", "migrate_cached_sockets"); ok {
+		m.MigrateCachedSockets.WithLabelValues(nodeName, nodeAddress).Set(migrateCachedSockets)
 	}
 	//slave_expires_tracked_keys:0
-	if slaveExpiresTrackedKeysStr, ok := r["slave_expires_tracked_keys"]; ok {
-		if slaveExpiresTrackedKeys, err := strconv.Atoi(slaveExpiresTrackedKeysStr); err == nil {
-			m.SlaveExpiresTrackedKeys.WithLabelValues(nodeName, nodeAddress).Set(
-				float64(slaveExpiresTrackedKeys))
-		}
+	if slaveExpiresTrackedKeys, ok := r.Float("Stats", "slave_expires_tracked_keys"); ok {
+		m.SlaveExpiresTrackedKeys.WithLabelValues(nodeName, nodeAddress).Set(
+			slaveExpiresTrackedKeys)
 	}
 	//active_defrag_hits:0
-	if activeDefragHitsStr, ok := r["active_defrag_hits"]; ok {
-		if activeDefragHits, err := strconv.Atoi(activeDefragHitsStr); err == nil {
-			m.ActiveDefragHits.WithLabelValues(nodeName, nodeAddress).Set(float64(activeDefragHits))
-		}
+	if activeDefragHits, ok := r.Float("Stats", "active_defrag_hits"); ok {
+		m.ActiveDefragHits.WithLabelValues(nodeName, nodeAddress).Set(activeDefragHits)
 	}
 	//active_defrag_misses:0
-	if activeDefragMissesStr, ok := r["active_defrag_misses"]; ok {
-		if activeDefragMisses, err := strconv.Atoi(activeDefragMissesStr); err == nil {
-			m.ActiveDefragMisses.WithLabelValues(nodeName, nodeAddress).Set(float64(activeDefragMisses))
-		}
+	if activeDefragMisses, ok := r.Float("Stats", "active_defrag_misses"); ok {
+		m.ActiveDefragMisses.WithLabelValues(nodeName, nodeAddress).Set(activeDefragMisses)
 	}
 	//active_defrag_key_hits:0
-	if activeDefragKeyHitsStr, ok := r["active_defrag_key_hits"]; ok {
-		if activeDefragKeyHits, err := strconv.Atoi(activeDefragKeyHitsStr); err == nil {
-			m.ActiveDefragKeyHits.WithLabelValues(nodeName, nodeAddress).Set(float64(activeDefragKeyHits))
-		}
+	if activeDefragKeyHits, ok := r.Float("Stats", "active_defrag_key_hits"); ok {
+		m.ActiveDefragKeyHits.WithLabelValues(nodeName, nodeAddress).Set(activeDefragKeyHits)
 	}
 	//active_defrag_key_misses:0
-	if activeDefragKeyMissesStr, ok := r["active_defrag_key_misses"]; ok {
-		if activeDefragKeyMisses, err := strconv.Atoi(activeDefragKeyMissesStr); err == nil {
-			m.ActiveDefragKeyMisses.WithLabelValues(nodeName, nodeAddress).Set(float64(activeDefragKeyMisses))
-		}
+	if activeDefragKeyMisses, ok := r.Float("Stats", "active_defrag_key_misses"); ok {
+		m.ActiveDefragKeyMisses.WithLabelValues(nodeName, nodeAddress).Set(activeDefragKeyMisses)
 	}
 	return nil
 }
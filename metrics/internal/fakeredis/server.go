@@ -0,0 +1,457 @@
+// Package fakeredis is an in-process Redis server for tests. It speaks
+// enough RESP to answer the commands redis-metrics sends with canned replies
+// recorded from real Redis versions, and can simulate the failures a
+// collector has to cope with.
+package fakeredis
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Failure is the way the server misbehaves.
+type Failure int
+
+const (
+	// None answers every command.
+	None Failure = iota
+	// Timeout reads commands but never replies.
+	Timeout
+	// AuthFailure rejects every command with NOAUTH.
+	AuthFailure
+	// Loading rejects every command with LOADING, as while an RDB file loads.
+	Loading
+	// Reset closes the connection when a command arrives.
+	Reset
+)
+
+// Server is a fake Redis server listening on a random local port. The
+// replies for a version are read from testdata/<version>/:
+//
+//	info.txt                 INFO
+//	config.txt               CONFIG GET, one "name value" pair per line
+//	cluster_info.txt         CLUSTER INFO, cluster support disabled without it
+//	cluster_nodes.txt        CLUSTER NODES
+//	sentinel_masters.txt     SENTINEL MASTERS, masters separated by empty lines
+//	                         of "name value" pairs, unknown command without it
+type Server struct {
+	Version string
+
+	listener net.Listener
+	files    map[string]string
+
+	mu       sync.Mutex
+	password string
+	failure  Failure
+	conns    map[net.Conn]struct{}
+	wg       sync.WaitGroup
+}
+
+// Versions returns the Redis versions there are canned replies for.
+func Versions() ([]string, error) {
+	dirs, err := ioutil.ReadDir(testdata())
+	if err != nil {
+		return nil, err
+	}
+	var versions []string
+	for _, dir := range dirs {
+		if dir.IsDir() {
+			versions = append(versions, dir.Name())
+		}
+	}
+	sort.Strings(versions)
+	return versions, nil
+}
+
+// Start starts a server answering like Redis version.
+func Start(version string) (*Server, error) {
+	files, err := readFiles(filepath.Join(testdata(), version))
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := files["info.txt"]; !ok {
+		return nil, fmt.Errorf("no INFO reply for Redis %s", version)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		Version:  version,
+		listener: listener,
+		files:    files,
+		conns:    make(map[net.Conn]struct{}),
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+func testdata() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "testdata")
+}
+
+func readFiles(dir string) (map[string]string, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]string)
+	for _, entry := range entries {
+		content, err := ioutil.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		files[entry.Name()] = string(content)
+	}
+	return files, nil
+}
+
+// Addr returns the host:port the server listens on.
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Host and Port split Addr.
+func (s *Server) Host() string {
+	host, _, _ := net.SplitHostPort(s.Addr())
+	return host
+}
+
+func (s *Server) Port() int {
+	_, port, _ := net.SplitHostPort(s.Addr())
+	p, _ := strconv.Atoi(port)
+	return p
+}
+
+// SetPassword makes the server require AUTH password.
+func (s *Server) SetPassword(password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.password = password
+}
+
+// SetFailure changes how the server misbehaves from the next command on.
+func (s *Server) SetFailure(f Failure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failure = f
+}
+
+// SetInfo replaces the canned INFO reply.
+func (s *Server) SetInfo(info string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files["info.txt"] = info
+}
+
+// Close stops the server and closes every connection.
+func (s *Server) Close() error {
+	err := s.listener.Close()
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	authenticated := false
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		failure, password := s.failure, s.password
+		s.mu.Unlock()
+
+		switch failure {
+		case Timeout:
+			continue
+		case Reset:
+			if tcp, ok := conn.(*net.TCPConn); ok {
+				tcp.SetLinger(0)
+			}
+			return
+		case AuthFailure:
+			writeError(w, "NOAUTH Authentication required.")
+		case Loading:
+			writeError(w, "LOADING Redis is loading the dataset in memory")
+		default:
+			cmd := strings.ToUpper(args[0])
+			switch {
+			case cmd == "AUTH":
+				if password == "" {
+					writeError(w, "ERR Client sent AUTH, but no password is set")
+				} else if len(args) != 2 || args[1] != password {
+					writeError(w, s.wrongPassword())
+				} else {
+					authenticated = true
+					writeSimple(w, "OK")
+				}
+			case password != "" && !authenticated:
+				writeError(w, "NOAUTH Authentication required.")
+			default:
+				s.reply(w, cmd, args[1:])
+			}
+		}
+		if err := w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// wrongPassword is the error of a failed AUTH, which changed in Redis 6.
+func (s *Server) wrongPassword() string {
+	if s.Version >= "6" {
+		return "WRONGPASS invalid username-password pair"
+	}
+	return "ERR invalid password"
+}
+
+func (s *Server) file(name string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	content, ok := s.files[name]
+	return content, ok
+}
+
+func (s *Server) reply(w *bufio.Writer, cmd string, args []string) {
+	switch cmd {
+	case "PING":
+		writeSimple(w, "PONG")
+	case "SELECT", "CLIENT", "READONLY", "QUIT":
+		writeSimple(w, "OK")
+	case "INFO":
+		info, _ := s.file("info.txt")
+		section := "all"
+		if len(args) > 0 {
+			section = strings.ToLower(args[0])
+		}
+		writeBulk(w, crlf(infoSection(info, section)))
+	case "CONFIG":
+		if len(args) != 2 || strings.ToUpper(args[0]) != "GET" {
+			writeError(w, "ERR unsupported CONFIG subcommand")
+			return
+		}
+		config, _ := s.file("config.txt")
+		var reply []interface{}
+		for _, line := range strings.Split(config, "\n") {
+			kv := strings.SplitN(strings.TrimSpace(line), " ", 2)
+			if kv[0] == "" {
+				continue
+			}
+			if ok, _ := path.Match(args[1], kv[0]); ok {
+				value := ""
+				if len(kv) == 2 {
+					value = kv[1]
+				}
+				reply = append(reply, kv[0], value)
+			}
+		}
+		writeArray(w, reply)
+	case "CLUSTER":
+		if len(args) == 0 {
+			writeError(w, "ERR wrong number of arguments for 'cluster' command")
+			return
+		}
+		name := "cluster_" + strings.ToLower(args[0]) + ".txt"
+		content, ok := s.file(name)
+		if !ok {
+			writeError(w, "ERR This instance has cluster support disabled")
+			return
+		}
+		writeBulk(w, crlf(content))
+	case "SENTINEL":
+		content, ok := s.file("sentinel_masters.txt")
+		if !ok || len(args) == 0 || strings.ToUpper(args[0]) != "MASTERS" {
+			writeError(w, "ERR unknown command `SENTINEL`")
+			return
+		}
+		var masters []interface{}
+		for _, block := range strings.Split(strings.TrimSpace(content), "\n\n") {
+			var fields []interface{}
+			for _, line := range strings.Split(block, "\n") {
+				kv := strings.SplitN(strings.TrimSpace(line), " ", 2)
+				if len(kv) == 2 {
+					fields = append(fields, kv[0], kv[1])
+				}
+			}
+			masters = append(masters, fields)
+		}
+		writeArray(w, masters)
+	case "SLOWLOG":
+		if len(args) == 0 {
+			writeError(w, "ERR wrong number of arguments for 'slowlog' command")
+			return
+		}
+		switch strings.ToUpper(args[0]) {
+		case "LEN":
+			writeInt(w, 2)
+		case "RESET":
+			writeSimple(w, "OK")
+		default:
+			writeArray(w, s.slowlog())
+		}
+	default:
+		writeError(w, fmt.Sprintf("ERR unknown command `%s`", strings.ToLower(cmd)))
+	}
+}
+
+// slowlog returns two canned entries. Since Redis 4.0 entries also carry
+// the client address and name.
+func (s *Server) slowlog() []interface{} {
+	entries := []interface{}{
+		[]interface{}{int64(1), int64(1577836800), int64(12000), []interface{}{"KEYS", "*"}},
+		[]interface{}{int64(0), int64(1577836700), int64(10500), []interface{}{"HGETALL", "big"}},
+	}
+	if s.Version >= "4" {
+		for i, entry := range entries {
+			entries[i] = append(entry.([]interface{}), "127.0.0.1:50000", "")
+		}
+	}
+	return entries
+}
+
+// infoSection returns the sections of info selected by section, the way
+// INFO <section> does.
+func infoSection(info, section string) string {
+	switch section {
+	case "all", "everything", "default":
+		return info
+	}
+	var b strings.Builder
+	include := false
+	for _, line := range strings.Split(info, "\n") {
+		if strings.HasPrefix(line, "# ") {
+			include = strings.EqualFold(strings.TrimSpace(line[2:]), section)
+		}
+		if include {
+			b.WriteString(line)
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
+
+func crlf(s string) string {
+	return strings.Replace(strings.Replace(s, "\r\n", "\n", -1), "\n", "\r\n", -1)
+}
+
+var errProtocol = errors.New("protocol error")
+
+// readCommand reads a RESP array of bulk strings or an inline command.
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		args := strings.Fields(line)
+		if len(args) == 0 {
+			return readCommand(r)
+		}
+		return args, nil
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n <= 0 {
+		return nil, errProtocol
+	}
+	args := make([]string, n)
+	for i := range args {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(line, "$") {
+			return nil, errProtocol
+		}
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 {
+			return nil, errProtocol
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func writeSimple(w io.Writer, s string) {
+	fmt.Fprintf(w, "+%s\r\n", s)
+}
+
+func writeError(w io.Writer, s string) {
+	fmt.Fprintf(w, "-%s\r\n", s)
+}
+
+func writeInt(w io.Writer, i int64) {
+	fmt.Fprintf(w, ":%d\r\n", i)
+}
+
+func writeBulk(w io.Writer, s string) {
+	fmt.Fprintf(w, "$%d\r\n%s\r\n", len(s), s)
+}
+
+func writeArray(w io.Writer, values []interface{}) {
+	fmt.Fprintf(w, "*%d\r\n", len(values))
+	for _, v := range values {
+		switch v := v.(type) {
+		case string:
+			writeBulk(w, v)
+		case int64:
+			writeInt(w, v)
+		case []interface{}:
+			writeArray(w, v)
+		}
+	}
+}
@@ -0,0 +1,397 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/cwr0401/redis_metrics/version"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	log "github.com/sirupsen/logrus"
+)
+
+const OutputOTLP = "otlp"
+
+// otlpCumulative lists the Redis gauges that are really running totals and
+// are therefore exported as monotonic sums.
+var otlpCumulative = map[string]bool{
+	"redis_stats_total_connections_received":     true,
+	"redis_stats_total_commands_processed":       true,
+	"redis_stats_total_net_input_bytes":          true,
+	"redis_stats_total_net_output_bytes":         true,
+	"redis_stats_rejected_connections":           true,
+	"redis_stats_sync_full":                      true,
+	"redis_stats_sync_partial_ok":                true,
+	"redis_stats_sync_partial_err":               true,
+	"redis_stats_expired_keys":                   true,
+	"redis_stats_expired_time_cap_reached_count": true,
+	"redis_stats_evicted_keys":                   true,
+	"redis_stats_keyspace_hits":                  true,
+	"redis_stats_keyspace_misses":                true,
+	"redis_stats_active_defrag_hits":             true,
+	"redis_stats_active_defrag_misses":           true,
+	"redis_stats_active_defrag_key_hits":         true,
+	"redis_stats_active_defrag_key_misses":       true,
+	"redis_cmdstat_calls":                        true,
+	"redis_cmdstat_usec":                         true,
+	"redis_cpu_used_cpu_sys":                     true,
+	"redis_cpu_used_cpu_user":                    true,
+	"redis_cpu_used_cpu_sys_children":            true,
+	"redis_cpu_used_cpu_user_children":           true,
+}
+
+// otlpSemconvName is the OpenTelemetry name, unit and extra attributes of a
+// Redis metric, following the names of the OpenTelemetry Collector's Redis
+// receiver.
+type otlpSemconvName struct {
+	name       string
+	unit       string
+	attributes map[string]string
+}
+
+var otlpSemconvNames = map[string]otlpSemconvName{
+	"redis_server_uptime_in_seconds":                   {"redis.uptime", "s", nil},
+	"redis_clients_connected_clients":                  {"redis.clients.connected", "{client}", nil},
+	"redis_clients_blocked_clients":                    {"redis.clients.blocked", "{client}", nil},
+	"redis_clients_client_recent_max_input_buffer":     {"redis.clients.max_input_buffer", "By", nil},
+	"redis_clients_client_recent_max_output_buffer":    {"redis.clients.max_output_buffer", "By", nil},
+	"redis_memory_used_memory":                         {"redis.memory.used", "By", nil},
+	"redis_memory_used_memory_rss":                     {"redis.memory.rss", "By", nil},
+	"redis_memory_used_memory_peak":                    {"redis.memory.peak", "By", nil},
+	"redis_memory_used_memory_lua":                     {"redis.memory.lua", "By", nil},
+	"redis_memory_maxmemory":                           {"redis.maxmemory", "By", nil},
+	"redis_memory_mem_fragmentation_ratio":             {"redis.memory.fragmentation_ratio", "1", nil},
+	"redis_persistence_rdb_changes_since_last_save":    {"redis.rdb.changes_since_last_save", "{change}", nil},
+	"redis_stats_total_connections_received":           {"redis.connections.received", "{connection}", nil},
+	"redis_stats_rejected_connections":                 {"redis.connections.rejected", "{connection}", nil},
+	"redis_stats_total_commands_processed":             {"redis.commands.processed", "{command}", nil},
+	"redis_stats_instantaneous_ops_per_sec":            {"redis.commands", "{ops}/s", nil},
+	"redis_stats_total_net_input_bytes":                {"redis.net.input", "By", nil},
+	"redis_stats_total_net_output_bytes":               {"redis.net.output", "By", nil},
+	"redis_stats_expired_keys":                         {"redis.keys.expired", "{event}", nil},
+	"redis_stats_evicted_keys":                         {"redis.keys.evicted", "{event}", nil},
+	"redis_stats_keyspace_hits":                        {"redis.keyspace.hits", "{hit}", nil},
+	"redis_stats_keyspace_misses":                      {"redis.keyspace.misses", "{miss}", nil},
+	"redis_stats_latest_fork_usec":                     {"redis.latest_fork", "us", nil},
+	"redis_replication_connected_slaves":               {"redis.slaves.connected", "{replica}", nil},
+	"redis_replication_master_repl_offset":             {"redis.replication.offset", "By", nil},
+	"redis_replication_repl_backlog_first_byte_offset": {"redis.replication.backlog_first_byte_offset", "By", nil},
+	"redis_cpu_used_cpu_sys":                           {"redis.cpu.time", "s", map[string]string{"state": "sys"}},
+	"redis_cpu_used_cpu_user":                          {"redis.cpu.time", "s", map[string]string{"state": "user"}},
+	"redis_cpu_used_cpu_sys_children":                  {"redis.cpu.time", "s", map[string]string{"state": "sys_children"}},
+	"redis_cpu_used_cpu_user_children":                 {"redis.cpu.time", "s", map[string]string{"state": "user_children"}},
+	"redis_keyspace_db_keys":                           {"redis.db.keys", "{key}", nil},
+	"redis_keyspace_db_expires":                        {"redis.db.expires", "{key}", nil},
+	"redis_keyspace_db_avg_ttl":                        {"redis.db.avg_ttl", "ms", nil},
+}
+
+// OTLPOutput exports the series of a target to an OpenTelemetry collector
+// over OTLP/HTTP with the JSON encoding after each of its collections. Every
+// target is a resource of its own. With Semconv set, the metrics listed in
+// otlpSemconvNames are renamed to their OpenTelemetry names.
+type OTLPOutput struct {
+	URL         string
+	Headers     map[string]string
+	ServiceName string
+	Semconv     bool
+	Gatherer    prometheus.Gatherer
+	Exporter    *ExporterCollector
+	Retries     int
+	Backoff     time.Duration
+
+	client *http.Client
+	queue  chan *CollectionResult
+}
+
+func NewOTLPOutput(url string, headers map[string]string, serviceName string, semconv bool,
+	gatherer prometheus.Gatherer, exporter *ExporterCollector, retries int, backoff, timeout time.Duration) *OTLPOutput {
+	return &OTLPOutput{
+		URL:         url,
+		Headers:     headers,
+		ServiceName: serviceName,
+		Semconv:     semconv,
+		Gatherer:    gatherer,
+		Exporter:    exporter,
+		Retries:     retries,
+		Backoff:     backoff,
+		client:      &http.Client{Timeout: timeout},
+		queue:       make(chan *CollectionResult, 1024),
+	}
+}
+
+func (o *OTLPOutput) Collected(result *CollectionResult) {
+	select {
+	case o.queue <- result:
+	default:
+		o.Exporter.OutputDropped.WithLabelValues(OutputOTLP).Inc()
+		log.WithFields(log.Fields{
+			"node": result.Name,
+			"addr": result.Addr,
+		}).Warn("OTLP queue is full, dropping export")
+	}
+}
+
+// Removed does nothing, OTLP receivers expire series on their own.
+func (o *OTLPOutput) Removed(nodeName, nodeAddress string) {}
+
+// Run exports the queued collections until stop is closed, then the
+// collections still queued. Once ctx is done the rest are dropped.
+func (o *OTLPOutput) Run(ctx context.Context, stop <-chan struct{}) {
+	log.Infof("Exporting metrics over OTLP to %s", o.URL)
+	defer func() { dropQueued(o.Exporter, OutputOTLP, len(o.queue)) }()
+	for ctx.Err() == nil {
+		var result *CollectionResult
+		select {
+		case <-ctx.Done():
+			return
+		case result = <-o.queue:
+		case <-stop:
+			select {
+			case result = <-o.queue:
+			default:
+				return
+			}
+		}
+		o.export(ctx, result)
+	}
+}
+
+func (o *OTLPOutput) export(ctx context.Context, result *CollectionResult) {
+	mfs, err := nodeGatherer{o.Gatherer, result.Name, result.Addr}.Gather()
+	if err == nil {
+		var body []byte
+		body, err = json.Marshal(o.request(result, mfs))
+		if err == nil {
+			err = retryWithBackoff(ctx, o.Retries, o.Backoff, func() error {
+				err := o.post(ctx, body)
+				o.Exporter.ObserveOutput(OutputOTLP, err)
+				return err
+			})
+		}
+	}
+	if err != nil {
+		o.Exporter.OutputDropped.WithLabelValues(OutputOTLP).Inc()
+		log.WithFields(log.Fields{
+			"node": result.Name,
+			"addr": result.Addr,
+		}).Errorf("OTLP export failed: %s", err)
+	}
+}
+
+func (o *OTLPOutput) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, o.URL, bytes.NewReader(body))
+	if err != nil {
+		return permanentError{err}
+	}
+	req = req.WithContext(ctx)
+	for name, value := range o.Headers {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		io.Copy(ioutil.Discard, resp.Body)
+		return nil
+	}
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 256))
+	err = fmt.Errorf("server returned HTTP status %s: %s", resp.Status, bytes.TrimSpace(msg))
+	// The OTLP specification only allows retrying these.
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return err
+	}
+	return permanentError{err}
+}
+
+// The otlp types below are the JSON mapping of the OTLP metrics protobuf
+// messages. 64 bit integers are encoded as strings as the mapping requires.
+
+type otlpExportRequest struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope    `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type otlpMetric struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description,omitempty"`
+	Unit        string     `json:"unit,omitempty"`
+	Gauge       *otlpGauge `json:"gauge,omitempty"`
+	Sum         *otlpSum   `json:"sum,omitempty"`
+}
+
+type otlpGauge struct {
+	DataPoints []otlpDataPoint `json:"dataPoints"`
+}
+
+// otlpAggregationTemporalityCumulative is AGGREGATION_TEMPORALITY_CUMULATIVE.
+const otlpAggregationTemporalityCumulative = 2
+
+type otlpSum struct {
+	DataPoints             []otlpDataPoint `json:"dataPoints"`
+	AggregationTemporality int             `json:"aggregationTemporality"`
+	IsMonotonic            bool            `json:"isMonotonic"`
+}
+
+type otlpDataPoint struct {
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      string         `json:"timeUnixNano"`
+	AsDouble          float64        `json:"asDouble"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+}
+
+func otlpString(key, value string) otlpKeyValue {
+	return otlpKeyValue{Key: key, Value: otlpAnyValue{StringValue: &value}}
+}
+
+func otlpInt(key string, value int64) otlpKeyValue {
+	s := strconv.FormatInt(value, 10)
+	return otlpKeyValue{Key: key, Value: otlpAnyValue{IntValue: &s}}
+}
+
+func (o *OTLPOutput) request(result *CollectionResult, mfs []*dto.MetricFamily) *otlpExportRequest {
+	now := strconv.FormatInt(result.Time.UnixNano(), 10)
+	// Cumulative sums start when the Redis server did.
+	var start string
+	uptimeInSeconds, _ := result.Info.String("Server", "uptime_in_seconds")
+	if uptime, err := strconv.ParseInt(uptimeInSeconds, 10, 64); err == nil {
+		start = strconv.FormatInt(result.Time.Add(-time.Duration(uptime)*time.Second).UnixNano(), 10)
+	}
+
+	attributes := []otlpKeyValue{
+		otlpString("service.name", o.ServiceName),
+		otlpString("service.instance.id", result.Name),
+		otlpString("db.system", "redis"),
+	}
+	if host, port, err := net.SplitHostPort(result.Addr); err == nil {
+		attributes = append(attributes, otlpString("net.peer.name", host))
+		if p, err := strconv.ParseInt(port, 10, 64); err == nil {
+			attributes = append(attributes, otlpInt("net.peer.port", p))
+		}
+	}
+
+	metrics := make([]otlpMetric, 0, len(mfs))
+	// Metrics renamed to the same OpenTelemetry name share one otlpMetric.
+	merged := make(map[string]int)
+	for _, mf := range mfs {
+		if mf.GetName() == "redis_server_info" {
+			for _, l := range mf.GetMetric()[0].GetLabel() {
+				if l.GetName() == "redis_version" {
+					attributes = append(attributes, otlpString("redis.version", l.GetValue()))
+				}
+			}
+		}
+
+		metric := otlpMetric{Name: mf.GetName(), Description: mf.GetHelp()}
+		var extra map[string]string
+		if name, ok := otlpSemconvNames[mf.GetName()]; ok && o.Semconv {
+			metric.Name, metric.Unit, extra = name.name, name.unit, name.attributes
+			if extra != nil {
+				// The Prometheus help only describes one of the merged metrics.
+				metric.Description = ""
+			}
+		}
+		cumulative := otlpCumulative[mf.GetName()] || mf.GetType() == dto.MetricType_COUNTER
+
+		var points []otlpDataPoint
+		for _, m := range mf.GetMetric() {
+			var value float64
+			switch mf.GetType() {
+			case dto.MetricType_GAUGE:
+				value = m.GetGauge().GetValue()
+			case dto.MetricType_COUNTER:
+				value = m.GetCounter().GetValue()
+			case dto.MetricType_UNTYPED:
+				value = m.GetUntyped().GetValue()
+			default:
+				continue
+			}
+			point := otlpDataPoint{TimeUnixNano: now, AsDouble: value}
+			if cumulative {
+				point.StartTimeUnixNano = start
+			}
+			for _, l := range m.GetLabel() {
+				// node_name and node_address are resource attributes.
+				if l.GetName() != "node_name" && l.GetName() != "node_address" {
+					point.Attributes = append(point.Attributes, otlpString(l.GetName(), l.GetValue()))
+				}
+			}
+			for key, value := range extra {
+				point.Attributes = append(point.Attributes, otlpString(key, value))
+			}
+			points = append(points, point)
+		}
+		if len(points) == 0 {
+			continue
+		}
+
+		if i, ok := merged[metric.Name]; ok {
+			if metrics[i].Sum != nil {
+				metrics[i].Sum.DataPoints = append(metrics[i].Sum.DataPoints, points...)
+			} else {
+				metrics[i].Gauge.DataPoints = append(metrics[i].Gauge.DataPoints, points...)
+			}
+			continue
+		}
+		if cumulative {
+			metric.Sum = &otlpSum{
+				DataPoints:             points,
+				AggregationTemporality: otlpAggregationTemporalityCumulative,
+				IsMonotonic:            true,
+			}
+		} else {
+			metric.Gauge = &otlpGauge{DataPoints: points}
+		}
+		merged[metric.Name] = len(metrics)
+		metrics = append(metrics, metric)
+	}
+	sort.Slice(attributes, func(i, j int) bool { return attributes[i].Key < attributes[j].Key })
+
+	return &otlpExportRequest{ResourceMetrics: []otlpResourceMetrics{{
+		Resource: otlpResource{Attributes: attributes},
+		ScopeMetrics: []otlpScopeMetrics{{
+			Scope:   otlpScope{Name: "redis-metrics", Version: version.Version.String()},
+			Metrics: metrics,
+		}},
+	}}}
+}
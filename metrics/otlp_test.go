@@ -0,0 +1,133 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cwr0401/redis_metrics/metrics/info"
+	"github.com/cwr0401/redis_metrics/version"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestOTLPOutput(t *testing.T) {
+	var (
+		requests []interface{}
+		statuses []int
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" || r.Header.Get("X-Tenant") != "t" {
+			t.Errorf("headers %v", r.Header)
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		var request interface{}
+		if err := json.Unmarshal(b, &request); err != nil {
+			t.Errorf("decode export request: %s", err)
+		}
+		requests = append(requests, request)
+		status := http.StatusOK
+		if len(statuses) > 0 {
+			status, statuses = statuses[0], statuses[1:]
+		}
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	registry := prometheus.NewRegistry()
+	gauge := func(name string, labels ...string) *prometheus.GaugeVec {
+		g := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: name, Help: name},
+			append([]string{"node_name", "node_address"}, labels...))
+		registry.MustRegister(g)
+		return g
+	}
+	gauge("redis_cpu_used_cpu_sys").WithLabelValues("a", "10.0.0.1:6379").Set(1.5)
+	gauge("redis_cpu_used_cpu_user").WithLabelValues("a", "10.0.0.1:6379").Set(2.5)
+	gauge("redis_server_uptime_in_seconds").WithLabelValues("a", "10.0.0.1:6379").Set(100)
+	gauge("redis_cmdstat_calls", "cmd").WithLabelValues("a", "10.0.0.1:6379", "get").Set(7)
+	gauge("redis_memory_used_memory").WithLabelValues("b", "10.0.0.2:6379").Set(1)
+
+	o := NewOTLPOutput(server.URL, map[string]string{"X-Tenant": "t"}, "redis", true, registry,
+		NewExporterCollector(), 1, time.Millisecond, time.Second)
+	result := &CollectionResult{
+		Name: "a",
+		Addr: "10.0.0.1:6379",
+		Info: info.ParseInfo("# Server\r\nuptime_in_seconds:100\r\n"),
+		Time: time.Unix(1600000000, 0),
+	}
+	o.export(context.Background(), result)
+
+	want := `{"resourceMetrics": [{
+		"resource": {"attributes": [
+			{"key": "db.system", "value": {"stringValue": "redis"}},
+			{"key": "net.peer.name", "value": {"stringValue": "10.0.0.1"}},
+			{"key": "net.peer.port", "value": {"intValue": "6379"}},
+			{"key": "service.instance.id", "value": {"stringValue": "a"}},
+			{"key": "service.name", "value": {"stringValue": "redis"}}
+		]},
+		"scopeMetrics": [{
+			"scope": {"name": "redis-metrics", "version": "VERSION"},
+			"metrics": [
+				{"name": "redis_cmdstat_calls", "description": "redis_cmdstat_calls", "sum": {
+					"dataPoints": [{
+						"attributes": [{"key": "cmd", "value": {"stringValue": "get"}}],
+						"startTimeUnixNano": "1599999900000000000",
+						"timeUnixNano": "1600000000000000000",
+						"asDouble": 7
+					}],
+					"aggregationTemporality": 2,
+					"isMonotonic": true
+				}},
+				{"name": "redis.cpu.time", "unit": "s", "sum": {
+					"dataPoints": [
+						{
+							"attributes": [{"key": "state", "value": {"stringValue": "sys"}}],
+							"startTimeUnixNano": "1599999900000000000",
+							"timeUnixNano": "1600000000000000000",
+							"asDouble": 1.5
+						},
+						{
+							"attributes": [{"key": "state", "value": {"stringValue": "user"}}],
+							"startTimeUnixNano": "1599999900000000000",
+							"timeUnixNano": "1600000000000000000",
+							"asDouble": 2.5
+						}
+					],
+					"aggregationTemporality": 2,
+					"isMonotonic": true
+				}},
+				{"name": "redis.uptime", "description": "redis_server_uptime_in_seconds", "unit": "s", "gauge": {
+					"dataPoints": [{"timeUnixNano": "1600000000000000000", "asDouble": 100}]
+				}}
+			]
+		}]
+	}]}`
+	var expected interface{}
+	if err := json.Unmarshal([]byte(strings.Replace(want, "VERSION", version.Version.String(), 1)), &expected); err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != 1 {
+		t.Fatalf("%d requests, want 1", len(requests))
+	}
+	if !reflect.DeepEqual(requests[0], expected) {
+		got, _ := json.MarshalIndent(requests[0], "", "  ")
+		t.Errorf("exported\n%s", got)
+	}
+
+	// Only the statuses the specification allows are retried.
+	requests, statuses = nil, []int{http.StatusServiceUnavailable}
+	o.export(context.Background(), result)
+	if len(requests) != 2 {
+		t.Errorf("%d attempts after 503, want 2", len(requests))
+	}
+	requests, statuses = nil, []int{http.StatusBadRequest}
+	o.export(context.Background(), result)
+	if len(requests) != 1 {
+		t.Errorf("%d attempts after 400, want 1", len(requests))
+	}
+}
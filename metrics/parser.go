@@ -1,21 +1,5 @@
 package metrics
 
-import (
-	"strings"
-)
-
-func RedisInfoResultParser(infoResult string) map[string]string {
-	infoResultLines := strings.Split(infoResult, "\r\n")
-	infoResultMap := make(map[string]string)
-	for _, line := range infoResultLines {
-		if strings.ContainsRune(line, ':') {
-			item := strings.SplitN(line, ":", 2)
-			infoResultMap[item[0]] = item[1] // strings.Replace(item[1], "\r", "", -1)
-		}
-	}
-	return infoResultMap
-}
-
 //func redisInfoToMetrics(nodeName, nodeAddress string, rdb *redis.Client) error {
 //	err := rdb.Ping().Err()
 //	if err != nil {
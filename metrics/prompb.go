@@ -0,0 +1,158 @@
+package metrics
+
+import (
+	"encoding/binary"
+	"math"
+	"sort"
+	"strconv"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// The types below mirror the messages of the Prometheus remote write
+// protocol (prompb/remote.proto and prompb/types.proto). Only the fields
+// needed to send samples are implemented.
+
+type promLabel struct {
+	Name  string
+	Value string
+}
+
+type promSample struct {
+	Value     float64
+	Timestamp int64
+}
+
+type promTimeSeries struct {
+	Labels  []promLabel
+	Samples []promSample
+}
+
+// encodeWriteRequest returns the protobuf encoding of a WriteRequest holding
+// series.
+func encodeWriteRequest(series []promTimeSeries) []byte {
+	var buf []byte
+	for _, ts := range series {
+		buf = appendMessage(buf, 1, encodeTimeSeries(ts))
+	}
+	return buf
+}
+
+func encodeTimeSeries(ts promTimeSeries) []byte {
+	var buf []byte
+	for _, l := range ts.Labels {
+		var label []byte
+		label = appendString(label, 1, l.Name)
+		label = appendString(label, 2, l.Value)
+		buf = appendMessage(buf, 1, label)
+	}
+	for _, s := range ts.Samples {
+		var sample []byte
+		sample = appendTag(sample, 1, 1)
+		sample = appendFixed64(sample, math.Float64bits(s.Value))
+		sample = appendTag(sample, 2, 0)
+		sample = appendUvarint(sample, uint64(s.Timestamp))
+		buf = appendMessage(buf, 2, sample)
+	}
+	return buf
+}
+
+func appendTag(buf []byte, field, wireType int) []byte {
+	return appendUvarint(buf, uint64(field<<3|wireType))
+}
+
+func appendUvarint(buf []byte, v uint64) []byte {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], v)
+	return append(buf, b[:n]...)
+}
+
+func appendFixed64(buf []byte, v uint64) []byte {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], v)
+	return append(buf, b[:]...)
+}
+
+func appendString(buf []byte, field int, s string) []byte {
+	buf = appendTag(buf, field, 2)
+	buf = appendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+func appendMessage(buf []byte, field int, msg []byte) []byte {
+	buf = appendTag(buf, field, 2)
+	buf = appendUvarint(buf, uint64(len(msg)))
+	return append(buf, msg...)
+}
+
+// metricFamiliesToSeries flattens gathered metric families into remote write
+// series, expanding histograms and summaries the way the text exposition
+// format does. extraLabels are added to every series and labels are sorted
+// by name as the protocol requires. Like Prometheus' external labels, they
+// do not override labels the series already has.
+func metricFamiliesToSeries(mfs []*dto.MetricFamily, extraLabels []promLabel, timestamp int64) []promTimeSeries {
+	var series []promTimeSeries
+	add := func(name string, m *dto.Metric, value float64, extra ...promLabel) {
+		labels := []promLabel{{Name: "__name__", Value: name}}
+		for _, l := range m.GetLabel() {
+			labels = append(labels, promLabel{Name: l.GetName(), Value: l.GetValue()})
+		}
+		labels = append(labels, extra...)
+		for _, l := range extraLabels {
+			if !hasLabel(labels, l.Name) {
+				labels = append(labels, l)
+			}
+		}
+		sort.Slice(labels, func(i, j int) bool { return labels[i].Name < labels[j].Name })
+		series = append(series, promTimeSeries{
+			Labels:  labels,
+			Samples: []promSample{{Value: value, Timestamp: timestamp}},
+		})
+	}
+	for _, mf := range mfs {
+		name := mf.GetName()
+		for _, m := range mf.GetMetric() {
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				add(name, m, m.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				add(name, m, m.GetGauge().GetValue())
+			case dto.MetricType_UNTYPED:
+				add(name, m, m.GetUntyped().GetValue())
+			case dto.MetricType_HISTOGRAM:
+				h := m.GetHistogram()
+				for _, b := range h.GetBucket() {
+					add(name+"_bucket", m, float64(b.GetCumulativeCount()),
+						promLabel{Name: "le", Value: formatFloat(b.GetUpperBound())})
+				}
+				add(name+"_bucket", m, float64(h.GetSampleCount()), promLabel{Name: "le", Value: "+Inf"})
+				add(name+"_sum", m, h.GetSampleSum())
+				add(name+"_count", m, float64(h.GetSampleCount()))
+			case dto.MetricType_SUMMARY:
+				s := m.GetSummary()
+				for _, q := range s.GetQuantile() {
+					add(name, m, q.GetValue(), promLabel{Name: "quantile", Value: formatFloat(q.GetQuantile())})
+				}
+				add(name+"_sum", m, s.GetSampleSum())
+				add(name+"_count", m, float64(s.GetSampleCount()))
+			}
+		}
+	}
+	return series
+}
+
+func hasLabel(labels []promLabel, name string) bool {
+	for _, l := range labels {
+		if l.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func formatFloat(f float64) string {
+	if math.IsInf(f, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,186 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	dto "github.com/prometheus/client_model/go"
+	log "github.com/sirupsen/logrus"
+)
+
+const OutputPushgateway = "pushgateway"
+
+// PushgatewayOutput pushes the series of a target to a Pushgateway after
+// each of its collections, in a group keyed by the target's name. The group
+// is deleted when the target is removed.
+type PushgatewayOutput struct {
+	URL      string
+	Job      string
+	Gatherer prometheus.Gatherer
+	Exporter *ExporterCollector
+	Retries  int
+	Backoff  time.Duration
+
+	client *http.Client
+	queue  chan pushRequest
+}
+
+type pushRequest struct {
+	nodeName    string
+	nodeAddress string
+	delete      bool
+}
+
+func NewPushgatewayOutput(url, job string, gatherer prometheus.Gatherer, exporter *ExporterCollector,
+	retries int, backoff, timeout time.Duration) *PushgatewayOutput {
+	return &PushgatewayOutput{
+		URL:      url,
+		Job:      job,
+		Gatherer: gatherer,
+		Exporter: exporter,
+		Retries:  retries,
+		Backoff:  backoff,
+		client:   &http.Client{Timeout: timeout},
+		queue:    make(chan pushRequest, 1024),
+	}
+}
+
+func (p *PushgatewayOutput) Collected(result *CollectionResult) {
+	p.enqueue(pushRequest{nodeName: result.Name, nodeAddress: result.Addr})
+}
+
+func (p *PushgatewayOutput) Removed(nodeName, nodeAddress string) {
+	p.enqueue(pushRequest{nodeName: nodeName, nodeAddress: nodeAddress, delete: true})
+}
+
+func (p *PushgatewayOutput) enqueue(req pushRequest) {
+	select {
+	case p.queue <- req:
+	default:
+		p.Exporter.OutputDropped.WithLabelValues(OutputPushgateway).Inc()
+		log.WithFields(log.Fields{
+			"node": req.nodeName,
+			"addr": req.nodeAddress,
+		}).Warn("Pushgateway queue is full, dropping push")
+	}
+}
+
+// Run sends the queued pushes until stop is closed, then the pushes still
+// queued. Once ctx is done the rest are dropped.
+func (p *PushgatewayOutput) Run(ctx context.Context, stop <-chan struct{}) {
+	log.Infof("Pushing metrics to %s", p.URL)
+	defer func() { dropQueued(p.Exporter, OutputPushgateway, len(p.queue)) }()
+	for ctx.Err() == nil {
+		var req pushRequest
+		select {
+		case <-ctx.Done():
+			return
+		case req = <-p.queue:
+		case <-stop:
+			select {
+			case req = <-p.queue:
+			default:
+				return
+			}
+		}
+		p.send(ctx, req)
+	}
+}
+
+func (p *PushgatewayOutput) send(ctx context.Context, req pushRequest) {
+	err := retryWithBackoff(ctx, p.Retries, p.Backoff, func() error {
+		pusher := push.New(p.URL, p.Job).Client(p.client).Grouping("instance", req.nodeName)
+		var err error
+		if req.delete {
+			err = pusher.Delete()
+		} else {
+			err = pusher.Gatherer(nodeGatherer{p.Gatherer, req.nodeName, req.nodeAddress}).Push()
+		}
+		p.Exporter.ObserveOutput(OutputPushgateway, err)
+		return err
+	})
+	if err != nil {
+		p.Exporter.OutputDropped.WithLabelValues(OutputPushgateway).Inc()
+		log.WithFields(log.Fields{
+			"node": req.nodeName,
+			"addr": req.nodeAddress,
+		}).Errorf("Push to Pushgateway failed: %s", err)
+	}
+}
+
+// dropQueued counts the n updates an output left in its queue when it had
+// to stop before sending them.
+func dropQueued(exporter *ExporterCollector, output string, n int) {
+	if n == 0 {
+		return
+	}
+	exporter.OutputDropped.WithLabelValues(output).Add(float64(n))
+	log.Warnf("Stopped %s output with %d updates queued, dropping them", output, n)
+}
+
+// permanentError wraps an error that retrying will not fix.
+type permanentError struct {
+	error
+}
+
+// retryWithBackoff calls fn until it succeeds or returns a permanentError,
+// at most retries+1 times, doubling the wait between attempts.
+func retryWithBackoff(ctx context.Context, retries int, backoff time.Duration, fn func() error) error {
+	err := fn()
+	for attempt := 0; err != nil && attempt < retries; attempt++ {
+		if _, ok := err.(permanentError); ok {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff << uint(attempt)):
+		}
+		err = fn()
+	}
+	return err
+}
+
+// nodeGatherer only returns the series of one Redis target.
+type nodeGatherer struct {
+	gatherer    prometheus.Gatherer
+	nodeName    string
+	nodeAddress string
+}
+
+func (g nodeGatherer) Gather() ([]*dto.MetricFamily, error) {
+	mfs, err := g.gatherer.Gather()
+	if err != nil {
+		return nil, err
+	}
+	var filtered []*dto.MetricFamily
+	for _, mf := range mfs {
+		var metrics []*dto.Metric
+		for _, m := range mf.GetMetric() {
+			if metricHasNode(m, g.nodeName, g.nodeAddress) {
+				metrics = append(metrics, m)
+			}
+		}
+		if len(metrics) > 0 {
+			mf.Metric = metrics
+			filtered = append(filtered, mf)
+		}
+	}
+	return filtered, nil
+}
+
+func metricHasNode(m *dto.Metric, nodeName, nodeAddress string) bool {
+	var name, address string
+	for _, label := range m.GetLabel() {
+		switch label.GetName() {
+		case "node_name":
+			name = label.GetValue()
+		case "node_address":
+			address = label.GetValue()
+		}
+	}
+	return name == nodeName && address == nodeAddress
+}
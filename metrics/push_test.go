@@ -0,0 +1,111 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+func TestPushgatewayOutput(t *testing.T) {
+	type pushed struct {
+		method, path string
+		families     []*dto.MetricFamily
+	}
+	var (
+		mu       sync.Mutex
+		requests []pushed
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := pushed{method: r.Method, path: r.URL.Path}
+		decoder := expfmt.NewDecoder(r.Body, expfmt.ResponseFormat(r.Header))
+		for {
+			var mf dto.MetricFamily
+			if err := decoder.Decode(&mf); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Errorf("decode push: %s", err)
+				break
+			}
+			req.families = append(req.families, &mf)
+		}
+		mu.Lock()
+		requests = append(requests, req)
+		mu.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	registry := prometheus.NewRegistry()
+	up := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "redis_server_up", Help: "up"},
+		[]string{"node_name", "node_address"})
+	registry.MustRegister(up)
+	up.WithLabelValues("a", "10.0.0.1:6379").Set(1)
+	up.WithLabelValues("b", "10.0.0.2:6379").Set(0)
+
+	p := NewPushgatewayOutput(server.URL, "redis", registry, NewExporterCollector(), 0, time.Millisecond, time.Second)
+	p.send(context.Background(), pushRequest{nodeName: "a", nodeAddress: "10.0.0.1:6379"})
+	p.send(context.Background(), pushRequest{nodeName: "a", nodeAddress: "10.0.0.1:6379", delete: true})
+
+	if len(requests) != 2 {
+		t.Fatalf("%d requests, want 2", len(requests))
+	}
+	push := requests[0]
+	if push.method != http.MethodPut || push.path != "/metrics/job/redis/instance/a" {
+		t.Errorf("pushed with %s %s", push.method, push.path)
+	}
+	if len(push.families) != 1 || len(push.families[0].Metric) != 1 {
+		t.Fatalf("pushed %v, want the series of a only", push.families)
+	}
+	if !metricHasNode(push.families[0].Metric[0], "a", "10.0.0.1:6379") {
+		t.Errorf("pushed %v, want the series of a", push.families[0].Metric[0])
+	}
+	if del := requests[1]; del.method != http.MethodDelete || del.path != "/metrics/job/redis/instance/a" {
+		t.Errorf("deleted with %s %s", del.method, del.path)
+	}
+}
+
+func TestPushgatewayOutputStop(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		paths []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	// Once stopped, the queued pushes are sent before Run returns.
+	exporter := NewExporterCollector()
+	p := NewPushgatewayOutput(server.URL, "redis", prometheus.NewRegistry(), exporter, 0, time.Millisecond, time.Second)
+	p.Removed("a", "10.0.0.1:6379")
+	p.Removed("b", "10.0.0.2:6379")
+	stop := make(chan struct{})
+	close(stop)
+	p.Run(context.Background(), stop)
+	if len(paths) != 2 {
+		t.Errorf("sent %v on stop, want the 2 queued pushes", paths)
+	}
+
+	// Past the shutdown deadline, they are dropped.
+	p.Removed("a", "10.0.0.1:6379")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	p.Run(ctx, nil)
+	if len(paths) != 2 {
+		t.Errorf("sent %v after the deadline", paths[2:])
+	}
+	if n := counterValue(exporter.OutputDropped.WithLabelValues(OutputPushgateway)); n != 1 {
+		t.Errorf("%v pushes dropped after the deadline, want 1", n)
+	}
+}
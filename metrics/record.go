@@ -0,0 +1,256 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cwr0401/redis_metrics/metrics/info"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	snapshotSuffix     = ".json"
+	snapshotTimeLayout = "20060102T150405.000000000Z"
+)
+
+// Snapshot is the recorded outcome of a collection: the raw output of every
+// command run, keyed by the command line, or the error that ended it.
+type Snapshot struct {
+	NodeName       string            `json:"node_name"`
+	NodeAddress    string            `json:"node_address"`
+	Time           time.Time         `json:"time"`
+	LatencySeconds float64           `json:"latency_seconds,omitempty"`
+	Error          string            `json:"error,omitempty"`
+	DownReason     string            `json:"down_reason,omitempty"`
+	Commands       map[string]string `json:"commands,omitempty"`
+}
+
+// Recorder writes a Snapshot of every collection to Dir/<node_name>/ in a
+// file named after the collection time. With MaxFiles set, only the newest
+// MaxFiles snapshots of a target are kept.
+type Recorder struct {
+	Dir      string
+	MaxFiles int
+
+	queue chan *CollectionResult
+}
+
+func NewRecorder(dir string, maxFiles int) *Recorder {
+	return &Recorder{
+		Dir:      dir,
+		MaxFiles: maxFiles,
+		queue:    make(chan *CollectionResult, 1024),
+	}
+}
+
+func (r *Recorder) Collected(result *CollectionResult) {
+	select {
+	case r.queue <- result:
+	default:
+		log.WithFields(log.Fields{
+			"node": result.Name,
+			"addr": result.Addr,
+		}).Warn("Recorder queue is full, dropping snapshot")
+	}
+}
+
+// Removed keeps the recorded snapshots, they are what replay is for.
+func (r *Recorder) Removed(nodeName, nodeAddress string) {}
+
+// Run writes the queued snapshots until stop is closed, then the snapshots
+// still queued. Once ctx is done the rest are dropped.
+func (r *Recorder) Run(ctx context.Context, stop <-chan struct{}) {
+	log.Infof("Recording INFO snapshots to %s", r.Dir)
+	defer func() {
+		if n := len(r.queue); n > 0 {
+			log.Warnf("Stopped recording with %d snapshots queued, dropping them", n)
+		}
+	}()
+	for ctx.Err() == nil {
+		var result *CollectionResult
+		select {
+		case <-ctx.Done():
+			return
+		case result = <-r.queue:
+		case <-stop:
+			select {
+			case result = <-r.queue:
+			default:
+				return
+			}
+		}
+		if err := r.record(result); err != nil {
+			log.WithFields(log.Fields{
+				"node": result.Name,
+				"addr": result.Addr,
+			}).Errorf("Record snapshot error: %s", err)
+		}
+	}
+}
+
+func (r *Recorder) record(result *CollectionResult) error {
+	snapshot := Snapshot{
+		NodeName:       result.Name,
+		NodeAddress:    result.Addr,
+		Time:           result.Time.UTC(),
+		LatencySeconds: result.Latency.Seconds(),
+	}
+	if result.Err != nil {
+		snapshot.Error = result.Err.Error()
+		snapshot.DownReason = result.Info.Down
+	}
+	if result.Raw != "" {
+		snapshot.Commands = map[string]string{"INFO all": result.Raw}
+	}
+	content, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Join(r.Dir, strings.Replace(result.Name, string(filepath.Separator), "_", -1))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	path := filepath.Join(dir, snapshot.Time.Format(snapshotTimeLayout)+snapshotSuffix)
+	// Write to a temporary file first so replay never sees a partial snapshot.
+	if err := ioutil.WriteFile(path+".tmp", content, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return err
+	}
+	return r.rotate(dir)
+}
+
+func (r *Recorder) rotate(dir string) error {
+	if r.MaxFiles <= 0 {
+		return nil
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*"+snapshotSuffix))
+	if err != nil {
+		return err
+	}
+	// The names sort by time.
+	sort.Strings(files)
+	for len(files) > r.MaxFiles {
+		if err := os.Remove(files[0]); err != nil {
+			return err
+		}
+		files = files[1:]
+	}
+	return nil
+}
+
+// readSnapshots reads the snapshots in paths, which are snapshot files or
+// directories searched recursively, sorted by collection time.
+func readSnapshots(paths []string) ([]*Snapshot, error) {
+	var snapshots []*Snapshot
+	for _, root := range paths {
+		err := filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if fi.IsDir() || !strings.HasSuffix(path, snapshotSuffix) {
+				return nil
+			}
+			content, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			snapshot := &Snapshot{}
+			if err := json.Unmarshal(content, snapshot); err != nil {
+				return fmt.Errorf("%s: %s", path, err)
+			}
+			snapshots = append(snapshots, snapshot)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.SliceStable(snapshots, func(i, j int) bool { return snapshots[i].Time.Before(snapshots[j].Time) })
+	return snapshots, nil
+}
+
+// replaySnapshot feeds snapshot through the parser and the collector the way
+// a live collection would.
+func replaySnapshot(rmc info.RedisCollector, snapshot *Snapshot) error {
+	var infoMap *info.Info
+	if raw, ok := snapshot.Commands["INFO all"]; ok && snapshot.Error == "" {
+		infoMap = info.ParseInfo(raw)
+	} else {
+		infoMap = info.DownInfo(snapshot.DownReason)
+	}
+	if err := rmc.Set(snapshot.NodeName, snapshot.NodeAddress, infoMap); err != nil {
+		return err
+	}
+	if snapshot.LatencySeconds > 0 {
+		rmc.SetPingLatency(snapshot.NodeName, snapshot.NodeAddress,
+			time.Duration(snapshot.LatencySeconds*float64(time.Second)))
+	}
+	return nil
+}
+
+// ReplayAction replays the recorded snapshots given as arguments, in time
+// order, and prints the resulting series after the last snapshot or, with
+// --every, after each of them. No Redis server is needed.
+func ReplayAction(c *cli.Context) error {
+	log.SetOutput(os.Stderr)
+	log.SetLevel(log.WarnLevel)
+	if c.Bool("debug") {
+		log.SetLevel(log.DebugLevel)
+	}
+	format := c.String("format")
+	switch format {
+	case FormatPrometheus, FormatJSON, FormatTable:
+	default:
+		return fmt.Errorf("unknown format %q, must be %s, %s or %s", format, FormatPrometheus, FormatJSON, FormatTable)
+	}
+	if c.NArg() == 0 {
+		return fmt.Errorf("no snapshot files or directories given")
+	}
+	snapshots, err := readSnapshots(c.Args().Slice())
+	if err != nil {
+		return err
+	}
+	if len(snapshots) == 0 {
+		return fmt.Errorf("no snapshots found")
+	}
+
+	registry := prometheus.NewRegistry()
+	rmc := info.NewRedisCollector()
+	rmc.MustRegister(registry)
+	for i, snapshot := range snapshots {
+		// Set fails for the snapshots of a down server, as it does live.
+		if err := replaySnapshot(rmc, snapshot); err != nil && snapshot.Error == "" {
+			log.WithFields(log.Fields{
+				"node": snapshot.NodeName,
+				"addr": snapshot.NodeAddress,
+			}).Errorf("Set Redis metrics error: %s", err)
+		}
+		if !c.Bool("every") && i < len(snapshots)-1 {
+			continue
+		}
+		// A comment line would break the JSON output.
+		if c.Bool("every") && format != FormatJSON {
+			fmt.Printf("# snapshot %s %s\n", snapshot.NodeName, snapshot.Time.Format(time.RFC3339Nano))
+		}
+		mfs, err := registry.Gather()
+		if err != nil {
+			return err
+		}
+		if err := writeMetricFamilies(os.Stdout, mfs, format); err != nil {
+			return err
+		}
+	}
+	return nil
+}
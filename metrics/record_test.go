@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cwr0401/redis_metrics/metrics/info"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestRecordReplay(t *testing.T) {
+	raw, err := ioutil.ReadFile(filepath.Join("internal", "fakeredis", "testdata", "7.2", "info.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "record")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	now := time.Now()
+	up := &CollectionResult{Name: "a", Addr: "10.0.0.1:6379", Info: info.ParseInfo(string(raw)),
+		Raw: string(raw), Latency: 2 * time.Millisecond, Time: now}
+	down := &CollectionResult{Name: "b", Addr: "10.0.0.2:6379", Err: errors.New("connection refused"),
+		Info: info.DownInfo(info.RedisServerDownReasonConnectionRefused), Time: now.Add(time.Second)}
+	r := NewRecorder(dir, 0)
+	for _, result := range []*CollectionResult{up, down} {
+		if err := r.record(result); err != nil {
+			t.Fatalf("record %s: %s", result.Name, err)
+		}
+	}
+
+	snapshots, err := readSnapshots([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 2 || snapshots[0].NodeName != "a" || snapshots[1].NodeName != "b" {
+		t.Fatalf("read %d snapshots, want those of a and b in time order", len(snapshots))
+	}
+	if reason := snapshots[1].DownReason; reason != info.RedisServerDownReasonConnectionRefused {
+		t.Errorf("down snapshot reason %q, want %q", reason, info.RedisServerDownReasonConnectionRefused)
+	}
+
+	exposition := func(set func(rmc info.RedisCollector)) string {
+		registry := prometheus.NewRegistry()
+		rmc := info.NewRedisCollector()
+		rmc.MustRegister(registry)
+		set(rmc)
+		mfs, err := registry.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := writeMetricFamilies(&buf, mfs, FormatPrometheus); err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+	live := exposition(func(rmc info.RedisCollector) {
+		rmc.Set(up.Name, up.Addr, up.Info)
+		rmc.SetPingLatency(up.Name, up.Addr, up.Latency)
+		rmc.Set(down.Name, down.Addr, down.Info)
+	})
+	replayed := exposition(func(rmc info.RedisCollector) {
+		for _, snapshot := range snapshots {
+			replaySnapshot(rmc, snapshot)
+		}
+	})
+	downSeries := `redis_server_up_reason{node_address="10.0.0.2:6379",node_name="b",reason="connection_refused"} 1`
+	if !strings.Contains(live, downSeries) {
+		t.Fatalf("live series lack %s", downSeries)
+	}
+	if replayed != live {
+		t.Errorf("replayed series differ from the live ones:\n%s\nwant:\n%s", replayed, live)
+	}
+}
@@ -0,0 +1,139 @@
+package metrics
+
+import (
+	"crypto/sha256"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/cwr0401/redis_metrics/config"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	ReloadTriggerHTTP   = "http"
+	ReloadTriggerSignal = "signal"
+	ReloadTriggerFile   = "file"
+)
+
+var reloadChan = make(chan struct{}, 1)
+
+// triggerReload requests a configuration reload. Requests made while one is
+// already pending are merged into it.
+func triggerReload(trigger string, exporter *ExporterCollector) {
+	log.Infof("Configuration reload requested by %s", trigger)
+	if exporter != nil {
+		exporter.ConfigReloadTriggers.WithLabelValues(trigger).Inc()
+	}
+	select {
+	case reloadChan <- struct{}{}:
+	default:
+	}
+}
+
+// watchSignal triggers a reload every time the process receives SIGHUP.
+func watchSignal(exporter *ExporterCollector) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGHUP)
+	for range c {
+		triggerReload(ReloadTriggerSignal, exporter)
+	}
+}
+
+// configFileState identifies a version of the configuration file. The target
+// of the symbolic link is part of it so that an atomic symlink swap, as done
+// by Kubernetes for ConfigMap volumes, is noticed even when the content of
+// the new target is identical.
+type configFileState struct {
+	target string
+	sum    [sha256.Size]byte
+	err    string
+}
+
+func readConfigFileState(path string) configFileState {
+	var state configFileState
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		state.err = err.Error()
+		return state
+	}
+	state.target = target
+	content, err := ioutil.ReadFile(target)
+	if err != nil {
+		state.err = err.Error()
+		return state
+	}
+	state.sum = sha256.Sum256(content)
+	return state
+}
+
+// watchConfigFile polls the configuration file every interval and triggers
+// a reload when it changes.
+func watchConfigFile(path string, interval time.Duration, exporter *ExporterCollector) {
+	configFilePath, err := filepath.Abs(path)
+	if err != nil {
+		log.Errorf("Watch configuration file error: %s", err)
+		return
+	}
+	log.Infof("Watching configuration file %s every %s", configFilePath, interval)
+	last := readConfigFileState(configFilePath)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		state := readConfigFileState(configFilePath)
+		if state == last {
+			continue
+		}
+		last = state
+		if state.err != "" {
+			log.Warnf("Configuration file changed but cannot be read: %s", state.err)
+		}
+		triggerReload(ReloadTriggerFile, exporter)
+	}
+}
+
+// waitReload blocks until a reload is requested and no further request has
+// arrived for the debounce period.
+func waitReload(debounce time.Duration) {
+	<-reloadChan
+	if debounce <= 0 {
+		return
+	}
+	timer := time.NewTimer(debounce)
+	for {
+		select {
+		case <-reloadChan:
+			if !timer.Stop() {
+				<-timer.C
+			}
+			timer.Reset(debounce)
+		case <-timer.C:
+			return
+		}
+	}
+}
+
+// reloadConfig waits for reload requests and returns the first configuration
+// that parses, validates and differs from redisConfig.
+func reloadConfig(configFile string, redisConfig *config.RedisConfig, debounce time.Duration,
+	exporter *ExporterCollector) *config.RedisConfig {
+	for {
+		waitReload(debounce)
+		log.Info("Reload configuration file")
+		newRedisConfig, err := loadConfigFile(configFile)
+		exporter.ObserveReload(err)
+		if err != nil {
+			log.Errorf("Reload configuration file error: %s", err)
+			continue
+		}
+		if newRedisConfig.Equal(*redisConfig) {
+			log.Info("config file no changes")
+			continue
+		} else {
+			return newRedisConfig
+		}
+	}
+}
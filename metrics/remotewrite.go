@@ -0,0 +1,201 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+const OutputRemoteWrite = "remote_write"
+
+// RemoteWriteOutput sends the series of a target to a Prometheus remote
+// write endpoint (Prometheus, Cortex, Mimir, VictoriaMetrics, ...) after each
+// of its collections. Series are queued and sent in batches of at most
+// BatchSize, a partial batch is sent after FlushInterval.
+type RemoteWriteOutput struct {
+	URL            string
+	ExternalLabels []promLabel
+	Headers        map[string]string
+	Gatherer       prometheus.Gatherer
+	Exporter       *ExporterCollector
+	BatchSize      int
+	FlushInterval  time.Duration
+	Retries        int
+	Backoff        time.Duration
+
+	client *http.Client
+	queue  chan promTimeSeries
+}
+
+func NewRemoteWriteOutput(url string, externalLabels []promLabel, headers map[string]string,
+	gatherer prometheus.Gatherer, exporter *ExporterCollector, batchSize, capacity int,
+	flushInterval time.Duration, retries int, backoff, timeout time.Duration) *RemoteWriteOutput {
+	return &RemoteWriteOutput{
+		URL:            url,
+		ExternalLabels: externalLabels,
+		Headers:        headers,
+		Gatherer:       gatherer,
+		Exporter:       exporter,
+		BatchSize:      batchSize,
+		FlushInterval:  flushInterval,
+		Retries:        retries,
+		Backoff:        backoff,
+		client:         &http.Client{Timeout: timeout},
+		queue:          make(chan promTimeSeries, capacity),
+	}
+}
+
+func (o *RemoteWriteOutput) Collected(result *CollectionResult) {
+	mfs, err := nodeGatherer{o.Gatherer, result.Name, result.Addr}.Gather()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"node": result.Name,
+			"addr": result.Addr,
+		}).Errorf("Gather metrics for remote write error: %s", err)
+		return
+	}
+	timestamp := result.Time.UnixNano() / int64(time.Millisecond)
+	dropped := 0
+	for _, ts := range metricFamiliesToSeries(mfs, o.ExternalLabels, timestamp) {
+		select {
+		case o.queue <- ts:
+		default:
+			dropped++
+		}
+	}
+	if dropped > 0 {
+		o.Exporter.OutputDropped.WithLabelValues(OutputRemoteWrite).Add(float64(dropped))
+		log.WithFields(log.Fields{
+			"node": result.Name,
+			"addr": result.Addr,
+		}).Warnf("Remote write queue is full, dropping %d series", dropped)
+	}
+}
+
+// Removed does nothing, remote write has no way to delete series. They go
+// stale in the receiver once no more samples arrive.
+func (o *RemoteWriteOutput) Removed(nodeName, nodeAddress string) {}
+
+// Run sends the queued series until stop is closed, then the series still
+// queued and the partial batch. Once ctx is done the rest, the partial batch
+// included, are dropped.
+func (o *RemoteWriteOutput) Run(ctx context.Context, stop <-chan struct{}) {
+	log.Infof("Sending metrics to remote write endpoint %s", o.URL)
+	ticker := time.NewTicker(o.FlushInterval)
+	defer ticker.Stop()
+	batch := make([]promTimeSeries, 0, o.BatchSize)
+	defer func() { dropQueued(o.Exporter, OutputRemoteWrite, len(batch)+len(o.queue)) }()
+	for ctx.Err() == nil {
+		select {
+		case <-ctx.Done():
+			return
+		case ts := <-o.queue:
+			batch = append(batch, ts)
+			if len(batch) < o.BatchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		case <-stop:
+			select {
+			case ts := <-o.queue:
+				batch = append(batch, ts)
+				if len(batch) < o.BatchSize {
+					continue
+				}
+			default:
+				if len(batch) > 0 {
+					o.send(ctx, batch)
+					batch = batch[:0]
+				}
+				return
+			}
+		}
+		o.send(ctx, batch)
+		batch = batch[:0]
+	}
+}
+
+func (o *RemoteWriteOutput) send(ctx context.Context, batch []promTimeSeries) {
+	body := snappy.Encode(nil, encodeWriteRequest(batch))
+	err := retryWithBackoff(ctx, o.Retries, o.Backoff, func() error {
+		err := o.write(ctx, body)
+		o.Exporter.ObserveOutput(OutputRemoteWrite, err)
+		return err
+	})
+	if err != nil {
+		o.Exporter.OutputDropped.WithLabelValues(OutputRemoteWrite).Add(float64(len(batch)))
+		log.Errorf("Remote write of %d series failed: %s", len(batch), err)
+	}
+}
+
+func (o *RemoteWriteOutput) write(ctx context.Context, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, o.URL, bytes.NewReader(body))
+	if err != nil {
+		return permanentError{err}
+	}
+	req = req.WithContext(ctx)
+	for name, value := range o.Headers {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("User-Agent", "redis-metrics")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		io.Copy(ioutil.Discard, resp.Body)
+		return nil
+	}
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 256))
+	err = fmt.Errorf("server returned HTTP status %s: %s", resp.Status, bytes.TrimSpace(msg))
+	// Only server errors and rate limiting are worth retrying, the receiver
+	// would reject the same batch again for any other status.
+	if resp.StatusCode/100 == 5 || resp.StatusCode == http.StatusTooManyRequests {
+		return err
+	}
+	return permanentError{err}
+}
+
+// parseKeyValues parses NAME=VALUE pairs as given to the remote write flags.
+func parseKeyValues(values []string) (map[string]string, error) {
+	pairs := make(map[string]string, len(values))
+	for _, v := range values {
+		i := strings.Index(v, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid %q, expected NAME=VALUE", v)
+		}
+		pairs[v[:i]] = v[i+1:]
+	}
+	return pairs, nil
+}
+
+func externalLabels(values []string) ([]promLabel, error) {
+	pairs, err := parseKeyValues(values)
+	if err != nil {
+		return nil, err
+	}
+	labels := make([]promLabel, 0, len(pairs))
+	for name, value := range pairs {
+		labels = append(labels, promLabel{Name: name, Value: value})
+	}
+	sort.Slice(labels, func(i, j int) bool { return labels[i].Name < labels[j].Name })
+	return labels, nil
+}
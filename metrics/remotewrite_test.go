@@ -0,0 +1,236 @@
+package metrics
+
+import (
+	"context"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// The messages of prompb/remote.proto and prompb/types.proto, decoded with
+// the protobuf library to check encodeWriteRequest.
+
+type testWriteRequest struct {
+	Timeseries []*testTimeSeries `protobuf:"bytes,1,rep,name=timeseries,proto3"`
+}
+
+func (m *testWriteRequest) Reset()         { *m = testWriteRequest{} }
+func (m *testWriteRequest) String() string { return proto.CompactTextString(m) }
+func (*testWriteRequest) ProtoMessage()    {}
+
+type testTimeSeries struct {
+	Labels  []*testLabel  `protobuf:"bytes,1,rep,name=labels,proto3"`
+	Samples []*testSample `protobuf:"bytes,2,rep,name=samples,proto3"`
+}
+
+func (m *testTimeSeries) Reset()         { *m = testTimeSeries{} }
+func (m *testTimeSeries) String() string { return proto.CompactTextString(m) }
+func (*testTimeSeries) ProtoMessage()    {}
+
+type testLabel struct {
+	Name  string `protobuf:"bytes,1,opt,name=name,proto3"`
+	Value string `protobuf:"bytes,2,opt,name=value,proto3"`
+}
+
+func (m *testLabel) Reset()         { *m = testLabel{} }
+func (m *testLabel) String() string { return proto.CompactTextString(m) }
+func (*testLabel) ProtoMessage()    {}
+
+type testSample struct {
+	Value     float64 `protobuf:"fixed64,1,opt,name=value,proto3"`
+	Timestamp int64   `protobuf:"varint,2,opt,name=timestamp,proto3"`
+}
+
+func (m *testSample) Reset()         { *m = testSample{} }
+func (m *testSample) String() string { return proto.CompactTextString(m) }
+func (*testSample) ProtoMessage()    {}
+
+func TestEncodeWriteRequest(t *testing.T) {
+	series := []promTimeSeries{
+		{
+			Labels: []promLabel{{Name: "__name__", Value: "redis_server_up"}, {Name: "node_name", Value: "a"}},
+			Samples: []promSample{
+				{Value: 1, Timestamp: 1600000000000},
+				{Value: math.Inf(-1), Timestamp: -1},
+			},
+		},
+		{
+			Labels:  []promLabel{{Name: "__name__", Value: "redis_memory_used_memory"}, {Name: "node_name", Value: "ü"}},
+			Samples: []promSample{{Value: 1.5e300, Timestamp: 0}},
+		},
+	}
+	var decoded testWriteRequest
+	if err := proto.Unmarshal(encodeWriteRequest(series), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Timeseries) != len(series) {
+		t.Fatalf("%d series, want %d", len(decoded.Timeseries), len(series))
+	}
+	for i, ts := range series {
+		got := decoded.Timeseries[i]
+		if len(got.Labels) != len(ts.Labels) || len(got.Samples) != len(ts.Samples) {
+			t.Fatalf("series %d decoded as %s", i, got)
+		}
+		for j, l := range ts.Labels {
+			if got.Labels[j].Name != l.Name || got.Labels[j].Value != l.Value {
+				t.Errorf("series %d label %d is %s, want %v", i, j, got.Labels[j], l)
+			}
+		}
+		for j, s := range ts.Samples {
+			if got.Samples[j].Value != s.Value || got.Samples[j].Timestamp != s.Timestamp {
+				t.Errorf("series %d sample %d is %s, want %v", i, j, got.Samples[j], s)
+			}
+		}
+	}
+}
+
+func TestRemoteWriteOutput(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		batches  []int
+		statuses []int
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for header, want := range map[string]string{
+			"Content-Encoding":                  "snappy",
+			"Content-Type":                      "application/x-protobuf",
+			"X-Prometheus-Remote-Write-Version": "0.1.0",
+			"X-Scope-OrgID":                     "tenant",
+		} {
+			if got := r.Header.Get(header); got != want {
+				t.Errorf("%s header %q, want %q", header, got, want)
+			}
+		}
+		compressed, _ := ioutil.ReadAll(r.Body)
+		body, err := snappy.Decode(nil, compressed)
+		if err != nil {
+			t.Errorf("decode snappy: %s", err)
+		}
+		var req testWriteRequest
+		if err := proto.Unmarshal(body, &req); err != nil {
+			t.Errorf("decode write request: %s", err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		batches = append(batches, len(req.Timeseries))
+		status := http.StatusNoContent
+		if len(statuses) > 0 {
+			status, statuses = statuses[0], statuses[1:]
+		}
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	newOutput := func(exporter *ExporterCollector) *RemoteWriteOutput {
+		return NewRemoteWriteOutput(server.URL, nil, map[string]string{"X-Scope-OrgID": "tenant"},
+			nil, exporter, 2, 10, 20*time.Millisecond, 2, time.Millisecond, time.Second)
+	}
+	ts := promTimeSeries{
+		Labels:  []promLabel{{Name: "__name__", Value: "redis_server_up"}},
+		Samples: []promSample{{Value: 1, Timestamp: 1}},
+	}
+	reset := func(codes ...int) {
+		mu.Lock()
+		batches, statuses = nil, codes
+		mu.Unlock()
+	}
+	sent := func() []int {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]int(nil), batches...)
+	}
+
+	// A full batch is sent at once, the rest after the flush interval.
+	o := newOutput(NewExporterCollector())
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		o.Run(ctx, nil)
+		close(done)
+	}()
+	for i := 0; i < 3; i++ {
+		o.queue <- ts
+	}
+	deadline := time.Now().Add(time.Second)
+	for len(sent()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	<-done
+	if got := sent(); len(got) != 2 || got[0] != 2 || got[1] != 1 {
+		t.Errorf("batches %v, want [2 1]", got)
+	}
+
+	// Once stopped, the queued series are sent along with the partial batch.
+	reset()
+	o = newOutput(NewExporterCollector())
+	for i := 0; i < 3; i++ {
+		o.queue <- ts
+	}
+	stop := make(chan struct{})
+	close(stop)
+	o.Run(context.Background(), stop)
+	if got := sent(); len(got) != 2 || got[0] != 2 || got[1] != 1 {
+		t.Errorf("batches %v on stop, want [2 1]", got)
+	}
+
+	// Once cancelled, the partial batch is dropped along with the queue.
+	reset()
+	exporter := NewExporterCollector()
+	o = NewRemoteWriteOutput(server.URL, nil, nil, nil, exporter, 2, 10, time.Hour, 2, time.Millisecond, time.Second)
+	ctx, cancel = context.WithCancel(context.Background())
+	done = make(chan struct{})
+	go func() {
+		o.Run(ctx, nil)
+		close(done)
+	}()
+	o.queue <- ts
+	for len(o.queue) > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+	if got := sent(); len(got) != 0 {
+		t.Errorf("batches %v after cancel, want none", got)
+	}
+	if n := counterValue(exporter.OutputDropped.WithLabelValues(OutputRemoteWrite)); n != 1 {
+		t.Errorf("%v series dropped on cancel, want the partial batch of 1", n)
+	}
+
+	// Server errors are retried.
+	reset(http.StatusInternalServerError, http.StatusServiceUnavailable)
+	exporter = NewExporterCollector()
+	o = newOutput(exporter)
+	o.send(context.Background(), []promTimeSeries{ts})
+	if got := sent(); len(got) != 3 {
+		t.Errorf("%d attempts after server errors, want 3", len(got))
+	}
+	if n := counterValue(exporter.OutputDropped.WithLabelValues(OutputRemoteWrite)); n != 0 {
+		t.Errorf("%v series dropped after a successful retry", n)
+	}
+
+	// Client errors are not.
+	reset(http.StatusBadRequest)
+	o.send(context.Background(), []promTimeSeries{ts})
+	if got := sent(); len(got) != 1 {
+		t.Errorf("%d attempts after a client error, want 1", len(got))
+	}
+	if n := counterValue(exporter.OutputDropped.WithLabelValues(OutputRemoteWrite)); n != 1 {
+		t.Errorf("%v series dropped after a client error, want 1", n)
+	}
+}
+
+func counterValue(c prometheus.Counter) float64 {
+	var m dto.Metric
+	c.Write(&m)
+	return m.GetCounter().GetValue()
+}
@@ -0,0 +1,266 @@
+package metrics
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/cwr0401/redis_metrics/metrics/info"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ReplicationCollector derives the replication lag of every replica that is a
+// target from its last INFO and the last INFO of its master, when the master
+// is a target too. The series are labelled with the node_name of the replica
+// and the name and address of its master. Masters are matched to targets by
+// the addresses Endpoints resolved when the configuration was applied, and a
+// collection only updates the pairs of the target collected.
+type ReplicationCollector struct {
+	LagBytes     *prometheus.GaugeVec
+	LagInBacklog *prometheus.GaugeVec
+	LastFullSync *prometheus.GaugeVec
+
+	Endpoints func() *targetEndpoints
+
+	mu       sync.Mutex
+	last     map[string]*CollectionResult
+	masterOf map[string]string
+	replicas map[string]map[string]bool
+	fullSync map[string]time.Time
+	series   map[string]prometheus.Labels
+}
+
+// NewReplicationCollector returns a ReplicationCollector observing the
+// collections of rm. It must be called before the first rm.Apply.
+func NewReplicationCollector(rm *RedisMetrics) *ReplicationCollector {
+	labels := []string{"node_name", "node_address", "master_name", "master_address"}
+	var (
+		// master_repl_offset of the master minus the offset of the replica
+		replicationLagBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "redis",
+			Subsystem: "replication",
+			Name:      "replica_lag_bytes",
+			Help:      "Bytes of the master's replication stream the replica has not acknowledged.",
+		}, labels)
+
+		// lag within repl_backlog_histlen
+		replicationLagInBacklog = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "redis",
+			Subsystem: "replication",
+			Name:      "replica_lag_in_backlog",
+			Help:      "Value is 1 if the replica's lag fits in the master's replication backlog, so a reconnect can resync partially, 0 otherwise.",
+		}, labels)
+
+		// last full sync
+		replicationLastFullSync = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "redis",
+			Subsystem: "replication",
+			Name:      "replica_last_full_sync_timestamp_seconds",
+			Help:      "Unix time of the last full sync of the replica, seen in progress or from the sync_full count of its master.",
+		}, labels)
+	)
+	c := &ReplicationCollector{
+		LagBytes:     replicationLagBytes,
+		LagInBacklog: replicationLagInBacklog,
+		LastFullSync: replicationLastFullSync,
+		Endpoints:    rm.Endpoints,
+		last:         make(map[string]*CollectionResult),
+		masterOf:     make(map[string]string),
+		replicas:     make(map[string]map[string]bool),
+		fullSync:     make(map[string]time.Time),
+		series:       make(map[string]prometheus.Labels),
+	}
+	rm.AddObserver(c)
+	return c
+}
+
+func (m *ReplicationCollector) MustRegister(registry *prometheus.Registry) {
+	registry.MustRegister(m.LagBytes)
+	registry.MustRegister(m.LagInBacklog)
+	registry.MustRegister(m.LastFullSync)
+}
+
+func (m *ReplicationCollector) Collected(result *CollectionResult) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	previous := m.last[result.Name]
+	if result.Err != nil {
+		delete(m.last, result.Name)
+	} else {
+		m.last[result.Name] = result
+		if previous != nil {
+			m.detectFullSyncs(previous, result)
+		}
+	}
+	m.update(result.Name)
+}
+
+func (m *ReplicationCollector) Removed(nodeName, nodeAddress string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.last, nodeName)
+	delete(m.fullSync, nodeName)
+	m.update(nodeName)
+}
+
+// update links the target name to its master and sets the series of the
+// pairs it is part of, as a replica or as a master.
+func (m *ReplicationCollector) update(name string) {
+	if master, ok := m.masterOf[name]; ok {
+		delete(m.replicas[master], name)
+		if len(m.replicas[master]) == 0 {
+			delete(m.replicas, master)
+		}
+		delete(m.masterOf, name)
+	}
+	if result, ok := m.last[name]; ok {
+		if role, _ := result.Info.String("Replication", "role"); role == "slave" {
+			host, _ := result.Info.String("Replication", "master_host")
+			port, _ := result.Info.String("Replication", "master_port")
+			if master := m.Endpoints().name(net.JoinHostPort(host, port)); master != "" && master != name {
+				m.masterOf[name] = master
+				if m.replicas[master] == nil {
+					m.replicas[master] = make(map[string]bool)
+				}
+				m.replicas[master][name] = true
+			}
+		}
+	}
+
+	m.updatePair(name)
+	for replica := range m.replicas[name] {
+		m.updatePair(replica)
+	}
+}
+
+// masterEntry returns the slaveN field of master describing replica, nil
+// when the master does not list it under the replica's address.
+func (m *ReplicationCollector) masterEntry(master, replica *CollectionResult) *info.Replica {
+	endpoints := m.Endpoints()
+	for i, entry := range master.Info.Replicas {
+		if endpoints.same(net.JoinHostPort(entry.IP, entry.Port), replica.Addr) {
+			return &master.Info.Replicas[i]
+		}
+	}
+	return nil
+}
+
+// detectFullSyncs records the full syncs between two collections of a
+// master from the growth of its sync_full count, so that syncs finishing
+// between collections are not missed. They are attributed to the replicas
+// the master did not list online before or lists syncing now, or to all its
+// replicas when none did.
+func (m *ReplicationCollector) detectFullSyncs(previous, current *CollectionResult) {
+	if role, _ := current.Info.String("Replication", "role"); role != "master" {
+		return
+	}
+	before, _ := previous.Info.Section("Stats").Float("sync_full")
+	after, _ := current.Info.Section("Stats").Float("sync_full")
+	previousRunID, _ := previous.Info.String("Server", "run_id")
+	currentRunID, _ := current.Info.String("Server", "run_id")
+	// A restart resets sync_full.
+	if after <= before || previousRunID != currentRunID {
+		return
+	}
+	var synced, all []string
+	for name := range m.replicas[current.Name] {
+		replica, ok := m.last[name]
+		if !ok {
+			continue
+		}
+		all = append(all, name)
+		was, is := m.masterEntry(previous, replica), m.masterEntry(current, replica)
+		if was == nil || was.State != "online" || (is != nil && is.State != "online") {
+			synced = append(synced, name)
+		}
+	}
+	if len(synced) == 0 {
+		synced = all
+	}
+	for _, name := range synced {
+		m.fullSync[name] = current.Time
+	}
+}
+
+// updatePair sets the series of the replica target name and its master, or
+// deletes them when it is not a replica of a master target.
+func (m *ReplicationCollector) updatePair(name string) {
+	old, hasOld := m.series[name]
+	replica, master := m.last[name], m.last[m.masterOf[name]]
+	role := ""
+	if master != nil {
+		role, _ = master.Info.String("Replication", "role")
+	}
+	if replica == nil || role != "master" {
+		if hasOld {
+			m.deleteSeries(old)
+			delete(m.series, name)
+		}
+		return
+	}
+	labels := prometheus.Labels{
+		"node_name":      replica.Name,
+		"node_address":   replica.Addr,
+		"master_name":    master.Name,
+		"master_address": master.Addr,
+	}
+	if hasOld && !sameLabels(old, labels) {
+		m.deleteSeries(old)
+	}
+	m.series[name] = labels
+	entry := m.masterEntry(master, replica)
+
+	// The master's view of the replica's offset was taken at the same
+	// time as its own offset, prefer it to the replica's.
+	masterOffset, hasMasterOffset := master.Info.Section("Replication").Float("master_repl_offset")
+	replicaOffset, hasReplicaOffset := replica.Info.Section("Replication").Float("slave_repl_offset")
+	if entry != nil {
+		replicaOffset, hasReplicaOffset = float64(entry.Offset), true
+	}
+	if hasMasterOffset && hasReplicaOffset {
+		lag := masterOffset - replicaOffset
+		if lag < 0 {
+			lag = 0
+		}
+		m.LagBytes.With(labels).Set(lag)
+		inBacklog := 0.0
+		active, _ := master.Info.Section("Replication").Float("repl_backlog_active")
+		histlen, _ := master.Info.Section("Replication").Float("repl_backlog_histlen")
+		if active == 1 && lag <= histlen {
+			inBacklog = 1
+		}
+		m.LagInBacklog.With(labels).Set(inBacklog)
+	}
+
+	// A full sync is in progress while the replica reports
+	// master_sync_in_progress or the master waits for or sends the RDB.
+	if syncing, _ := replica.Info.Section("Replication").Float("master_sync_in_progress"); syncing == 1 &&
+		replica.Time.After(m.fullSync[name]) {
+		m.fullSync[name] = replica.Time
+	}
+	if entry != nil && (entry.State == "wait_bgsave" || entry.State == "send_bulk") &&
+		master.Time.After(m.fullSync[name]) {
+		m.fullSync[name] = master.Time
+	}
+	if t, ok := m.fullSync[name]; ok {
+		m.LastFullSync.With(labels).Set(float64(t.UnixNano()) / 1e9)
+	}
+}
+
+func (m *ReplicationCollector) deleteSeries(labels prometheus.Labels) {
+	m.LagBytes.Delete(labels)
+	m.LagInBacklog.Delete(labels)
+	m.LastFullSync.Delete(labels)
+}
+
+func sameLabels(a, b prometheus.Labels) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if b[k] != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,176 @@
+package metrics
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cwr0401/redis_metrics/metrics/info"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestReplicationCollector(t *testing.T) {
+	rm := NewRedisMetrics(info.NewRedisCollector(), NewExporterCollector(), time.Second)
+	c := NewReplicationCollector(rm)
+	rm.setEndpoints(newTargetEndpoints(map[string]string{
+		"master":  "127.0.0.1:6379",
+		"online":  "127.0.0.1:6380",
+		"syncing": "127.0.0.1:6381",
+	}))
+	now := time.Now()
+
+	master := &CollectionResult{
+		Name: "master",
+		Addr: "127.0.0.1:6379",
+		Time: now,
+		Info: info.ParseInfo("# Replication\r\nrole:master\r\nconnected_slaves:2\r\n" +
+			"slave0:ip=127.0.0.1,port=6380,state=online,offset=900,lag=0\r\n" +
+			"slave1:ip=127.0.0.1,port=6381,state=send_bulk,offset=0,lag=0\r\n" +
+			"master_repl_offset:1000\r\nrepl_backlog_active:1\r\nrepl_backlog_histlen:500\r\n"),
+	}
+	online := &CollectionResult{
+		Name: "online",
+		Addr: "127.0.0.1:6380",
+		Time: now,
+		Info: info.ParseInfo("# Replication\r\nrole:slave\r\nmaster_host:127.0.0.1\r\nmaster_port:6379\r\n" +
+			"master_sync_in_progress:0\r\nslave_repl_offset:950\r\n"),
+	}
+	syncing := &CollectionResult{
+		Name: "syncing",
+		Addr: "127.0.0.1:6381",
+		Time: now,
+		Info: info.ParseInfo("# Replication\r\nrole:slave\r\nmaster_host:127.0.0.1\r\nmaster_port:6379\r\n" +
+			"master_sync_in_progress:1\r\nslave_repl_offset:-1\r\n"),
+	}
+	for _, result := range []*CollectionResult{master, online, syncing} {
+		c.Collected(result)
+	}
+
+	labels := func(name, addr string) prometheus.Labels {
+		return prometheus.Labels{"node_name": name, "node_address": addr,
+			"master_name": "master", "master_address": "127.0.0.1:6379"}
+	}
+	// The master's offset for the replica wins over the replica's own.
+	if v := gaugeValue(c.LagBytes.With(labels("online", online.Addr))); v != 100 {
+		t.Errorf("online lag %v, want 100", v)
+	}
+	if v := gaugeValue(c.LagInBacklog.With(labels("online", online.Addr))); v != 1 {
+		t.Errorf("online in backlog %v, want 1", v)
+	}
+	if v := gaugeValue(c.LagInBacklog.With(labels("syncing", syncing.Addr))); v != 0 {
+		t.Errorf("syncing in backlog %v, want 0", v)
+	}
+	if n := seriesCount(c.LastFullSync); n != 1 {
+		t.Errorf("%d last full sync series, want only the syncing replica's", n)
+	}
+
+	c.Removed("master", master.Addr)
+	if n := seriesCount(c.LagBytes); n != 0 {
+		t.Errorf("%d lag series left without the master", n)
+	}
+}
+
+func TestReplicationFullSyncBetweenCollections(t *testing.T) {
+	rm := NewRedisMetrics(info.NewRedisCollector(), NewExporterCollector(), time.Second)
+	c := NewReplicationCollector(rm)
+	rm.setEndpoints(newTargetEndpoints(map[string]string{
+		"master": "10.0.0.1:6379",
+		"a":      "10.0.0.2:6379",
+		"b":      "10.0.0.3:6379",
+	}))
+	now := time.Now()
+	master := func(runID string, syncFull int, replicas ...string) *CollectionResult {
+		raw := fmt.Sprintf("# Server\r\nrun_id:%s\r\n# Stats\r\nsync_full:%d\r\n"+
+			"# Replication\r\nrole:master\r\nmaster_repl_offset:100\r\n", runID, syncFull)
+		for i, ip := range replicas {
+			raw += fmt.Sprintf("slave%d:ip=%s,port=6379,state=online,offset=100,lag=0\r\n", i, ip)
+		}
+		now = now.Add(time.Second)
+		return &CollectionResult{Name: "master", Addr: "10.0.0.1:6379", Time: now, Info: info.ParseInfo(raw)}
+	}
+	replica := func(name, addr string) *CollectionResult {
+		return &CollectionResult{Name: name, Addr: addr, Time: now, Info: info.ParseInfo(
+			"# Replication\r\nrole:slave\r\nmaster_host:10.0.0.1\r\nmaster_port:6379\r\n" +
+				"master_sync_in_progress:0\r\nslave_repl_offset:100\r\n")}
+	}
+	labels := func(name, addr string) prometheus.Labels {
+		return prometheus.Labels{"node_name": name, "node_address": addr,
+			"master_name": "master", "master_address": "10.0.0.1:6379"}
+	}
+
+	c.Collected(master("r1", 1, "10.0.0.2"))
+	c.Collected(replica("a", "10.0.0.2:6379"))
+	c.Collected(replica("b", "10.0.0.3:6379"))
+	if n := seriesCount(c.LastFullSync); n != 0 {
+		t.Fatalf("%d last full sync series without a sync", n)
+	}
+
+	// b synced between two collections, only the master's count shows it.
+	c.Collected(master("r1", 2, "10.0.0.2", "10.0.0.3"))
+	if n := seriesCount(c.LastFullSync); n != 1 {
+		t.Errorf("%d last full sync series, want b's", n)
+	}
+	if v := gaugeValue(c.LastFullSync.With(labels("b", "10.0.0.3:6379"))); v != float64(now.UnixNano())/1e9 {
+		t.Errorf("last full sync of b at %v, want the master's collection at %v", v, float64(now.UnixNano())/1e9)
+	}
+	if !c.LastFullSync.Delete(labels("b", "10.0.0.3:6379")) {
+		t.Error("no full sync of b, the replica the master did not list before")
+	}
+
+	// Either could have synced, the master listed both online.
+	c.Collected(master("r1", 3, "10.0.0.2", "10.0.0.3"))
+	if n := seriesCount(c.LastFullSync); n != 2 {
+		t.Errorf("%d last full sync series, want both replicas'", n)
+	}
+
+	// A restart resets the count.
+	c.LastFullSync.Reset()
+	c.Collected(master("r2", 4, "10.0.0.2", "10.0.0.3"))
+	if n := seriesCount(c.LastFullSync); n != 2 {
+		t.Errorf("%d last full sync series, want the previous syncs only", n)
+	}
+	if c.fullSync["a"] != c.fullSync["b"] || c.fullSync["a"].Equal(now) {
+		t.Error("full sync recorded across a restart")
+	}
+}
+
+func gaugeValue(g prometheus.Gauge) float64 {
+	var m dto.Metric
+	g.Write(&m)
+	return m.GetGauge().GetValue()
+}
+
+func seriesCount(c prometheus.Collector) int {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		c.Collect(ch)
+		close(ch)
+	}()
+	n := 0
+	for range ch {
+		n++
+	}
+	return n
+}
+
+func TestMasterName(t *testing.T) {
+	rm := NewRedisMetrics(info.NewRedisCollector(), NewExporterCollector(), time.Second)
+	if name := rm.masterName("127.0.0.1", "6379"); name != "" {
+		t.Errorf("master %q before Apply", name)
+	}
+	rm.setEndpoints(newTargetEndpoints(map[string]string{
+		"local":  "localhost:6379",
+		"remote": "10.0.0.1:6379",
+	}))
+	for _, test := range []struct{ host, port, want string }{
+		{"127.0.0.1", "6379", "local"},
+		{"localhost", "6379", "local"},
+		{"10.0.0.1", "6379", "remote"},
+		{"10.0.0.1", "6380", ""},
+	} {
+		if name := rm.masterName(test.host, test.port); name != test.want {
+			t.Errorf("master of %s:%s is %q, want %q", test.host, test.port, name, test.want)
+		}
+	}
+}
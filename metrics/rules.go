@@ -0,0 +1,176 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v2"
+)
+
+// RulesOptions are the thresholds of the generated alerting rules. A zero
+// HitRatio leaves out the hit ratio alert.
+type RulesOptions struct {
+	Window          time.Duration
+	For             time.Duration
+	MemoryRatio     float64
+	ReplicationLag  float64
+	EvictionRate    float64
+	HitRatio        float64
+	MasterChangeFor time.Duration
+}
+
+// ruleGroups is a Prometheus rules file.
+type ruleGroups struct {
+	Groups []ruleGroup `yaml:"groups"`
+}
+
+type ruleGroup struct {
+	Name  string `yaml:"name"`
+	Rules []rule `yaml:"rules"`
+}
+
+type rule struct {
+	Record      string            `yaml:"record,omitempty"`
+	Alert       string            `yaml:"alert,omitempty"`
+	Expr        string            `yaml:"expr"`
+	For         string            `yaml:"for,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+// generateRules returns the recording and alerting rules for the metrics of
+// the exporter, failing when a rule uses a metric or label the collectors
+// no longer define.
+func generateRules(opts RulesOptions) (*ruleGroups, error) {
+	definitions, err := metricDefinitions()
+	if err != nil {
+		return nil, err
+	}
+	window := model.Duration(opts.Window).String()
+	forDuration := ""
+	if opts.For > 0 {
+		forDuration = model.Duration(opts.For).String()
+	}
+
+	hitRatio := "redis:keyspace_hit_ratio:rate" + window
+	memoryRatio := "redis:memory_used:ratio"
+	opsRate := "redis:commands_processed:rate" + window
+	replicationLag := "redis:replica_lag_bytes:max" + window
+	evictionRate := "redis:evicted_keys:rate" + window
+
+	recording := []rule{
+		{
+			Record: hitRatio,
+			Expr: fmt.Sprintf("rate(redis_stats_keyspace_hits[%s])\n"+
+				"  / (rate(redis_stats_keyspace_hits[%[1]s]) + rate(redis_stats_keyspace_misses[%[1]s]))", window),
+		},
+		{
+			Record: memoryRatio,
+			Expr:   "redis_memory_used_memory / (redis_memory_maxmemory > 0)",
+		},
+		{
+			Record: opsRate,
+			Expr:   fmt.Sprintf("rate(redis_stats_total_commands_processed[%s])", window),
+		},
+		{
+			Record: replicationLag,
+			Expr:   fmt.Sprintf("max_over_time(redis_replication_replica_lag_bytes[%s])", window),
+		},
+		{
+			Record: evictionRate,
+			Expr:   fmt.Sprintf("rate(redis_stats_evicted_keys[%s])", window),
+		},
+	}
+
+	alert := func(name, expr, severity, summary string) rule {
+		return rule{
+			Alert:  name,
+			Expr:   expr,
+			For:    forDuration,
+			Labels: map[string]string{"severity": severity},
+			Annotations: map[string]string{
+				"summary": summary,
+			},
+		}
+	}
+	alerting := []rule{
+		alert("RedisDown", "redis_server_up == 0", "critical",
+			"Redis {{ $labels.node_name }} ({{ $labels.node_address }}) is down"),
+		alert("RedisMemoryHigh", memoryRatio+" > "+formatThreshold(opts.MemoryRatio), "warning",
+			"Redis {{ $labels.node_name }} uses {{ $value | humanizePercentage }} of maxmemory"),
+		alert("RedisReplicaLinkDown", "redis_replication_master_link_status == 1", "critical",
+			"The link of Redis replica {{ $labels.node_name }} to its master is down"),
+		alert("RedisReplicationLagHigh", replicationLag+" > "+formatThreshold(opts.ReplicationLag), "warning",
+			"Redis replica {{ $labels.node_name }} lags {{ $value | humanize1024 }}B behind {{ $labels.master_name }}"),
+		alert("RedisRdbBgsaveFailing", "redis_persistence_rdb_last_bgsave_status == 0", "critical",
+			"The last RDB save of Redis {{ $labels.node_name }} failed"),
+		alert("RedisEvictions", evictionRate+" > "+formatThreshold(opts.EvictionRate), "warning",
+			"Redis {{ $labels.node_name }} evicts {{ $value | humanize }} keys/s"),
+	}
+	if opts.HitRatio > 0 {
+		alerting = append(alerting, alert("RedisHitRatioLow", hitRatio+" < "+formatThreshold(opts.HitRatio),
+			"info", "Redis {{ $labels.node_name }} hit ratio is {{ $value | humanizePercentage }}"))
+	}
+	masterChanged := alert("RedisMasterChanged",
+		fmt.Sprintf("increase(redis_replication_master_changes_total[%s]) > 0",
+			model.Duration(opts.MasterChangeFor).String()),
+		"warning", "Redis replica {{ $labels.node_name }} changed its master")
+	masterChanged.For = ""
+	alerting = append(alerting, masterChanged)
+
+	recorded := make(map[string]bool)
+	for _, r := range recording {
+		if err := checkExpr(definitions, recorded, r.Expr, "node_name", "node_address"); err != nil {
+			return nil, fmt.Errorf("recording rule %s: %s", r.Record, err)
+		}
+		recorded[r.Record] = true
+	}
+	for _, r := range alerting {
+		if err := checkExpr(definitions, recorded, r.Expr, "node_name", "node_address"); err != nil {
+			return nil, fmt.Errorf("alerting rule %s: %s", r.Alert, err)
+		}
+	}
+	return &ruleGroups{Groups: []ruleGroup{
+		{Name: "redis-metrics.rules", Rules: recording},
+		{Name: "redis-metrics.alerts", Rules: alerting},
+	}}, nil
+}
+
+// formatThreshold formats v without an exponent, as people write PromQL.
+func formatThreshold(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// GenerateRulesAction prints the Prometheus recording and alerting rules for
+// the metrics of the exporter.
+func GenerateRulesAction(c *cli.Context) error {
+	opts := RulesOptions{
+		Window:          c.Duration("window"),
+		For:             c.Duration("for"),
+		MemoryRatio:     c.Float64("memory-ratio"),
+		ReplicationLag:  c.Float64("replication-lag-bytes"),
+		EvictionRate:    c.Float64("eviction-rate"),
+		HitRatio:        c.Float64("hit-ratio"),
+		MasterChangeFor: c.Duration("master-change-window"),
+	}
+	if opts.Window <= 0 || opts.MasterChangeFor <= 0 || opts.For < 0 {
+		return errors.New("window and master-change-window must be positive, for must not be negative")
+	}
+	groups, err := generateRules(opts)
+	if err != nil {
+		return err
+	}
+	return writeOutput(c.String("output"), func(w io.Writer) error {
+		b, err := yaml.Marshal(groups)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(b)
+		return err
+	})
+}
@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGenerateRules(t *testing.T) {
+	groups, err := generateRules(RulesOptions{
+		Window:          time.Minute,
+		For:             2 * time.Minute,
+		MemoryRatio:     0.8,
+		ReplicationLag:  1 << 20,
+		HitRatio:        0.5,
+		MasterChangeFor: 10 * time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules := make(map[string]rule)
+	for _, group := range groups.Groups {
+		for _, r := range group.Rules {
+			rules[r.Record+r.Alert] = r
+		}
+	}
+	if r := rules["RedisReplicationLagHigh"]; r.Expr != "redis:replica_lag_bytes:max1m > 1048576" || r.For != "2m" {
+		t.Errorf("RedisReplicationLagHigh %+v", r)
+	}
+	if _, ok := rules["RedisHitRatioLow"]; !ok {
+		t.Error("no RedisHitRatioLow with a hit ratio")
+	}
+}
+
+func TestCheckExpr(t *testing.T) {
+	definitions, err := metricDefinitions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := definitions["redis_replication_master_changes_total"]; d.Type != MetricTypeCounter ||
+		strings.Join(d.Labels, ",") != "node_name,node_address" {
+		t.Errorf("master changes definition %+v", d)
+	}
+	for _, expr := range []string{
+		"redis_memory_no_such_metric > 0",
+		"redis:unknown:ratio > 0",
+		"up == 0",
+	} {
+		if err := checkExpr(definitions, nil, expr, "node_name"); err == nil {
+			t.Errorf("%s passed", expr)
+		}
+	}
+	if err := checkExpr(definitions, nil, "redis_server_up == 0", "master_name"); err == nil {
+		t.Error("redis_server_up passed with master_name")
+	}
+}
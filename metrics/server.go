@@ -3,11 +3,15 @@ package metrics
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
-	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/cwr0401/redis_metrics/config"
@@ -23,8 +27,6 @@ const (
 	MaxCollectorInterval int = 600
 )
 
-var reloadChan = make(chan struct{}, 1)
-
 func init() {
 	// Log as JSON instead of the default ASCII formatter.
 	log.SetFormatter(&log.TextFormatter{FullTimestamp: true})
@@ -45,33 +47,149 @@ func healthCheck(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("OK"))
 }
 
-func reload(w http.ResponseWriter, r *http.Request) {
+// reload requests a configuration reload. Unless the route is protected by
+// authentication, only requests from a loopback address are accepted.
+func reload(exporter *ExporterCollector, loopbackOnly bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" && r.Method != "PUT" {
+			w.WriteHeader(405)
+			w.Write([]byte("Only POST or PUT requests allowed"))
+			return
+		}
 
-	if r.Method != "POST" && r.Method != "PUT" {
-		w.WriteHeader(405)
-		w.Write([]byte("Only POST or PUT requests allowed"))
-		return
+		if !loopbackOnly || isLoopback(r.RemoteAddr) {
+			triggerReload(ReloadTriggerHTTP, exporter)
+			w.Write([]byte("OK"))
+		} else {
+			w.WriteHeader(405)
+			w.Write([]byte("Error"))
+		}
 	}
+}
 
-	if strings.Contains(r.RemoteAddr, "127.0.0.1:") {
-		go func() {
-			reloadChan <- struct{}{}
-		}()
-		w.Write([]byte("OK"))
-	} else {
-		w.WriteHeader(405)
-		w.Write([]byte("Error"))
+// isLoopback reports whether the host part of addr is a loopback IP address,
+// either IPv4 or IPv6.
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
 	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
 }
 
-func HttpServer(addr string, handler http.Handler) {
-	http.HandleFunc("/ping", ping)
-	http.HandleFunc("/health", healthCheck)
-	http.HandleFunc("/-/reload", reload)
-	http.Handle("/metrics", handler)
-	err := http.ListenAndServe(addr, nil)
-	if err != nil {
-		log.Panic(err)
+func NewHttpServer(addr string, handler http.Handler, exporter *ExporterCollector,
+	statuses *TargetStatuses, alerts *AlertEvaluator, webConfig *config.WebConfig) (*http.Server, error) {
+	mux := http.NewServeMux()
+	auth := newAuthenticator(webConfig)
+	handle := func(path string, h http.Handler) {
+		mux.Handle(path, auth.wrap(path, h))
+	}
+	handle("/ping", http.HandlerFunc(ping))
+	handle("/health", http.HandlerFunc(healthCheck))
+	handle("/-/reload", reload(exporter, !webConfig.RequireAuth("/-/reload")))
+	handle("/metrics", handler)
+	handle("/api/v1/targets", targetsAPI(statuses))
+	handle("/api/v1/targets/", targetsAPI(statuses))
+	handle("/api/v1/topology", topologyAPI(statuses))
+	handle("/api/v1/alerts", alertsAPI(alerts))
+	handle("/", dashboard(statuses))
+
+	server := &http.Server{
+		Addr:    addr,
+		Handler: mux,
+	}
+	if webConfig != nil && webConfig.TLSServerConfig != nil {
+		tlsConfig, err := webConfig.TLSServerConfig.TLSConfig()
+		if err != nil {
+			return nil, err
+		}
+		server.TLSConfig = tlsConfig
+	}
+	return server, nil
+}
+
+// shutdown drains the HTTP server, then stops every collector and closes the
+// Redis clients, then lets the outputs send what they have queued, giving up
+// once timeout has elapsed. The collectors are stopped even when the HTTP
+// server could not drain in time, with a timeout of their own, and the first
+// error is returned.
+func shutdown(server *http.Server, rm *RedisMetrics, outputs *outputGroup, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	log.Info("Shutdown Http server")
+	var firstErr error
+	if err := server.Shutdown(ctx); err != nil {
+		log.Errorf("Shutdown Http server error: %s", err)
+		server.Close()
+		firstErr = err
+		ctx, cancel = context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+	}
+
+	log.Info("Stop Collector")
+	stopped := make(chan struct{})
+	go func() {
+		rm.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		if firstErr == nil {
+			firstErr = errors.New("shutdown timeout exceeded while waiting for collectors")
+		}
+	}
+
+	log.Info("Stop outputs")
+	if err := outputs.Stop(ctx); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	if firstErr == nil {
+		log.Info("Shutdown completed")
+	}
+	return firstErr
+}
+
+// outputGroup runs the outputs, which send what they still have queued when
+// the group is stopped.
+type outputGroup struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	stop   chan struct{}
+	wg     sync.WaitGroup
+}
+
+func newOutputGroup() *outputGroup {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &outputGroup{ctx: ctx, cancel: cancel, stop: make(chan struct{})}
+}
+
+// Go runs an output until the group is stopped.
+func (g *outputGroup) Go(run func(ctx context.Context, stop <-chan struct{})) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		run(g.ctx, g.stop)
+	}()
+}
+
+// Stop lets the outputs send what they have queued until ctx is done, then
+// cancels what they are still sending.
+func (g *outputGroup) Stop(ctx context.Context) error {
+	defer g.cancel()
+	close(g.stop)
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return errors.New("shutdown timeout exceeded while waiting for outputs")
 	}
 }
 
@@ -103,22 +221,50 @@ func loadConfigFile(path string) (*config.RedisConfig, error) {
 	return redisConfig, nil
 }
 
-func reloadConfig(configFile string, redisConfig *config.RedisConfig) *config.RedisConfig {
-	for {
-		<-reloadChan
-		log.Info("Reload configuration file")
-		newRedisConfig, err := loadConfigFile(configFile)
+func loadWebConfigFile(path string) (*config.WebConfig, error) {
+	if path == "" {
+		return nil, nil
+	}
+	webConfigFilePath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("Loading web configuration file %s", webConfigFilePath)
+	webConfigContent, err := readConfigFile(webConfigFilePath)
+	if err != nil {
+		return nil, err
+	}
+	return config.ParseWebConfig(webConfigContent)
+}
+
+// newSinks returns the Graphite, StatsD and InfluxDB sinks configured by the
+// command line flags, keyed by output name.
+func newSinks(c *cli.Context) (map[string]Sink, error) {
+	sinks := make(map[string]Sink)
+	timeout := c.Duration("sink-timeout")
+	if address := c.String("graphite-address"); address != "" {
+		sink, err := NewGraphiteSink(address, c.String("graphite-template"), c.String("graphite-label-mode"), timeout)
 		if err != nil {
-			log.Errorf("Reload configuration file error: %s", err)
-			continue
+			return nil, fmt.Errorf("graphite: %s", err)
 		}
-		if newRedisConfig.Equal(*redisConfig) {
-			log.Info("config file no changes")
-			continue
-		} else {
-			return newRedisConfig
+		sinks[OutputGraphite] = sink
+	}
+	if address := c.String("statsd-address"); address != "" {
+		sink, err := NewStatsDSink(address, c.String("statsd-template"), c.String("statsd-label-mode"), timeout)
+		if err != nil {
+			return nil, fmt.Errorf("statsd: %s", err)
+		}
+		sinks[OutputStatsD] = sink
+	}
+	if url := c.String("influxdb-url"); url != "" {
+		sink, err := NewInfluxDBSink(url, c.String("influxdb-token"), c.String("influxdb-template"),
+			c.String("influxdb-label-mode"), timeout)
+		if err != nil {
+			return nil, fmt.Errorf("influxdb: %s", err)
 		}
+		sinks[OutputInfluxDB] = sink
 	}
+	return sinks, nil
 }
 
 func RedisMetricsAction(c *cli.Context) error {
@@ -145,6 +291,11 @@ func RedisMetricsAction(c *cli.Context) error {
 		return err
 	}
 
+	webConfig, err := loadWebConfigFile(c.String("web-config"))
+	if err != nil {
+		return err
+	}
+
 	var (
 		registry                        = prometheus.NewRegistry()
 		gather      prometheus.Gatherer = registry
@@ -154,33 +305,157 @@ func RedisMetricsAction(c *cli.Context) error {
 	registry.MustRegister(prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}))
 	registry.MustRegister(prometheus.NewGoCollector())
 
-	go HttpServer(addr, Handler)
+	exporter := NewExporterCollector()
+	exporter.MustRegister(registry)
+	exporter.ConfigLastReload.SetToCurrentTime()
+	exporter.ConfigLastReloadStatus.Set(1)
 
-	for {
-		ctx, cancel := context.WithCancel(context.Background())
+	rmc := info.NewRedisCollector()
+	rmc.MustRegister(registry)
 
-		// register
-		rmc := info.NewRedisCollector()
-		rmc.MustRegister(registry)
+	rm := NewRedisMetrics(rmc, exporter, collectorIntervalDuration)
+	statuses := NewTargetStatuses(rm)
+	replication := NewReplicationCollector(rm)
+	replication.MustRegister(registry)
+	events := NewEventDetector(rm)
+	events.MustRegister(registry)
+	if window := c.Duration("forecast-window"); window > 0 {
+		forecast := NewForecastCollector(rm, window, c.Duration("forecast-horizon"))
+		forecast.MustRegister(registry)
+	}
+	alerts := NewAlertEvaluator(rm, exporter)
+	alerts.Apply(redisConfig.Alerting)
 
-		rm := RedisMetrics{
-			Collector: rmc,
-			Duration:  collectorIntervalDuration,
-			Config:    redisConfig,
+	server, err := NewHttpServer(addr, Handler, exporter, statuses, alerts, webConfig)
+	if err != nil {
+		return err
+	}
+	serverErr := make(chan error, 1)
+	go func() {
+		var err error
+		if server.TLSConfig != nil {
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
 		}
+		if err != nil && err != http.ErrServerClosed {
+			serverErr <- err
+		}
+	}()
 
-		stopFlag := make(chan struct{}, 1)
+	go watchSignal(exporter)
+	if watchInterval := c.Duration("config-watch-interval"); watchInterval > 0 {
+		go watchConfigFile(configFile, watchInterval, exporter)
+	}
+	reloadDebounce := c.Duration("config-reload-debounce")
 
-		log.Debug("Start Collector")
-		go rm.Run(ctx, stopFlag)
+	outputs := newOutputGroup()
+	defer outputs.cancel()
+	if dir := c.String("record-dir"); dir != "" {
+		recorder := NewRecorder(dir, c.Int("record-max-files"))
+		rm.AddObserver(recorder)
+		outputs.Go(recorder.Run)
+	}
+	if url := c.String("push-gateway-url"); url != "" {
+		pusher := NewPushgatewayOutput(url, c.String("push-job"), registry, exporter,
+			c.Int("push-retries"), c.Duration("push-retry-backoff"), c.Duration("push-timeout"))
+		rm.AddObserver(pusher)
+		outputs.Go(pusher.Run)
+	}
+	if url := c.String("remote-write-url"); url != "" {
+		labels, err := externalLabels(c.StringSlice("remote-write-external-label"))
+		if err != nil {
+			return fmt.Errorf("remote-write-external-label: %s", err)
+		}
+		headers, err := parseKeyValues(c.StringSlice("remote-write-header"))
+		if err != nil {
+			return fmt.Errorf("remote-write-header: %s", err)
+		}
+		if c.Int("remote-write-batch-size") < 1 || c.Duration("remote-write-flush-interval") <= 0 {
+			return errors.New("remote-write-batch-size and remote-write-flush-interval must be positive")
+		}
+		writer := NewRemoteWriteOutput(url, labels, headers, registry, exporter,
+			c.Int("remote-write-batch-size"), c.Int("remote-write-queue-capacity"),
+			c.Duration("remote-write-flush-interval"), c.Int("remote-write-retries"),
+			c.Duration("remote-write-retry-backoff"), c.Duration("remote-write-timeout"))
+		rm.AddObserver(writer)
+		outputs.Go(writer.Run)
+	}
+	// The sinks and OTLP only get the Redis series, not the exporter's own.
+	var redisRegistry *prometheus.Registry
+	redisGatherer := func() prometheus.Gatherer {
+		if redisRegistry == nil {
+			redisRegistry = prometheus.NewRegistry()
+			rmc.MustRegister(redisRegistry)
+		}
+		return redisRegistry
+	}
+	sinks, err := newSinks(c)
+	if err != nil {
+		return err
+	}
+	for name, sink := range sinks {
+		log.Infof("Sending metrics to %s", name)
+		output := NewSinkOutput(name, sink, redisGatherer(), exporter)
+		rm.AddObserver(output)
+		outputs.Go(output.Run)
+	}
+	if url := c.String("otlp-url"); url != "" {
+		headers, err := parseKeyValues(c.StringSlice("otlp-header"))
+		if err != nil {
+			return fmt.Errorf("otlp-header: %s", err)
+		}
+		otlp := NewOTLPOutput(url, headers, c.String("otlp-service-name"), c.Bool("otlp-semconv"),
+			redisGatherer(), exporter, c.Int("otlp-retries"), c.Duration("otlp-retry-backoff"),
+			c.Duration("otlp-timeout"))
+		rm.AddObserver(otlp)
+		outputs.Go(otlp.Run)
+	}
+	if path := c.String("event-log"); path != "" {
+		w := os.Stdout
+		if path != "-" {
+			f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+			if err != nil {
+				return fmt.Errorf("event-log: %s", err)
+			}
+			defer f.Close()
+			w = f
+		}
+		events.AddOutput(NewEventLog(w))
+	}
+	if url := c.String("event-webhook-url"); url != "" {
+		webhook := NewEventWebhook(url, exporter, c.Int("event-webhook-retries"),
+			c.Duration("event-webhook-retry-backoff"), c.Duration("event-webhook-timeout"))
+		events.AddOutput(webhook)
+		outputs.Go(webhook.Run)
+	}
 
-		// wait reload event
-		redisConfig = reloadConfig(configFile, redisConfig)
-		cancel()
-		log.Debug("Stop Collector")
-		<-stopFlag
-		if !rmc.Unregister(registry) {
-			return errors.New("unregister error")
+	outputs.Go(alerts.Run)
+
+	log.Debug("Start Collector")
+	rm.Apply(redisConfig)
+
+	go func() {
+		for {
+			// wait reload event
+			redisConfig = reloadConfig(configFile, redisConfig, reloadDebounce, exporter)
+			log.Debug("Apply configuration changes")
+			alerts.Apply(redisConfig.Alerting)
+			rm.Apply(redisConfig)
 		}
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, os.Interrupt)
+	select {
+	case sig := <-stop:
+		log.Infof("Received signal %s, shutting down", sig)
+	case err := <-serverErr:
+		log.Errorf("Http server error: %s", err)
+		if shutdownErr := shutdown(server, rm, outputs, c.Duration("shutdown-timeout")); shutdownErr != nil {
+			log.Error(shutdownErr)
+		}
+		return err
 	}
+	return shutdown(server, rm, outputs, c.Duration("shutdown-timeout"))
 }
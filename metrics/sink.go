@@ -0,0 +1,205 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	SinkLabelsAsTags = "tags"
+	SinkLabelsAsPath = "path"
+)
+
+// SinkPoint is a single Redis metric value as handed to a Sink. Section is
+// the INFO section (the Prometheus subsystem) and Name the metric name
+// within it, so redis_memory_used_memory has Section "memory" and Name
+// "used_memory". Labels holds the labels other than node_name and
+// node_address.
+type SinkPoint struct {
+	NodeName    string
+	NodeAddress string
+	Section     string
+	Name        string
+	Labels      []*dto.LabelPair
+	Value       float64
+	Time        time.Time
+}
+
+// Sink writes metric points to a non-Prometheus backend.
+type Sink interface {
+	Write(points []SinkPoint) error
+}
+
+// SinkOutput feeds a Sink with the series of a target after each of its
+// collections. Gatherer should only expose the series of info.RedisCollector.
+type SinkOutput struct {
+	Name     string
+	Sink     Sink
+	Gatherer prometheus.Gatherer
+	Exporter *ExporterCollector
+
+	queue chan *CollectionResult
+}
+
+func NewSinkOutput(name string, sink Sink, gatherer prometheus.Gatherer, exporter *ExporterCollector) *SinkOutput {
+	return &SinkOutput{
+		Name:     name,
+		Sink:     sink,
+		Gatherer: gatherer,
+		Exporter: exporter,
+		queue:    make(chan *CollectionResult, 1024),
+	}
+}
+
+func (s *SinkOutput) Collected(result *CollectionResult) {
+	select {
+	case s.queue <- result:
+	default:
+		s.Exporter.OutputDropped.WithLabelValues(s.Name).Inc()
+		log.WithFields(log.Fields{
+			"node": result.Name,
+			"addr": result.Addr,
+		}).Warnf("%s queue is full, dropping update", s.Name)
+	}
+}
+
+// Removed does nothing, these backends keep the series they have received.
+func (s *SinkOutput) Removed(nodeName, nodeAddress string) {}
+
+// Run writes the queued collections until stop is closed, then the
+// collections still queued. Once ctx is done the rest are dropped.
+func (s *SinkOutput) Run(ctx context.Context, stop <-chan struct{}) {
+	defer func() { dropQueued(s.Exporter, s.Name, len(s.queue)) }()
+	for ctx.Err() == nil {
+		var result *CollectionResult
+		select {
+		case <-ctx.Done():
+			return
+		case result = <-s.queue:
+		case <-stop:
+			select {
+			case result = <-s.queue:
+			default:
+				return
+			}
+		}
+		s.write(result)
+	}
+}
+
+func (s *SinkOutput) write(result *CollectionResult) {
+	mfs, err := nodeGatherer{s.Gatherer, result.Name, result.Addr}.Gather()
+	if err == nil {
+		err = s.Sink.Write(sinkPoints(mfs, result.Time))
+		s.Exporter.ObserveOutput(s.Name, err)
+	}
+	if err != nil {
+		s.Exporter.OutputDropped.WithLabelValues(s.Name).Inc()
+		log.WithFields(log.Fields{
+			"node": result.Name,
+			"addr": result.Addr,
+		}).Errorf("Write to %s failed: %s", s.Name, err)
+	}
+}
+
+func sinkPoints(mfs []*dto.MetricFamily, t time.Time) []SinkPoint {
+	var points []SinkPoint
+	for _, mf := range mfs {
+		parts := strings.SplitN(mf.GetName(), "_", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			point := SinkPoint{Section: parts[1], Name: parts[2], Time: t}
+			switch mf.GetType() {
+			case dto.MetricType_GAUGE:
+				point.Value = m.GetGauge().GetValue()
+			case dto.MetricType_COUNTER:
+				point.Value = m.GetCounter().GetValue()
+			case dto.MetricType_UNTYPED:
+				point.Value = m.GetUntyped().GetValue()
+			default:
+				continue
+			}
+			for _, l := range m.GetLabel() {
+				switch l.GetName() {
+				case "node_name":
+					point.NodeName = l.GetValue()
+				case "node_address":
+					point.NodeAddress = l.GetValue()
+				default:
+					point.Labels = append(point.Labels, l)
+				}
+			}
+			sort.Slice(point.Labels, func(i, j int) bool {
+				return point.Labels[i].GetName() < point.Labels[j].GetName()
+			})
+			points = append(points, point)
+		}
+	}
+	return points
+}
+
+// sinkTemplate renders metric names from a text/template using the fields
+// .NodeName, .NodeAddress, .Section and .Name of a SinkPoint. The values are
+// passed through escape first so that they cannot break the name up.
+type sinkTemplate struct {
+	tmpl   *template.Template
+	escape func(string) string
+}
+
+func newSinkTemplate(text string, escape func(string) string) (*sinkTemplate, error) {
+	tmpl, err := template.New("name").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	t := &sinkTemplate{tmpl: tmpl, escape: escape}
+	// Catch references to unknown fields now rather than on every write.
+	if _, err := t.render(SinkPoint{}); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *sinkTemplate) render(p SinkPoint) (string, error) {
+	var buf bytes.Buffer
+	err := t.tmpl.Execute(&buf, struct {
+		NodeName, NodeAddress, Section, Name string
+	}{t.escape(p.NodeName), t.escape(p.NodeAddress), t.escape(p.Section), t.escape(p.Name)})
+	if err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// pathName renders the name of p and, in SinkLabelsAsPath mode, appends the
+// label values to it as further segments.
+func (t *sinkTemplate) pathName(p SinkPoint, labelMode, separator string) (string, error) {
+	name, err := t.render(p)
+	if err != nil {
+		return "", err
+	}
+	if labelMode == SinkLabelsAsPath {
+		for _, l := range p.Labels {
+			name += separator + t.escape(l.GetValue())
+		}
+	}
+	return name, nil
+}
+
+func validSinkLabelMode(mode string) error {
+	switch mode {
+	case SinkLabelsAsTags, SinkLabelsAsPath:
+		return nil
+	}
+	return fmt.Errorf("label mode must be %q or %q", SinkLabelsAsTags, SinkLabelsAsPath)
+}
@@ -0,0 +1,205 @@
+package metrics
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	dto "github.com/prometheus/client_model/go"
+)
+
+var sinkTestTime = time.Unix(1600000000, 0)
+
+func sinkTestPoints(nodeName string) []SinkPoint {
+	return []SinkPoint{
+		{
+			NodeName: nodeName, NodeAddress: "10.0.0.1:6379",
+			Section: "memory", Name: "used_memory", Value: 1024, Time: sinkTestTime,
+		},
+		{
+			NodeName: nodeName, NodeAddress: "10.0.0.1:6379",
+			Section: "commandstats", Name: "calls", Value: 5.5, Time: sinkTestTime,
+			Labels: []*dto.LabelPair{{Name: proto.String("cmd"), Value: proto.String("config|get")}},
+		},
+	}
+}
+
+func TestGraphiteSink(t *testing.T) {
+	tests := []struct {
+		template, labelMode string
+		want                []string
+	}{
+		{DefaultGraphiteTemplate, SinkLabelsAsPath, []string{
+			"redis.a_b.memory.used_memory 1024 1600000000",
+			"redis.a_b.commandstats.calls.config|get 5.5 1600000000",
+		}},
+		{"{{.Section}}.{{.Name}}.{{.NodeName}}", SinkLabelsAsTags, []string{
+			"memory.used_memory.a_b 1024 1600000000",
+			"commandstats.calls.a_b;cmd=config|get 5.5 1600000000",
+		}},
+	}
+	for _, test := range tests {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		received := make(chan string, 1)
+		go func() {
+			conn, err := l.Accept()
+			if err != nil {
+				received <- err.Error()
+				return
+			}
+			b, _ := ioutil.ReadAll(conn)
+			conn.Close()
+			received <- string(b)
+		}()
+		sink, err := NewGraphiteSink(l.Addr().String(), test.template, test.labelMode, time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := sink.Write(sinkTestPoints("a.b")); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := <-received, strings.Join(test.want, "\n")+"\n"; got != want {
+			t.Errorf("%s %s: sent\n%s\nwant\n%s", test.template, test.labelMode, got, want)
+		}
+		l.Close()
+	}
+
+	if _, err := NewGraphiteSink("localhost:2003", "{{.Unknown}}", SinkLabelsAsPath, time.Second); err == nil {
+		t.Error("no error for a template with an unknown field")
+	}
+	if _, err := NewGraphiteSink("localhost:2003", DefaultGraphiteTemplate, "labels", time.Second); err == nil {
+		t.Error("no error for an unknown label mode")
+	}
+}
+
+func TestStatsDSink(t *testing.T) {
+	tests := []struct {
+		labelMode string
+		want      string
+	}{
+		{SinkLabelsAsPath, "redis.a_b.memory.used_memory:1024|g\nredis.a_b.commandstats.calls.config_get:5.5|g"},
+		{SinkLabelsAsTags, "redis.a_b.memory.used_memory:1024|g\nredis.a_b.commandstats.calls:5.5|g|#cmd:config_get"},
+	}
+	for _, test := range tests {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		sink, err := NewStatsDSink(conn.LocalAddr().String(), DefaultStatsDTemplate, test.labelMode, time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := sink.Write(sinkTestPoints("a:b")); err != nil {
+			t.Fatal(err)
+		}
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		buf := make([]byte, statsdMaxPacketSize)
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(buf[:n]); got != test.want {
+			t.Errorf("%s: sent\n%s\nwant\n%s", test.labelMode, got, test.want)
+		}
+		conn.Close()
+	}
+}
+
+func TestStatsDSinkPackets(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	sink, err := NewStatsDSink(conn.LocalAddr().String(), DefaultStatsDTemplate, SinkLabelsAsPath, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var points []SinkPoint
+	for i := 0; i < 100; i++ {
+		points = append(points, sinkTestPoints("a")[0])
+	}
+	if err := sink.Write(points); err != nil {
+		t.Fatal(err)
+	}
+	lines := 0
+	buf := make([]byte, 65536)
+	for lines < len(points) {
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n > statsdMaxPacketSize {
+			t.Errorf("packet of %d bytes", n)
+		}
+		lines += strings.Count(string(buf[:n]), "\n") + 1
+	}
+	if lines != len(points) {
+		t.Errorf("%d lines sent, want %d", lines, len(points))
+	}
+}
+
+func TestInfluxDBSink(t *testing.T) {
+	var (
+		body, auth string
+		status     = http.StatusNoContent
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		body, auth = string(b), r.Header.Get("Authorization")
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		labelMode string
+		want      string
+	}{
+		{SinkLabelsAsTags, `redis_memory,node_name=a\ b\,c\=d,node_address=10.0.0.1:6379 used_memory=1024 1600000000000000000
+redis_commandstats,node_name=a\ b\,c\=d,node_address=10.0.0.1:6379,cmd=config|get calls=5.5 1600000000000000000
+`},
+		{SinkLabelsAsPath, `redis_memory,node_name=a\ b\,c\=d,node_address=10.0.0.1:6379 used_memory=1024 1600000000000000000
+redis_commandstats_config|get,node_name=a\ b\,c\=d,node_address=10.0.0.1:6379 calls=5.5 1600000000000000000
+`},
+	}
+	for _, test := range tests {
+		sink, err := NewInfluxDBSink(server.URL, "secret", DefaultInfluxDBTemplate, test.labelMode, time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := sink.Write(sinkTestPoints("a b,c=d")); err != nil {
+			t.Fatal(err)
+		}
+		if body != test.want {
+			t.Errorf("%s: sent\n%s\nwant\n%s", test.labelMode, body, test.want)
+		}
+		if auth != "Token secret" {
+			t.Errorf("Authorization %q", auth)
+		}
+	}
+
+	// The fields of a section and target share a line.
+	sink, _ := NewInfluxDBSink(server.URL, "", DefaultInfluxDBTemplate, SinkLabelsAsTags, time.Second)
+	points := sinkTestPoints("a")
+	points[1].Section, points[1].Labels = "memory", nil
+	if err := sink.Write(points); err != nil {
+		t.Fatal(err)
+	}
+	if want := "redis_memory,node_name=a,node_address=10.0.0.1:6379 used_memory=1024,calls=5.5 1600000000000000000\n"; body != want {
+		t.Errorf("sent\n%s\nwant\n%s", body, want)
+	}
+
+	status = http.StatusBadRequest
+	if err := sink.Write(points); err == nil {
+		t.Error("no error for a rejected write")
+	}
+}
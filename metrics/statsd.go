@@ -0,0 +1,92 @@
+package metrics
+
+import (
+	"bytes"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	OutputStatsD = "statsd"
+
+	DefaultStatsDTemplate = "redis.{{.NodeName}}.{{.Section}}.{{.Name}}"
+
+	// statsdMaxPacketSize keeps datagrams below the usual Ethernet MTU.
+	statsdMaxPacketSize = 1432
+)
+
+var statsdEscaper = strings.NewReplacer(".", "_", " ", "_", ":", "_", "|", "_", "@", "_", "#", "_", ",", "_")
+
+// StatsDSink sends points to a StatsD server over UDP. Every value is sent as
+// a gauge since the collected counters are already cumulative. Labels are
+// appended to the path or sent as DogStatsD tags.
+type StatsDSink struct {
+	Address   string
+	LabelMode string
+	Timeout   time.Duration
+
+	template *sinkTemplate
+}
+
+func NewStatsDSink(address, nameTemplate, labelMode string, timeout time.Duration) (*StatsDSink, error) {
+	if err := validSinkLabelMode(labelMode); err != nil {
+		return nil, err
+	}
+	tmpl, err := newSinkTemplate(nameTemplate, statsdEscaper.Replace)
+	if err != nil {
+		return nil, err
+	}
+	return &StatsDSink{
+		Address:   address,
+		LabelMode: labelMode,
+		Timeout:   timeout,
+		template:  tmpl,
+	}, nil
+}
+
+func (s *StatsDSink) Write(points []SinkPoint) error {
+	conn, err := net.DialTimeout("udp", s.Address, s.Timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var packet bytes.Buffer
+	flush := func() error {
+		if packet.Len() == 0 {
+			return nil
+		}
+		if err := conn.SetWriteDeadline(time.Now().Add(s.Timeout)); err != nil {
+			return err
+		}
+		_, err := conn.Write(packet.Bytes())
+		packet.Reset()
+		return err
+	}
+	for _, p := range points {
+		name, err := s.template.pathName(p, s.LabelMode, ".")
+		if err != nil {
+			return err
+		}
+		line := name + ":" + strconv.FormatFloat(p.Value, 'f', -1, 64) + "|g"
+		if s.LabelMode == SinkLabelsAsTags && len(p.Labels) > 0 {
+			tags := make([]string, 0, len(p.Labels))
+			for _, l := range p.Labels {
+				tags = append(tags, statsdEscaper.Replace(l.GetName())+":"+statsdEscaper.Replace(l.GetValue()))
+			}
+			line += "|#" + strings.Join(tags, ",")
+		}
+		if packet.Len() > 0 && packet.Len()+1+len(line) > statsdMaxPacketSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		if packet.Len() > 0 {
+			packet.WriteByte('\n')
+		}
+		packet.WriteString(line)
+	}
+	return flush()
+}
@@ -0,0 +1,173 @@
+package metrics
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cwr0401/redis_metrics/metrics/info"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	TargetHealthUp      = "up"
+	TargetHealthDown    = "down"
+	TargetHealthUnknown = "unknown"
+)
+
+// TargetStatus is the state of a target as reported by /api/v1/targets.
+// Role and Version come from the last successful collection.
+type TargetStatus struct {
+	Name                   string     `json:"name"`
+	Address                string     `json:"address"`
+	Source                 string     `json:"source"`
+	Health                 string     `json:"health"`
+	LastCollection         *time.Time `json:"last_collection,omitempty"`
+	LastCollectionDuration float64    `json:"last_collection_duration_seconds"`
+	LastError              string     `json:"last_error"`
+	Role                   string     `json:"role"`
+	Version                string     `json:"version"`
+	IntervalSeconds        float64    `json:"interval_seconds"`
+}
+
+// TargetInfo is the last successfully collected INFO of a target as
+// reported by /api/v1/targets/{name}/info.
+type TargetInfo struct {
+	Name     string                  `json:"name"`
+	Address  string                  `json:"address"`
+	Time     time.Time               `json:"time"`
+	Sections map[string]info.Section `json:"sections"`
+}
+
+// TargetStatuses keeps the last collection result of every target.
+type TargetStatuses struct {
+	Metrics *RedisMetrics
+
+	mu     sync.RWMutex
+	last   map[string]*CollectionResult
+	lastUp map[string]*CollectionResult
+}
+
+// NewTargetStatuses returns TargetStatuses observing the collections of
+// rm. It must be called before the first rm.Apply.
+func NewTargetStatuses(rm *RedisMetrics) *TargetStatuses {
+	s := &TargetStatuses{
+		Metrics: rm,
+		last:    make(map[string]*CollectionResult),
+		lastUp:  make(map[string]*CollectionResult),
+	}
+	rm.AddObserver(s)
+	return s
+}
+
+func (s *TargetStatuses) Collected(result *CollectionResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.last[result.Name] = result
+	if result.Err == nil {
+		s.lastUp[result.Name] = result
+	}
+}
+
+func (s *TargetStatuses) Removed(nodeName, nodeAddress string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.last, nodeName)
+	delete(s.lastUp, nodeName)
+}
+
+// Targets returns the status of every current target sorted by name.
+func (s *TargetStatuses) Targets() []TargetStatus {
+	clients := s.Metrics.Targets()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	statuses := make([]TargetStatus, 0, len(clients))
+	for _, client := range clients {
+		status := TargetStatus{
+			Name:            client.Name,
+			Address:         client.Addr,
+			Source:          TargetSourceConfigured,
+			Health:          TargetHealthUnknown,
+			IntervalSeconds: s.Metrics.Duration.Seconds(),
+		}
+		if last, ok := s.last[client.Name]; ok {
+			t := last.Time
+			status.LastCollection = &t
+			status.LastCollectionDuration = last.Duration.Seconds()
+			status.Health = TargetHealthUp
+			if last.Err != nil {
+				status.Health = TargetHealthDown
+				status.LastError = last.Err.Error()
+			}
+		}
+		if up, ok := s.lastUp[client.Name]; ok {
+			status.Role, _ = up.Info.String("Replication", "role")
+			status.Version, _ = up.Info.String("Server", "redis_version")
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// Info returns the last successfully collected INFO of the target name.
+func (s *TargetStatuses) Info(name string) (*TargetInfo, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	up, ok := s.lastUp[name]
+	if !ok {
+		return nil, false
+	}
+	return &TargetInfo{
+		Name:     up.Name,
+		Address:  up.Addr,
+		Time:     up.Time,
+		Sections: up.Info.Sections,
+	}, true
+}
+
+type apiResponse struct {
+	Status string      `json:"status"`
+	Data   interface{} `json:"data"`
+	Error  string      `json:"error,omitempty"`
+}
+
+func writeAPI(w http.ResponseWriter, code int, data interface{}, errMsg string) {
+	resp := apiResponse{Status: "success", Data: data}
+	if errMsg != "" {
+		resp = apiResponse{Status: "error", Error: errMsg}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Errorf("Write API response error: %s", err)
+	}
+}
+
+// targetsAPI serves /api/v1/targets and /api/v1/targets/{name}/info.
+func targetsAPI(statuses *TargetStatuses) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeAPI(w, http.StatusMethodNotAllowed, nil, "method not allowed")
+			return
+		}
+		path := strings.TrimSuffix(r.URL.Path, "/")
+		if path == "/api/v1/targets" {
+			writeAPI(w, http.StatusOK, statuses.Targets(), "")
+			return
+		}
+		name := strings.TrimPrefix(path, "/api/v1/targets/")
+		if !strings.HasSuffix(name, "/info") {
+			writeAPI(w, http.StatusNotFound, nil, "not found")
+			return
+		}
+		name = strings.TrimSuffix(name, "/info")
+		info, ok := statuses.Info(name)
+		if !ok {
+			writeAPI(w, http.StatusNotFound, nil, "no INFO collected from target "+name)
+			return
+		}
+		writeAPI(w, http.StatusOK, info, "")
+	}
+}
@@ -0,0 +1,432 @@
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cwr0401/redis_metrics/metrics/info"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	TopologyRoleMaster   = "master"
+	TopologyRoleReplica  = "replica"
+	TopologyRoleSentinel = "sentinel"
+	TopologyRoleUnknown  = "unknown"
+
+	TopologyEdgeReplication = "replication"
+	TopologyEdgeMonitors    = "monitors"
+
+	// AnomalyChainedReplica is a replica of a replica.
+	AnomalyChainedReplica = "chained_replica"
+	// AnomalyUnknownMaster is a replica whose master is not a target.
+	AnomalyUnknownMaster = "master_not_configured"
+	// AnomalyMultipleMasters is a replica claimed by more than one master.
+	AnomalyMultipleMasters = "multiple_masters"
+	// AnomalyOrphanedMaster is a master without replicas in a fleet that
+	// replicates.
+	AnomalyOrphanedMaster = "orphaned_master"
+
+	FormatDOT = "dot"
+)
+
+// TopologyNode is a Redis server of the replication graph, identified by its
+// address. Servers that are not targets are only known from the INFO of the
+// targets, their Name is empty and their Health unknown.
+type TopologyNode struct {
+	ID         string `json:"id"`
+	Name       string `json:"name,omitempty"`
+	Target     bool   `json:"target"`
+	Role       string `json:"role"`
+	Health     string `json:"health"`
+	LinkStatus string `json:"link_status,omitempty"`
+	Offset     *int64 `json:"offset,omitempty"`
+	LagBytes   *int64 `json:"lag_bytes,omitempty"`
+}
+
+// TopologyEdge goes from a master to a replica, or from a sentinel to a
+// master it monitors.
+type TopologyEdge struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Kind     string `json:"kind"`
+	State    string `json:"state,omitempty"`
+	LagBytes *int64 `json:"lag_bytes,omitempty"`
+}
+
+type TopologyAnomaly struct {
+	Kind    string `json:"kind"`
+	Node    string `json:"node"`
+	Message string `json:"message"`
+}
+
+type Topology struct {
+	Nodes     []*TopologyNode   `json:"nodes"`
+	Edges     []*TopologyEdge   `json:"edges"`
+	Anomalies []TopologyAnomaly `json:"anomalies"`
+}
+
+// topologyTarget is a target with its last INFO, nil when none was ever
+// collected.
+type topologyTarget struct {
+	Name    string
+	Address string
+	Health  string
+	Info    *info.Info
+}
+
+// topologyRole maps the INFO of a server to its role in the graph.
+func topologyRole(i *info.Info) string {
+	if mode, _ := i.String("Server", "redis_mode"); mode == "sentinel" {
+		return TopologyRoleSentinel
+	}
+	switch role, _ := i.String("Replication", "role"); role {
+	case "master":
+		return TopologyRoleMaster
+	case "slave":
+		return TopologyRoleReplica
+	}
+	return TopologyRoleUnknown
+}
+
+// buildTopology builds the replication graph from the role, master_host,
+// master_port and slaveN fields of the targets, and the masterN fields of
+// sentinels, and flags its anomalies. The addresses reported by INFO are
+// matched to the targets with endpoints, other servers by their address.
+func buildTopology(targets []topologyTarget, endpoints *targetEndpoints) *Topology {
+	t := &Topology{Nodes: []*TopologyNode{}, Edges: []*TopologyEdge{}, Anomalies: []TopologyAnomaly{}}
+	nodes := make(map[string]*TopologyNode)
+	node := func(addr string) *TopologyNode {
+		key := addr
+		if name := endpoints.name(addr); name != "" {
+			key = "target " + name
+		}
+		if n, ok := nodes[key]; ok {
+			return n
+		}
+		n := &TopologyNode{ID: addr, Role: TopologyRoleUnknown, Health: TargetHealthUnknown}
+		nodes[key] = n
+		t.Nodes = append(t.Nodes, n)
+		return n
+	}
+	edge := func(from, to *TopologyNode, kind string) *TopologyEdge {
+		for _, e := range t.Edges {
+			if e.From == from.ID && e.To == to.ID && e.Kind == kind {
+				return e
+			}
+		}
+		e := &TopologyEdge{From: from.ID, To: to.ID, Kind: kind}
+		t.Edges = append(t.Edges, e)
+		return e
+	}
+
+	for _, target := range targets {
+		n := node(target.Address)
+		n.Name, n.Target, n.Health = target.Name, true, target.Health
+		if target.Info == nil {
+			continue
+		}
+		n.Role = topologyRole(target.Info)
+		replication := target.Info.Section("Replication")
+		offsetKey := "master_repl_offset"
+		if n.Role == TopologyRoleReplica {
+			offsetKey = "slave_repl_offset"
+			n.LinkStatus = replication["master_link_status"]
+		}
+		if offset, err := strconv.ParseInt(replication[offsetKey], 10, 64); err == nil {
+			n.Offset = &offset
+		}
+	}
+
+	// The masters that claim each replica.
+	claims := make(map[*TopologyNode][]*TopologyNode)
+	claim := func(replica, master *TopologyNode) {
+		for _, m := range claims[replica] {
+			if m == master {
+				return
+			}
+		}
+		claims[replica] = append(claims[replica], master)
+	}
+	for _, target := range targets {
+		if target.Info == nil {
+			continue
+		}
+		n := node(target.Address)
+		replication := target.Info.Section("Replication")
+		switch n.Role {
+		case TopologyRoleMaster:
+			for _, r := range target.Info.Replicas {
+				replica := node(net.JoinHostPort(r.IP, r.Port))
+				if replica.Role == TopologyRoleUnknown {
+					replica.Role = TopologyRoleReplica
+				}
+				e := edge(n, replica, TopologyEdgeReplication)
+				e.State = r.State
+				if n.Offset != nil {
+					lag := *n.Offset - r.Offset
+					if lag < 0 {
+						lag = 0
+					}
+					e.LagBytes = &lag
+				}
+				claim(replica, n)
+			}
+		case TopologyRoleReplica:
+			master := node(net.JoinHostPort(replication["master_host"], replication["master_port"]))
+			if master.Role == TopologyRoleUnknown && !master.Target {
+				master.Role = TopologyRoleMaster
+			}
+			e := edge(master, n, TopologyEdgeReplication)
+			if e.State == "" {
+				e.State = "link " + replication["master_link_status"]
+			}
+			claim(n, master)
+		case TopologyRoleSentinel:
+			for _, m := range target.Info.SentinelMasters {
+				master := node(m.Address)
+				if master.Role == TopologyRoleUnknown && !master.Target {
+					master.Role = TopologyRoleMaster
+				}
+				edge(n, master, TopologyEdgeMonitors).State = m.Status
+			}
+		}
+	}
+
+	// Annotate the replicas with the lag their master reports.
+	for _, e := range t.Edges {
+		if e.Kind == TopologyEdgeReplication && e.LagBytes != nil {
+			node(e.To).LagBytes = e.LagBytes
+		}
+	}
+	t.Anomalies = topologyAnomalies(t, claims)
+
+	sort.Slice(t.Nodes, func(i, j int) bool { return t.Nodes[i].ID < t.Nodes[j].ID })
+	sort.Slice(t.Edges, func(i, j int) bool {
+		if t.Edges[i].From != t.Edges[j].From {
+			return t.Edges[i].From < t.Edges[j].From
+		}
+		return t.Edges[i].To < t.Edges[j].To
+	})
+	return t
+}
+
+func topologyAnomalies(t *Topology, claims map[*TopologyNode][]*TopologyNode) []TopologyAnomaly {
+	anomalies := []TopologyAnomaly{}
+	replicates := false
+	hasReplicas := make(map[string]bool)
+	for _, e := range t.Edges {
+		if e.Kind == TopologyEdgeReplication {
+			replicates = true
+			hasReplicas[e.From] = true
+		}
+	}
+	for _, n := range t.Nodes {
+		masters := claims[n]
+		if len(masters) > 1 {
+			var ids []string
+			for _, m := range masters {
+				ids = append(ids, m.ID)
+			}
+			sort.Strings(ids)
+			anomalies = append(anomalies, TopologyAnomaly{
+				Kind:    AnomalyMultipleMasters,
+				Node:    n.ID,
+				Message: fmt.Sprintf("claimed as a replica by %s", strings.Join(ids, ", ")),
+			})
+		}
+		if !n.Target {
+			continue
+		}
+		switch n.Role {
+		case TopologyRoleReplica:
+			for _, m := range masters {
+				if !m.Target {
+					anomalies = append(anomalies, TopologyAnomaly{
+						Kind:    AnomalyUnknownMaster,
+						Node:    n.ID,
+						Message: fmt.Sprintf("master %s is not a target", m.ID),
+					})
+				}
+				if m.Role == TopologyRoleReplica {
+					anomalies = append(anomalies, TopologyAnomaly{
+						Kind:    AnomalyChainedReplica,
+						Node:    n.ID,
+						Message: fmt.Sprintf("replicates from %s, itself a replica", m.ID),
+					})
+				}
+			}
+		case TopologyRoleMaster:
+			if replicates && !hasReplicas[n.ID] {
+				anomalies = append(anomalies, TopologyAnomaly{
+					Kind:    AnomalyOrphanedMaster,
+					Node:    n.ID,
+					Message: "master without replicas",
+				})
+			}
+		}
+	}
+	sort.SliceStable(anomalies, func(i, j int) bool { return anomalies[i].Node < anomalies[j].Node })
+	return anomalies
+}
+
+// writeDOT writes the graph in the Graphviz DOT language.
+func (t *Topology) writeDOT(w io.Writer) error {
+	colors := map[string]string{
+		TargetHealthUp:      "darkgreen",
+		TargetHealthDown:    "red",
+		TargetHealthUnknown: "gray",
+	}
+	shapes := map[string]string{
+		TopologyRoleMaster:   "doubleoctagon",
+		TopologyRoleReplica:  "box",
+		TopologyRoleSentinel: "diamond",
+		TopologyRoleUnknown:  "ellipse",
+	}
+	anomalous := make(map[string][]string)
+	for _, a := range t.Anomalies {
+		anomalous[a.Node] = append(anomalous[a.Node], a.Kind)
+	}
+	var b strings.Builder
+	b.WriteString("digraph redis {\n\trankdir=LR;\n\tnode [fontname=\"sans-serif\"];\n")
+	for _, n := range t.Nodes {
+		label := []string{n.ID, n.Role}
+		if n.Name != "" {
+			label = append([]string{n.Name}, label...)
+		}
+		if n.LinkStatus != "" {
+			label = append(label, "link "+n.LinkStatus)
+		}
+		if n.LagBytes != nil {
+			label = append(label, fmt.Sprintf("lag %dB", *n.LagBytes))
+		}
+		label = append(label, anomalous[n.ID]...)
+		style := "solid"
+		if !n.Target {
+			style = "dashed"
+		}
+		fmt.Fprintf(&b, "\t%s [label=%s, shape=%s, color=%s, style=%s];\n", dotQuote(n.ID),
+			dotQuote(strings.Join(label, "\n")), shapes[n.Role], colors[n.Health], style)
+	}
+	for _, e := range t.Edges {
+		label := e.State
+		if e.LagBytes != nil {
+			label += fmt.Sprintf(" lag %dB", *e.LagBytes)
+		}
+		style := "solid"
+		if e.Kind == TopologyEdgeMonitors {
+			style = "dotted"
+		}
+		fmt.Fprintf(&b, "\t%s -> %s [label=%s, style=%s];\n", dotQuote(e.From), dotQuote(e.To),
+			dotQuote(strings.TrimSpace(label)), style)
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func dotQuote(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, `"`, `\"`, -1)
+	return `"` + strings.Replace(s, "\n", `\n`, -1) + `"`
+}
+
+// topologyTargets returns the current targets with their last successfully
+// collected INFO.
+func (s *TargetStatuses) topologyTargets() []topologyTarget {
+	var targets []topologyTarget
+	for _, status := range s.Targets() {
+		target := topologyTarget{Name: status.Name, Address: status.Address, Health: status.Health}
+		s.mu.RLock()
+		if up, ok := s.lastUp[status.Name]; ok {
+			target.Info = up.Info
+		}
+		s.mu.RUnlock()
+		targets = append(targets, target)
+	}
+	return targets
+}
+
+// topologyAddresses returns the addresses of targets keyed by name.
+func topologyAddresses(targets []topologyTarget) map[string]string {
+	addresses := make(map[string]string, len(targets))
+	for _, target := range targets {
+		addresses[target.Name] = target.Address
+	}
+	return addresses
+}
+
+// topologyAPI serves /api/v1/topology, as DOT with ?format=dot.
+func topologyAPI(statuses *TargetStatuses) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeAPI(w, http.StatusMethodNotAllowed, nil, "method not allowed")
+			return
+		}
+		topology := buildTopology(statuses.topologyTargets(), statuses.Metrics.Endpoints())
+		switch format := r.URL.Query().Get("format"); format {
+		case "", FormatJSON:
+			writeAPI(w, http.StatusOK, topology, "")
+		case FormatDOT:
+			w.Header().Set("Content-Type", "text/vnd.graphviz; charset=utf-8")
+			if err := topology.writeDOT(w); err != nil {
+				log.Errorf("Write topology error: %s", err)
+			}
+		default:
+			writeAPI(w, http.StatusBadRequest, nil, fmt.Sprintf("unknown format %q", format))
+		}
+	}
+}
+
+// TopologyAction collects every target of the configuration file once and
+// prints the replication graph.
+func TopologyAction(c *cli.Context) error {
+	log.SetOutput(os.Stderr)
+	log.SetLevel(log.WarnLevel)
+	if c.Bool("debug") {
+		log.SetLevel(log.DebugLevel)
+	}
+	format := c.String("format")
+	if format != FormatJSON && format != FormatDOT {
+		return fmt.Errorf("unknown format %q, must be %s or %s", format, FormatJSON, FormatDOT)
+	}
+	redisConfig, err := loadConfigFile(c.String("config"))
+	if err != nil {
+		return err
+	}
+
+	targets := make([]topologyTarget, len(redisConfig.RedisInstances))
+	var wg sync.WaitGroup
+	for i, instance := range redisConfig.RedisInstances {
+		wg.Add(1)
+		go func(i int, client *RedisClient) {
+			defer wg.Done()
+			defer client.Client.Close()
+			targets[i] = topologyTarget{Name: client.Name, Address: client.Addr, Health: TargetHealthUp}
+			infoMap, _, _, err := redisInfoToMetrics(client.Name, client.Addr, client.Client)
+			if err != nil {
+				targets[i].Health = TargetHealthDown
+				return
+			}
+			targets[i].Info = infoMap
+		}(i, newRedisClient(instance))
+	}
+	wg.Wait()
+
+	topology := buildTopology(targets, newTargetEndpoints(topologyAddresses(targets)))
+	if format == FormatDOT {
+		return topology.writeDOT(os.Stdout)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(topology)
+}
@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/cwr0401/redis_metrics/metrics/info"
+)
+
+func replicaInfo(master string) *info.Info {
+	host, port := strings.Split(master, ":")[0], strings.Split(master, ":")[1]
+	return info.ParseInfo("# Replication\r\nrole:slave\r\nmaster_host:" + host + "\r\nmaster_port:" + port +
+		"\r\nmaster_link_status:up\r\nslave_repl_offset:90\r\n")
+}
+
+func TestBuildTopology(t *testing.T) {
+	targets := []topologyTarget{
+		{Name: "m", Address: "127.0.0.1:6379", Health: TargetHealthUp, Info: info.ParseInfo(
+			"# Replication\r\nrole:master\r\nmaster_repl_offset:100\r\n" +
+				"slave0:ip=127.0.0.1,port=6380,state=online,offset=90,lag=0\r\n")},
+		{Name: "m2", Address: "127.0.0.2:6379", Health: TargetHealthUp, Info: info.ParseInfo(
+			"# Replication\r\nrole:master\r\nmaster_repl_offset:5\r\n" +
+				"slave0:ip=127.0.0.1,port=6380,state=online,offset=5,lag=0\r\n")},
+		{Name: "orphan", Address: "127.0.0.3:6379", Health: TargetHealthUp, Info: info.ParseInfo(
+			"# Replication\r\nrole:master\r\nmaster_repl_offset:0\r\n")},
+		{Name: "r1", Address: "127.0.0.1:6380", Health: TargetHealthUp, Info: replicaInfo("127.0.0.1:6379")},
+		{Name: "chained", Address: "127.0.0.1:6381", Health: TargetHealthUp, Info: replicaInfo("127.0.0.1:6380")},
+		{Name: "lost", Address: "127.0.0.1:6382", Health: TargetHealthUp, Info: replicaInfo("127.0.0.9:6379")},
+		{Name: "down", Address: "127.0.0.1:6383", Health: TargetHealthDown},
+	}
+	topology := buildTopology(targets, newTargetEndpoints(topologyAddresses(targets)))
+
+	var got []string
+	for _, a := range topology.Anomalies {
+		got = append(got, a.Kind+" "+a.Node)
+	}
+	want := []string{
+		"multiple_masters 127.0.0.1:6380",
+		"chained_replica 127.0.0.1:6381",
+		"master_not_configured 127.0.0.1:6382",
+		"orphaned_master 127.0.0.3:6379",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("anomalies:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	for _, n := range topology.Nodes {
+		switch n.ID {
+		case "127.0.0.1:6380":
+			if n.Role != TopologyRoleReplica || n.LinkStatus != "up" || n.LagBytes == nil {
+				t.Errorf("r1 %+v", n)
+			}
+		case "127.0.0.9:6379":
+			if n.Target || n.Role != TopologyRoleMaster || n.Health != TargetHealthUnknown {
+				t.Errorf("external master %+v", n)
+			}
+		}
+	}
+	if len(topology.Nodes) != 8 {
+		t.Errorf("%d nodes, want 8", len(topology.Nodes))
+	}
+
+	var dot bytes.Buffer
+	if err := topology.writeDOT(&dot); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(dot.String(), `"127.0.0.1:6379" -> "127.0.0.1:6380" [label="online lag 10B"`) {
+		t.Errorf("DOT output:\n%s", dot.String())
+	}
+
+	// The dashboard page groups the same graph by master.
+	dashboard := newDashboardTopology(topology)
+	var groups []string
+	for _, g := range dashboard.Groups {
+		var replicas []string
+		for _, r := range g.Replicas {
+			replicas = append(replicas, r.Address)
+		}
+		groups = append(groups, g.Address+" ["+strings.Join(replicas, " ")+"]")
+	}
+	wantGroups := []string{
+		"127.0.0.1:6379 [127.0.0.1:6380]",
+		"127.0.0.2:6379 [127.0.0.1:6380]",
+		"127.0.0.3:6379 []",
+		"127.0.0.9:6379 [127.0.0.1:6382]",
+		"127.0.0.1:6380 [127.0.0.1:6381]",
+	}
+	if strings.Join(groups, "\n") != strings.Join(wantGroups, "\n") {
+		t.Errorf("groups:\n%s\nwant:\n%s", strings.Join(groups, "\n"), strings.Join(wantGroups, "\n"))
+	}
+	var page bytes.Buffer
+	if err := dashboardPages["topology"].Execute(&page, dashboard); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`<a href="/targets/r1">r1</a>`, "chained_replica",
+		"127.0.0.9:6379 <small>(not a target)</small>", "No replicas.", "10B"} {
+		if !strings.Contains(page.String(), want) {
+			t.Errorf("topology page lacks %s:\n%s", want, page.String())
+		}
+	}
+}